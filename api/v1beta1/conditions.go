@@ -16,6 +16,8 @@ limitations under the License.
 package v1beta1
 
 import (
+	"strings"
+
 	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
 )
 
@@ -37,6 +39,83 @@ const (
 
 	// KeystoneServiceOSUserReadyCondition Status=True condition which indicates if the service user got created in the keystone instance is ready/was successful
 	KeystoneServiceOSUserReadyCondition condition.Type = "KeystoneServiceOSUserReady"
+
+	// KeystoneServiceOSEndpointsInSyncCondition Status=True condition which indicates if all registered endpoints of the service are declared in the spec
+	KeystoneServiceOSEndpointsInSyncCondition condition.Type = "KeystoneServiceOSEndpointsInSync"
+
+	// KeystoneServiceOSEndpointsStableCondition Status=True condition which indicates if the endpoints are not updated repeatedly
+	KeystoneServiceOSEndpointsStableCondition condition.Type = "KeystoneServiceOSEndpointsStable"
+
+	// KeystoneClockInSyncCondition Status=True condition which indicates if the clocks of the operator and keystone agree within the clock skew threshold
+	KeystoneClockInSyncCondition condition.Type = "KeystoneClockInSync"
+
+	// KeystoneCatalogServicesReadyCondition Status=True condition which indicates if all services of the catalog and their endpoints are ready
+	KeystoneCatalogServicesReadyCondition condition.Type = "KeystoneCatalogServicesReady"
+
+	// KeystoneServiceDependenciesReadyCondition Status=True condition which indicates if the KeystoneServices the service depends on are ready
+	KeystoneServiceDependenciesReadyCondition condition.Type = "KeystoneServiceDependenciesReady"
+
+	// KeystoneServiceIDAppliedCondition Status=True condition which indicates if the service is registered with the ServiceID of the spec
+	KeystoneServiceIDAppliedCondition condition.Type = "KeystoneServiceIDApplied"
+
+	// KeystoneServiceTemplateServicesReadyCondition Status=True condition which indicates if all services expanded from the template and their endpoints are ready
+	KeystoneServiceTemplateServicesReadyCondition condition.Type = "KeystoneServiceTemplateServicesReady"
+
+	// KeystoneRoleReadyCondition Status=True condition which indicates if the role and its inference rules got created in the keystone instance
+	KeystoneRoleReadyCondition condition.Type = "KeystoneRoleReady"
+)
+
+//
+// Keystone Reasons used by API objects.
+//
+const (
+	// KeystoneUnavailableReason - keystone is not contacted as authentication failed repeatedly
+	KeystoneUnavailableReason condition.Reason = "KeystoneUnavailable"
+
+	// AuthenticationFailedReason - keystone rejected the admin credentials (401)
+	AuthenticationFailedReason condition.Reason = "AuthenticationFailed"
+
+	// AuthorizationFailedReason - the admin user is not allowed to manage the catalog (403)
+	AuthorizationFailedReason condition.Reason = "AuthorizationFailed"
+
+	// AmbiguousRegionReason - the KeystoneAPI has no region and keystone has multiple
+	AmbiguousRegionReason condition.Reason = "AmbiguousRegion"
+
+	// AmbiguousIdentityEndpointReason - multiple identity endpoints of the catalog match the region of the KeystoneAPI
+	AmbiguousIdentityEndpointReason condition.Reason = "AmbiguousIdentityEndpoint"
+
+	// DomainScopedServiceUnsupportedReason - keystone does not associate services with a domain
+	DomainScopedServiceUnsupportedReason condition.Reason = "DomainScopedServiceUnsupported"
+
+	// ClientCertificateInvalidReason - the client certificate of the ClientTLSSecret cannot be loaded
+	ClientCertificateInvalidReason condition.Reason = "ClientCertificateInvalid"
+
+	// EndpointDriftReason - endpoints are registered which are not declared in the spec
+	EndpointDriftReason condition.Reason = "EndpointDrift"
+
+	// EndpointFlappingReason - endpoints get updated on most reconciles
+	EndpointFlappingReason condition.Reason = "EndpointFlapping"
+
+	// EndpointRecreatedReason - a tracked endpoint got deleted out-of-band and was registered again
+	EndpointRecreatedReason condition.Reason = "EndpointRecreated"
+
+	// EndpointUnreachableReason - the HealthCheck of an endpoint failed
+	EndpointUnreachableReason condition.Reason = "EndpointUnreachable"
+
+	// ClockSkewDetectedReason - the token keystone issued is timestamped too far from the clock of the operator
+	ClockSkewDetectedReason condition.Reason = "ClockSkewDetected"
+
+	// WaitingForAPIControllerReason - the KeystoneAPI exists, but its controller did not populate its status yet
+	WaitingForAPIControllerReason condition.Reason = "WaitingForAPIController"
+
+	// WaitingForDependencyReason - a KeystoneService the service depends on is not ready
+	WaitingForDependencyReason condition.Reason = "WaitingForDependency"
+
+	// ServiceIDNotAppliedReason - the service is registered with another ID than the ServiceID of the spec, e.g. keystone assigned its own
+	ServiceIDNotAppliedReason condition.Reason = "ServiceIDNotApplied"
+
+	// FailedReason - the reconcile failed more often than the MaxRetries of the resource
+	FailedReason condition.Reason = "Failed"
 )
 
 //
@@ -59,6 +138,12 @@ const (
 	// KeystoneAPIReadyWaitingMessage
 	KeystoneAPIReadyWaitingMessage = "KeystoneAPI not yet ready"
 
+	// KeystoneAPIReadyWaitingControllerMessage
+	KeystoneAPIReadyWaitingControllerMessage = "KeystoneAPI status not yet populated by its controller"
+
+	// KeystoneAPIReadyWaitingBootstrapMessage
+	KeystoneAPIReadyWaitingBootstrapMessage = "KeystoneAPI bootstrap not yet completed"
+
 	// KeystoneAPIReadyErrorMessage
 	KeystoneAPIReadyErrorMessage = "KeystoneAPI error occured %s"
 
@@ -77,6 +162,27 @@ const (
 	// AdminServiceClientReadyErrorMessage
 	AdminServiceClientReadyErrorMessage = "Admin client error occured %s"
 
+	// AuthenticationFailedMessage
+	AuthenticationFailedMessage = "Keystone rejected the admin credentials, check the admin user and password: %s"
+
+	// AuthorizationFailedMessage
+	AuthorizationFailedMessage = "Admin user lacks the admin role to manage the catalog: %s"
+
+	// AmbiguousRegionMessage
+	AmbiguousRegionMessage = "KeystoneAPI has no region, set it to one of the keystone regions: %s"
+
+	// AmbiguousIdentityEndpointMessage
+	AmbiguousIdentityEndpointMessage = "Identity endpoint not unique: %s"
+
+	// DomainScopedServiceUnsupportedMessage
+	DomainScopedServiceUnsupportedMessage = "Keystone does not support domain scoped services, remove the domain: %s"
+
+	// ClientCertificateInvalidMessage
+	ClientCertificateInvalidMessage = "Client certificate for keystone cannot be loaded, check the clientTLSSecret of the KeystoneAPI: %s"
+
+	// AdminServiceClientReadyKeystoneUnavailableMessage
+	AdminServiceClientReadyKeystoneUnavailableMessage = "Keystone unavailable, retrying authentication in %s"
+
 	//
 	// KeystoneServiceOSServiceReady condition messages
 	//
@@ -86,9 +192,21 @@ const (
 	// KeystoneServiceOSServiceReadyMessage
 	KeystoneServiceOSServiceReadyMessage = "Keystone Service %s - %s ready"
 
+	// KeystoneServiceOSServiceReadyAdoptedMessage
+	KeystoneServiceOSServiceReadyAdoptedMessage = "Keystone Service %s - %s ready, adopted already registered services: %s"
+
 	// AdminServiceClientReadyErrorMessage
 	KeystoneServiceOSServiceReadyErrorMessage = "Keystone Service error occured %s"
 
+	// KeystoneServiceOSServiceReadyNotConfirmedMessage
+	KeystoneServiceOSServiceReadyNotConfirmedMessage = "Keystone Service %s created, waiting for it to be visible in keystone: %s"
+
+	// KeystoneServiceFailedMessage
+	KeystoneServiceFailedMessage = "Keystone Service failed after %d attempts, retrying on a spec change or in %s: %s"
+
+	// KeystoneEndpointFailedMessage
+	KeystoneEndpointFailedMessage = "Keystone Endpoints failed after %d attempts, retrying on a spec change or in %s: %s"
+
 	//
 	// KeystoneServiceOSEndpointsReady condition messages
 	//
@@ -98,9 +216,57 @@ const (
 	// KeystoneServiceOSEndpointsReadyMessage
 	KeystoneServiceOSEndpointsReadyMessage = "Keystone Endpoints ready: %+v"
 
+	// KeystoneServiceOSEndpointsReadyWaitingParentRegionMessage
+	KeystoneServiceOSEndpointsReadyWaitingParentRegionMessage = "Keystone Endpoints waiting for parent region %s"
+
+	// KeystoneServiceOSEndpointsReadyWaitingServiceMessage
+	KeystoneServiceOSEndpointsReadyWaitingServiceMessage = "Keystone Endpoints waiting for service %s to be visible"
+
+	// KeystoneServiceOSEndpointsReadyWaitingURLRefMessage
+	KeystoneServiceOSEndpointsReadyWaitingURLRefMessage = "Keystone Endpoints waiting for the endpoint URL references: %s"
+
+	// KeystoneServiceOSEndpointsReadyWaitingCatalogMessage
+	KeystoneServiceOSEndpointsReadyWaitingCatalogMessage = "Keystone Endpoints not yet in the catalog: %s"
+
 	// KeystoneServiceOSEndpointsReadyErrorMessage
 	KeystoneServiceOSEndpointsReadyErrorMessage = "Keystone Endpoints error occured %s"
 
+	//
+	// KeystoneServiceOSEndpointsInSync condition messages
+	//
+	// KeystoneServiceOSEndpointsInSyncMessage
+	KeystoneServiceOSEndpointsInSyncMessage = "Keystone Endpoints in sync"
+
+	// KeystoneServiceOSEndpointsInSyncDriftMessage
+	KeystoneServiceOSEndpointsInSyncDriftMessage = "Keystone Endpoints not declared in the spec: %s"
+
+	//
+	// KeystoneServiceOSEndpointsStable condition messages
+	//
+	// KeystoneServiceOSEndpointsStableMessage
+	KeystoneServiceOSEndpointsStableMessage = "Keystone Endpoints stable"
+
+	// KeystoneServiceOSEndpointsStableFlappingMessage
+	KeystoneServiceOSEndpointsStableFlappingMessage = "Keystone Endpoints updated repeatedly: %s within %s"
+
+	//
+	// KeystoneClockInSync condition messages
+	//
+	// KeystoneClockInSyncMessage
+	KeystoneClockInSyncMessage = "Clock in sync with keystone"
+
+	// KeystoneClockInSyncSkewMessage
+	KeystoneClockInSyncSkewMessage = "Clock skewed by %s from keystone, tokens may get rejected early or late"
+
+	//
+	// KeystoneEndpointReachable condition messages
+	//
+	// KeystoneEndpointReachableMessage
+	KeystoneEndpointReachableMessage = "Keystone Endpoint %s reachable"
+
+	// KeystoneEndpointReachableErrorMessage
+	KeystoneEndpointReachableErrorMessage = "Keystone Endpoint %s unreachable: %s"
+
 	//
 	// KeystoneServiceOSUserReady condition messages
 	//
@@ -115,4 +281,82 @@ const (
 
 	// KeystoneServiceOSUserReadyErrorMessage
 	KeystoneServiceOSUserReadyErrorMessage = "Keystone Service user error occured %s"
+
+	//
+	// KeystoneCatalogServicesReady condition messages
+	//
+	// KeystoneCatalogServicesReadyInitMessage
+	KeystoneCatalogServicesReadyInitMessage = "Keystone Catalog services not started"
+
+	// KeystoneCatalogServicesReadyMessage
+	KeystoneCatalogServicesReadyMessage = "Keystone Catalog services ready"
+
+	// KeystoneCatalogServicesReadyWaitingMessage
+	KeystoneCatalogServicesReadyWaitingMessage = "Keystone Catalog services not yet ready: %s"
+
+	// KeystoneCatalogServicesReadyErrorMessage
+	KeystoneCatalogServicesReadyErrorMessage = "Keystone Catalog services error occured %s"
+
+	//
+	// KeystoneServiceDependenciesReady condition messages
+	//
+	// KeystoneServiceDependenciesReadyMessage
+	KeystoneServiceDependenciesReadyMessage = "Keystone Service dependencies ready"
+
+	// KeystoneServiceDependenciesReadyWaitingMessage
+	KeystoneServiceDependenciesReadyWaitingMessage = "Keystone Service waiting for the KeystoneServices: %s"
+
+	//
+	// KeystoneServiceIDApplied condition messages
+	//
+	// KeystoneServiceIDAppliedMessage
+	KeystoneServiceIDAppliedMessage = "Keystone Service registered with the ID of the spec"
+
+	// KeystoneServiceIDAppliedMismatchMessage
+	KeystoneServiceIDAppliedMismatchMessage = "Keystone Service registered with ID %s instead of %s"
+
+	//
+	// KeystoneServiceTemplateServicesReady condition messages
+	//
+	// KeystoneServiceTemplateServicesReadyInitMessage
+	KeystoneServiceTemplateServicesReadyInitMessage = "Keystone Service template services not started"
+
+	// KeystoneServiceTemplateServicesReadyMessage
+	KeystoneServiceTemplateServicesReadyMessage = "Keystone Service template services ready"
+
+	// KeystoneServiceTemplateServicesReadyWaitingMessage
+	KeystoneServiceTemplateServicesReadyWaitingMessage = "Keystone Service template services not yet ready, %d of %d ready"
+
+	// KeystoneServiceTemplateServicesReadyErrorMessage
+	KeystoneServiceTemplateServicesReadyErrorMessage = "Keystone Service template services error occured %s"
+
+	//
+	// KeystoneRoleReady condition messages
+	//
+	// KeystoneRoleReadyInitMessage
+	KeystoneRoleReadyInitMessage = "Keystone Role not started"
+
+	// KeystoneRoleReadyMessage
+	KeystoneRoleReadyMessage = "Keystone Role %s ready"
+
+	// KeystoneRoleReadyErrorMessage
+	KeystoneRoleReadyErrorMessage = "Keystone Role error occured %s"
 )
+
+// KeystoneEndpointReachableCondition - returns the Status=True condition type
+// which indicates if the endpoint of endpointType passed the HealthCheck, e.g.
+// KeystoneEndpointPublicReachable
+func KeystoneEndpointReachableCondition(endpointType string) condition.Type {
+	if endpointType != "" {
+		endpointType = strings.ToUpper(endpointType[:1]) + endpointType[1:]
+	}
+
+	return condition.Type("KeystoneEndpoint" + endpointType + "Reachable")
+}
+
+// IsKeystoneEndpointReachableCondition - returns true if t is a condition type
+// returned by KeystoneEndpointReachableCondition
+func IsKeystoneEndpointReachableCondition(t condition.Type) bool {
+	return strings.HasPrefix(string(t), "KeystoneEndpoint") &&
+		strings.HasSuffix(string(t), "Reachable")
+}
@@ -98,6 +98,13 @@ func GetAdminServiceClient(
 		return nil, ctrlResult, nil
 	}
 
+	// the openstack client of lib-common has a single domain for the user and
+	// the project
+	domainName := keystoneAPI.GetAdminUserDomain()
+	if domainName == "" {
+		domainName = "Default"
+	}
+
 	os, err := openstack.NewOpenStack(
 		h.GetLogger(),
 		openstack.AuthOpts{
@@ -105,8 +112,8 @@ func GetAdminServiceClient(
 			Username:   keystoneAPI.Spec.AdminUser,
 			Password:   authPassword,
 			TenantName: keystoneAPI.Spec.AdminProject,
-			DomainName: "Default",
-			Region:     keystoneAPI.Spec.Region,
+			DomainName: domainName,
+			Region:     keystoneAPI.GetRegion(),
 		})
 	if err != nil {
 		return nil, ctrl.Result{}, err
@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	"fmt"
+	"strings"
 
 	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/endpoint"
@@ -37,8 +38,60 @@ const (
 
 	// FernetKeysHash completed
 	FernetKeysHash = "fernetkeys"
+
+	// AuthModePassword - authenticate as AdminUser using the admin password
+	AuthModePassword = "password"
+
+	// AuthModeToken - authenticate using a pre-scoped token
+	AuthModeToken = "token"
+
+	// AuthModeServiceAccount - authenticate by exchanging the service account
+	// token of the operator for a keystone token using federation
+	AuthModeServiceAccount = "serviceAccount"
+
+	// AuthModeApplicationCredential - authenticate using the application
+	// credential ApplicationCredentialID and its secret
+	AuthModeApplicationCredential = "v3applicationcredential"
+
+	// AuthModeOIDCPassword - authenticate as AdminUser against the OIDC token
+	// endpoint of the identity provider and exchange the access token for a
+	// keystone token using federation
+	AuthModeOIDCPassword = "v3oidcpassword"
 )
 
+// BootstrapHashAnnotation - annotation a KeystoneAPI which does not report the
+// bootstrap hash in its status signals the completed bootstrap with, set by the
+// --bootstrap-hash-annotation flag of the manager
+var BootstrapHashAnnotation = "keystone.openstack.org/bootstrap-hash"
+
+// SkipBootstrapGate - treat every KeystoneAPI as bootstrapped, for
+// environments where keystone never signals the completed bootstrap, set by
+// the --skip-bootstrap-gate flag of the manager
+var SkipBootstrapGate = false
+
+// ReauthAnnotation - annotation which, set to true on a KeystoneService or
+// KeystoneEndpoint, forces its next reconcile to authenticate against keystone,
+// e.g. to verify rotated admin credentials, set by the --reauth-annotation
+// flag of the manager. It gets removed once the authentication succeeded.
+var ReauthAnnotation = "keystone.openstack.org/reauth"
+
+// Finalizer - finalizer the KeystoneService, KeystoneEndpoint and KeystoneRole
+// controllers register to clean up keystone on delete, set by the
+// --finalizer-name flag of the manager. Empty uses the default finalizer of
+// the controller.
+var Finalizer = ""
+
+// DisableFinalizer - do not register the Finalizer, for environments which
+// clean up keystone outside of the operator, set by the --disable-finalizer
+// flag of the manager
+var DisableFinalizer = false
+
+// SkipFinalizerAnnotation - annotation which, set to true on a KeystoneService,
+// KeystoneEndpoint or KeystoneRole, disables the Finalizer for it. Deleting
+// it then leaves its resources in keystone. Set by the
+// --skip-finalizer-annotation flag of the manager.
+var SkipFinalizerAnnotation = "keystone.openstack.org/skip-finalizer"
+
 // KeystoneAPISpec defines the desired state of KeystoneAPI
 type KeystoneAPISpec struct {
 	// +kubebuilder:validation:Required
@@ -54,15 +107,59 @@ type KeystoneAPISpec struct {
 	DatabaseUser string `json:"databaseUser"`
 
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=regionOne
-	// Region - optional region name for the keystone service
+	// Region - optional region name for the keystone service, surrounding whitespace
+	// is ignored. Defaults to DefaultRegion.
 	Region string `json:"region"`
 
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=RegionOne
+	// DefaultRegion - region used if Region is empty, defaults to RegionOne. If both
+	// are empty, keystone must have a single region which then gets used.
+	DefaultRegion string `json:"defaultRegion"`
+
+	// +kubebuilder:validation:Optional
+	// RegionID - optional ID of the region endpoints get registered in and looked up
+	// by, defaults to Region. Set it if the region ID differs from the Region name
+	// used to select the identity endpoint from the catalog.
+	RegionID string `json:"regionID,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ParentRegion - optional parent region of Region. If set, Region gets created
+	// as child of ParentRegion before endpoints are registered in it.
+	ParentRegion string `json:"parentRegion,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AutoCreateRegion - create the region if registering an endpoint fails because
+	// it does not exist, instead of failing the reconcile
+	AutoCreateRegion bool `json:"autoCreateRegion,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=admin
 	// AdminProject - admin project name
 	AdminProject string `json:"adminProject"`
 
+	// +kubebuilder:validation:Optional
+	// AdminProjectID - optional admin project ID, takes precedence over AdminProject to scope
+	// the admin token of the service catalog reconcilers. Use it when projects with the
+	// AdminProject name exist in multiple domains.
+	AdminProjectID string `json:"adminProjectID,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AdminDomain - optional domain of the AdminUser used by the service catalog
+	// reconcilers, defaults to the --default-domain of the operator
+	AdminDomain string `json:"adminDomain,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AdminUserDomain - optional domain of the AdminUser, defaults to AdminDomain. Set
+	// it together with AdminProjectDomain if the AdminUser and the AdminProject live in
+	// different domains.
+	AdminUserDomain string `json:"adminUserDomain,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AdminProjectDomain - optional domain of the AdminProject the admin token gets
+	// scoped to, defaults to AdminDomain
+	AdminProjectDomain string `json:"adminProjectDomain,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=admin
 	// AdminRole - admin role name
@@ -73,6 +170,72 @@ type KeystoneAPISpec struct {
 	// AdminUser - admin user name
 	AdminUser string `json:"adminUser"`
 
+	// +kubebuilder:validation:Optional
+	// AuthURLs - optional list of identity endpoints the service catalog reconcilers
+	// authenticate against, tried in order. The next one is only tried while keystone
+	// is unavailable, i.e. on a connection failure or a 5xx response. If empty the
+	// public endpoint of the KeystoneAPI is used.
+	AuthURLs []string `json:"authURLs,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// IdentityEndpoint - optional identity v3 endpoint, including a path prefix
+	// and the version, e.g. https://cloud.example.com/identity/v3, the service
+	// catalog reconcilers send their requests to instead of the identity
+	// endpoint discovered from the catalog
+	IdentityEndpoint string `json:"identityEndpoint,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=password;token;serviceAccount;v3applicationcredential;v3oidcpassword
+	// +kubebuilder:default=password
+	// AuthMode - how the service catalog reconcilers authenticate. With password the
+	// AdminUser password from Secret is used, with token a pre-scoped token from
+	// AuthTokenSecret is used and no password is read. With serviceAccount the
+	// service account token of the operator is exchanged for a keystone token using
+	// FederationIdentityProvider and FederationProtocol, and scoped to AdminProject.
+	// With v3applicationcredential the ApplicationCredentialID and its secret from
+	// Secret are used. With v3oidcpassword the AdminUser password from Secret is
+	// exchanged for an access token at the OIDCTokenEndpoint, which then gets
+	// exchanged like the service account token.
+	AuthMode string `json:"authMode,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ApplicationCredentialID - ID of the application credential used with
+	// AuthMode v3applicationcredential
+	ApplicationCredentialID string `json:"applicationCredentialID,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// OIDCTokenEndpoint - token endpoint of the OIDC identity provider used with
+	// AuthMode v3oidcpassword
+	OIDCTokenEndpoint string `json:"oidcTokenEndpoint,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// OIDCClientID - OIDC client the access token gets requested for with AuthMode
+	// v3oidcpassword
+	OIDCClientID string `json:"oidcClientID,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// FederationIdentityProvider - keystone identity provider trusting the service
+	// account tokens, used with AuthMode serviceAccount
+	FederationIdentityProvider string `json:"federationIdentityProvider,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=openid
+	// FederationProtocol - federation protocol of the FederationIdentityProvider,
+	// used with AuthMode serviceAccount
+	FederationProtocol string `json:"federationProtocol,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AuthTokenSecret - Secret containing the token used with AuthMode token,
+	// defaults to Secret
+	AuthTokenSecret string `json:"authTokenSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ClientTLSSecret - optional kubernetes.io/tls Secret with the client
+	// certificate (tls.crt) and key (tls.key) the service catalog reconcilers
+	// present to keystone, for identity endpoints requiring mutual TLS. A CA
+	// bundle in its ca.crt is trusted in addition to the system CAs.
+	ClientTLSSecret string `json:"clientTLSSecret,omitempty"`
+
 	// +kubebuilder:validation:Required
 	// Keystone Container Image URL
 	ContainerImage string `json:"containerImage,omitempty"`
@@ -136,6 +299,20 @@ type PasswordSelector struct {
 	// +kubebuilder:default="AdminPassword"
 	// Admin - Selector to get the keystone Admin password from the Secret
 	Admin string `json:"admin,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="AdminToken"
+	// AdminToken - Selector to get the token used with AuthMode token from the AuthTokenSecret
+	AdminToken string `json:"adminToken,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="ApplicationCredentialSecret"
+	// ApplicationCredentialSecret - Selector to get the application credential secret
+	// used with AuthMode v3applicationcredential from the Secret
+	ApplicationCredentialSecret string `json:"applicationCredentialSecret,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="OIDCClientSecret"
+	// OIDCClientSecret - Selector to get the secret of the OIDCClientID used with
+	// AuthMode v3oidcpassword from the Secret
+	OIDCClientSecret string `json:"oidcClientSecret,omitempty"`
 }
 
 // KeystoneDebug defines the observed state of KeystoneAPI
@@ -170,6 +347,10 @@ type KeystoneAPIStatus struct {
 
 	// Keystone Database Hostname
 	DatabaseHostname string `json:"databaseHostname,omitempty"`
+
+	// Region - the default region bootstrapped in keystone, resolved from
+	// Region and DefaultRegion. KeystoneServices without a region inherit it.
+	Region string `json:"region,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -207,6 +388,71 @@ func (instance KeystoneAPI) GetEndpoint(endpointType endpoint.Endpoint) (string,
 	return "", fmt.Errorf("%s endpoint not found", string(endpointType))
 }
 
+// GetAdminUserDomain - returns the domain of the AdminUser, AdminDomain if
+// AdminUserDomain is empty
+func (instance KeystoneAPI) GetAdminUserDomain() string {
+	if instance.Spec.AdminUserDomain != "" {
+		return instance.Spec.AdminUserDomain
+	}
+
+	return instance.Spec.AdminDomain
+}
+
+// GetAdminProjectDomain - returns the domain of the AdminProject, AdminDomain
+// if AdminProjectDomain is empty
+func (instance KeystoneAPI) GetAdminProjectDomain() string {
+	if instance.Spec.AdminProjectDomain != "" {
+		return instance.Spec.AdminProjectDomain
+	}
+
+	return instance.Spec.AdminDomain
+}
+
+// GetRegion - returns the region name with surrounding whitespace removed,
+// DefaultRegion if Region is empty
+func (instance KeystoneAPI) GetRegion() string {
+	if region := strings.TrimSpace(instance.Spec.Region); region != "" {
+		return region
+	}
+
+	return strings.TrimSpace(instance.Spec.DefaultRegion)
+}
+
+// GetRegionID - returns the ID of the region endpoints get registered in
+func (instance KeystoneAPI) GetRegionID() string {
+	if regionID := strings.TrimSpace(instance.Spec.RegionID); regionID != "" {
+		return regionID
+	}
+
+	return instance.GetRegion()
+}
+
+// HasStatus - returns true if the controller of the KeystoneAPI populated its
+// status
+func (instance KeystoneAPI) HasStatus() bool {
+	return len(instance.Status.Conditions) > 0 || len(instance.Status.Hash) > 0
+}
+
+// GetBootstrapHash - returns the hash of the completed bootstrap from the
+// status, or from the BootstrapHashAnnotation if the status has none
+func (instance KeystoneAPI) GetBootstrapHash() string {
+	if hash := instance.Status.Hash[BootstrapHash]; hash != "" {
+		return hash
+	}
+	if BootstrapHashAnnotation == "" {
+		return ""
+	}
+
+	return instance.GetAnnotations()[BootstrapHashAnnotation]
+}
+
+// IsBootstrapped - returns true if the bootstrap of keystone completed, the
+// admin user, project and the identity service exist, or SkipBootstrapGate is
+// set
+func (instance KeystoneAPI) IsBootstrapped() bool {
+	return SkipBootstrapGate || instance.GetBootstrapHash() != ""
+}
+
 // IsReady - returns true if service is ready to server requests
 func (instance KeystoneAPI) IsReady() bool {
 	return instance.Status.Conditions.IsTrue(condition.ExposeServiceReadyCondition) &&
@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// KeystoneAPIWebhookOptions - operator level options of the KeystoneAPI webhook
+// +kubebuilder:object:generate=false
+type KeystoneAPIWebhookOptions struct {
+	// AuthCheck - whether the admin credentials of a created or changed
+	// KeystoneAPI with AuthURLs get verified by authenticating against them,
+	// one of AuthCheckReject or AuthCheckWarn, empty disables it
+	AuthCheck string
+	// VerifyAuth - authenticates with the admin credentials of the KeystoneAPI
+	VerifyAuth func(ctx context.Context, instance *KeystoneAPI) error
+}
+
+const (
+	// AuthCheckReject - a KeystoneAPI whose credentials fail is rejected
+	AuthCheckReject = "reject"
+	// AuthCheckWarn - a failing authentication is only logged
+	AuthCheckWarn = "warn"
+)
+
+// authCheckTimeout - how long the authentication may delay the admission
+const authCheckTimeout = 10 * time.Second
+
+// log is for logging in this package.
+var keystoneapilog = logf.Log.WithName("keystoneapi-resource")
+
+var keystoneAPIWebhookOptions KeystoneAPIWebhookOptions
+
+// SetupKeystoneAPIWebhookOptions - sets the options used by the KeystoneAPI webhook
+func SetupKeystoneAPIWebhookOptions(opts KeystoneAPIWebhookOptions) {
+	keystoneAPIWebhookOptions = opts
+}
+
+// SetupWebhookWithManager sets up the webhook with the Manager
+func (r *KeystoneAPI) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-keystone-openstack-org-v1beta1-keystoneapi,mutating=false,failurePolicy=fail,sideEffects=None,groups=keystone.openstack.org,resources=keystoneapis,verbs=create;update,versions=v1beta1,name=vkeystoneapi.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &KeystoneAPI{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneAPI) ValidateCreate() error {
+	keystoneapilog.Info("validate create", "name", r.Name)
+
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	return r.verifyAuth()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneAPI) ValidateUpdate(old runtime.Object) error {
+	keystoneapilog.Info("validate update", "name", r.Name)
+
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	// the reconcilers update the metadata, e.g. the finalizers, those
+	// updates must not depend on keystone being reachable
+	if oldAPI, ok := old.(*KeystoneAPI); ok && equality.Semantic.DeepEqual(oldAPI.Spec, r.Spec) {
+		return nil
+	}
+
+	return r.verifyAuth()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneAPI) ValidateDelete() error {
+	keystoneapilog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+// verifyAuth - authenticates with the admin credentials against the AuthURLs
+// if enabled by the webhook options. The public endpoint of the KeystoneAPI
+// does not exist at admission, without AuthURLs nothing gets checked.
+func (r *KeystoneAPI) verifyAuth() error {
+	opts := keystoneAPIWebhookOptions
+	if opts.AuthCheck == "" || opts.VerifyAuth == nil || len(r.Spec.AuthURLs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authCheckTimeout)
+	defer cancel()
+	err := opts.VerifyAuth(ctx, r)
+	if err == nil {
+		return nil
+	}
+	if opts.AuthCheck == AuthCheckWarn {
+		keystoneapilog.Info("authentication with the admin credentials failed", "name", r.Name, "error", err.Error())
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "KeystoneAPI"},
+		r.Name, field.ErrorList{field.Invalid(field.NewPath("spec").Child("authURLs"), r.Spec.AuthURLs,
+			fmt.Sprintf("authentication with the admin credentials failed: %s", err))})
+}
+
+// validate - rejects an AuthMode without the fields it requires
+func (r *KeystoneAPI) validate() error {
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	required := map[string]string{}
+	switch r.Spec.AuthMode {
+	case AuthModeServiceAccount:
+		required["federationIdentityProvider"] = r.Spec.FederationIdentityProvider
+	case AuthModeApplicationCredential:
+		required["applicationCredentialID"] = r.Spec.ApplicationCredentialID
+	case AuthModeOIDCPassword:
+		required["federationIdentityProvider"] = r.Spec.FederationIdentityProvider
+		required["oidcTokenEndpoint"] = r.Spec.OIDCTokenEndpoint
+		required["oidcClientID"] = r.Spec.OIDCClientID
+	}
+	for _, name := range []string{
+		"federationIdentityProvider",
+		"applicationCredentialID",
+		"oidcTokenEndpoint",
+		"oidcClientID",
+	} {
+		if value, ok := required[name]; ok && value == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child(name),
+				"required with authMode "+r.Spec.AuthMode))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "KeystoneAPI"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVerifyAuth(t *testing.T) {
+	failing := func(ctx context.Context, instance *KeystoneAPI) error {
+		return fmt.Errorf("401 Unauthorized")
+	}
+	succeeding := func(ctx context.Context, instance *KeystoneAPI) error {
+		return nil
+	}
+	authURLs := []string{"https://keystone.example.com/v3"}
+
+	tests := []struct {
+		name       string
+		authCheck  string
+		verifyAuth func(ctx context.Context, instance *KeystoneAPI) error
+		authURLs   []string
+		wantErr    bool
+	}{
+		{
+			name:       "disabled",
+			verifyAuth: failing,
+			authURLs:   authURLs,
+			wantErr:    false,
+		},
+		{
+			name:       "reject failing credentials",
+			authCheck:  AuthCheckReject,
+			verifyAuth: failing,
+			authURLs:   authURLs,
+			wantErr:    true,
+		},
+		{
+			name:       "reject with working credentials",
+			authCheck:  AuthCheckReject,
+			verifyAuth: succeeding,
+			authURLs:   authURLs,
+			wantErr:    false,
+		},
+		{
+			name:       "warn about failing credentials",
+			authCheck:  AuthCheckWarn,
+			verifyAuth: failing,
+			authURLs:   authURLs,
+			wantErr:    false,
+		},
+		{
+			name:       "without AuthURLs",
+			authCheck:  AuthCheckReject,
+			verifyAuth: failing,
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetupKeystoneAPIWebhookOptions(KeystoneAPIWebhookOptions{AuthCheck: tt.authCheck, VerifyAuth: tt.verifyAuth})
+			defer SetupKeystoneAPIWebhookOptions(KeystoneAPIWebhookOptions{})
+
+			instance := &KeystoneAPI{
+				ObjectMeta: metav1.ObjectMeta{Name: "keystone", Namespace: "openstack"},
+				Spec:       KeystoneAPISpec{AuthURLs: tt.authURLs},
+			}
+			err := instance.ValidateCreate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateVerifiesChangedSpec(t *testing.T) {
+	calls := 0
+	SetupKeystoneAPIWebhookOptions(KeystoneAPIWebhookOptions{
+		AuthCheck: AuthCheckReject,
+		VerifyAuth: func(ctx context.Context, instance *KeystoneAPI) error {
+			calls++
+			return fmt.Errorf("401 Unauthorized")
+		},
+	})
+	defer SetupKeystoneAPIWebhookOptions(KeystoneAPIWebhookOptions{})
+
+	old := &KeystoneAPI{
+		ObjectMeta: metav1.ObjectMeta{Name: "keystone", Namespace: "openstack"},
+		Spec:       KeystoneAPISpec{AuthURLs: []string{"https://keystone.example.com/v3"}},
+	}
+
+	// a metadata only update, e.g. of the finalizers
+	updated := old.DeepCopy()
+	updated.Finalizers = []string{"KeystoneAPI"}
+	if err := updated.ValidateUpdate(old); err != nil {
+		t.Errorf("ValidateUpdate() not changing the spec: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("VerifyAuth called %d times for an unchanged spec", calls)
+	}
+
+	changed := old.DeepCopy()
+	changed.Spec.AuthURLs = append(changed.Spec.AuthURLs, "https://keystone-2.example.com/v3")
+	if err := changed.ValidateUpdate(old); err == nil {
+		t.Errorf("ValidateUpdate() changing the AuthURLs with failing credentials succeeded")
+	}
+	if calls != 1 {
+		t.Errorf("VerifyAuth called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneCatalogLabel - label set on the KeystoneServices and KeystoneEndpoints
+// of a KeystoneCatalog, with the name of the KeystoneCatalog as value
+const KeystoneCatalogLabel = "keystone.openstack.org/catalog"
+
+// KeystoneCatalogSpec defines the desired state of KeystoneCatalog
+type KeystoneCatalogSpec struct {
+	// +kubebuilder:validation:Required
+	// Services - the services of the catalog. Each gets reconciled into a
+	// KeystoneService and, if it has endpoints, a KeystoneEndpoint named after
+	// the ServiceName. Services removed from the list get deleted.
+	Services []KeystoneCatalogService `json:"services"`
+}
+
+// KeystoneCatalogService - service of a KeystoneCatalog with its endpoints
+type KeystoneCatalogService struct {
+	KeystoneServiceSpec `json:",inline"`
+	// +kubebuilder:validation:Optional
+	// Endpoints - map with service api endpoint URLs with the endpoint type as index
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+}
+
+// KeystoneCatalogStatus defines the observed state of KeystoneCatalog
+type KeystoneCatalogStatus struct {
+	// ServiceIDs - IDs of the registered services with the service name as index
+	ServiceIDs map[string]string `json:"serviceIDs,omitempty"`
+	// ReadyCount - number of services which are ready together with their endpoints
+	ReadyCount int `json:"readyCount,omitempty"`
+	// ObservedGeneration - the most recent generation reconciled successfully
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions
+	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyCount",description="Ready"
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[0].status",description="Status"
+//+kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.conditions[0].message",description="Message"
+
+// KeystoneCatalog is the Schema for the keystonecatalogs API
+type KeystoneCatalog struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneCatalogSpec   `json:"spec,omitempty"`
+	Status KeystoneCatalogStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KeystoneCatalogList contains a list of KeystoneCatalog
+type KeystoneCatalogList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneCatalog `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneCatalog{}, &KeystoneCatalogList{})
+}
+
+// IsReady - returns true if all services of the catalog and their endpoints
+// are ready
+func (instance KeystoneCatalog) IsReady() bool {
+	return instance.Status.Conditions.IsTrue(KeystoneCatalogServicesReadyCondition)
+}
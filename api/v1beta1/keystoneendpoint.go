@@ -80,6 +80,9 @@ func (ke *KeystoneEndpointHelper) CreateOrPatch(
 
 	op, err := controllerutil.CreateOrPatch(ctx, h.GetClient(), endpoint, func() error {
 		endpoint.Spec = ke.endpoint.Spec
+		// apply the defaulting webhook, which would move the deprecated
+		// Endpoints, to not patch the spec on every call
+		endpoint.Spec.Default()
 		endpoint.Labels = util.MergeStringMaps(endpoint.Labels, ke.endpoint.Labels)
 
 		err := controllerutil.SetControllerReference(h.GetBeforeObject(), endpoint, h.GetScheme())
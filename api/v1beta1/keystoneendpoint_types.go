@@ -17,7 +17,10 @@ limitations under the License.
 package v1beta1
 
 import (
+	"sort"
+
 	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,15 +29,238 @@ type KeystoneEndpointSpec struct {
 	// +kubebuilder:validation:Required
 	// ServiceName - Name of the service to create the endpoint for
 	ServiceName string `json:"serviceName,omitempty"`
-	// +kubebuilder:validation:Required
-	// Endpoints - map with service api endpoint URLs with the endpoint type as index
+	// +kubebuilder:validation:Optional
+	// AdditionalServiceName - optional name of one of the AdditionalServices of the
+	// KeystoneService to create the endpoints for, instead of its main service
+	AdditionalServiceName string `json:"additionalServiceName,omitempty"`
+	// +kubebuilder:validation:Optional
+	// EndpointList - the endpoints of the service. Registered endpoints of the
+	// service for types not in the list get deleted, unless PrunePolicy is report.
+	EndpointList []EndpointSpec `json:"endpointList,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Endpoints - deprecated, use EndpointList. Map with service api endpoint URLs
+	// with the endpoint type as index, the defaulting webhook moves them into the
+	// EndpointList. An URL in the map replaces the one of the same endpoint type in
+	// the EndpointList.
 	Endpoints map[string]string `json:"endpoints,omitempty"`
+	// +kubebuilder:validation:Optional
+	// AdoptEndpointIDs - map with the IDs of already registered endpoints with the
+	// endpoint type as index. Until an endpoint type is tracked in the status, the
+	// endpoint with the given ID gets adopted instead of creating a new one.
+	AdoptEndpointIDs map[string]string `json:"adoptEndpointIDs,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ProjectEndpointScope - optional list of project IDs the endpoints get associated
+	// with using the keystone endpoint filter extension, to only show them in the
+	// catalog of these projects
+	ProjectEndpointScope []string `json:"projectEndpointScope,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=delete;report
+	// +kubebuilder:default=delete
+	// PrunePolicy - how registered endpoints of the service which are not in Endpoints
+	// are handled. With delete the endpoints of the region get deleted, with report they
+	// are only listed in Status.UndeclaredEndpoints, but the endpoint registered for an
+	// interface removed from the spec still gets deleted. Endpoints in other regions are
+	// always only reported.
+	PrunePolicy string `json:"prunePolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// PublicAliases - optional list of further endpoint types, admin or internal,
+	// which get registered with the URL of the public endpoint, to keep them in
+	// sync with it. Keystone only knows the admin, internal and public interfaces,
+	// so no custom interface names can be used. An URL for the endpoint type in
+	// Endpoints takes precedence.
+	PublicAliases []PublicAlias `json:"publicAliases,omitempty"`
+	// +kubebuilder:validation:Optional
+	// EndpointURLRefs - map with references to the Route or Service the URL of an
+	// endpoint gets resolved from with the endpoint type as index. The endpoint gets
+	// updated when the Route or Service changes. An URL for the endpoint type in
+	// Endpoints takes precedence.
+	EndpointURLRefs map[string]EndpointURLRef `json:"endpointURLRefs,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Disable
+	// +kubebuilder:default=Delete
+	// DeletionPolicy - whether the endpoints get deleted from keystone or only
+	// disabled when the KeystoneEndpoint gets deleted
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// EndpointOrder - optional list of endpoint types in the order their endpoints
+	// get created or updated in keystone, defaults to internal, admin, public.
+	// Declared endpoint types which are not in the list follow in alphabetical
+	// order. All changes get applied within one reconcile, which stops at the first
+	// failing endpoint, so an endpoint is never changed before the endpoints
+	// ordered before it.
+	EndpointOrder []string `json:"endpointOrder,omitempty"`
+	// +kubebuilder:validation:Optional
+	// URLVariablesFrom - optional ConfigMaps and Secrets whose keys, with the
+	// optional prefix, get substituted for $(KEY) references in the endpoint URLs,
+	// like for the env of a container. Keys of later sources take precedence.
+	// References to other keys get resolved from the environment of the operator.
+	// The endpoints wait while a reference can not be resolved.
+	URLVariablesFrom []corev1.EnvFromSource `json:"urlVariablesFrom,omitempty"`
+	// +kubebuilder:validation:Optional
+	// VerifyCatalog - if true, the endpoints only get ready once they are listed
+	// with their URL in the catalog keystone returns to the admin client, to
+	// catch endpoints which got registered, but are not visible to clients. With
+	// a ProjectEndpointScope the admin project needs to be one of the projects.
+	VerifyCatalog bool `json:"verifyCatalog,omitempty"`
+	// +kubebuilder:validation:Optional
+	// HealthCheck - optional reachability probe of the registered endpoints,
+	// the result per endpoint type is reported in a
+	// KeystoneEndpoint<Type>Reachable condition. A failed probe is a warning
+	// only, the endpoints stay ready.
+	HealthCheck *EndpointHealthCheck `json:"healthCheck,omitempty"`
+	// +kubebuilder:validation:Optional
+	// KeystoneAPINamespace - optional namespace of the KeystoneAPI the endpoints get
+	// registered with, for a keystone running in a central namespace. Defaults to
+	// the namespace of the KeystoneEndpoint.
+	KeystoneAPINamespace string `json:"keystoneAPINamespace,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ParentRegions - optional region hierarchy above the region of the
+	// endpoints, the root region first. The region gets created as child of the
+	// last one, each of them as child of the one before it, before the endpoints
+	// get registered. Missing parent regions get created with AutoCreateRegion of
+	// the KeystoneAPI, otherwise the endpoints wait for them. Takes precedence over
+	// the ParentRegion of the KeystoneAPI.
+	ParentRegions []string `json:"parentRegions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxRetries - optional number of failed reconciles of a generation after
+	// which the endpoints are marked Failed, independent of the MaxRetries of
+	// the KeystoneService. They then only get retried on a spec change or after
+	// the resync period. 0 retries forever.
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RetryInterval - optional interval a failed reconcile gets retried after,
+	// e.g. 10s to wait for the replication of a region. Defaults to the
+	// exponential backoff of the controller.
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+}
+
+// EndpointHealthCheck - the reachability probe of the endpoints
+type EndpointHealthCheck struct {
+	// +kubebuilder:validation:Optional
+	// Path - path appended to the endpoint URL for the probe, e.g. /healthcheck,
+	// for services whose root path is not a health endpoint
+	Path string `json:"path,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=200
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	// ExpectedStatus - HTTP status code a reachable endpoint responds with
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+}
+
+// ManagedEndpointFields - the attributes of a keystone endpoint the operator set
+type ManagedEndpointFields struct {
+	// URL - the endpoint URL
+	URL string `json:"url"`
+	// Enabled - the enabled state of the endpoint, only set if the
+	// EndpointList declares it
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// PublicAlias - an endpoint type registered with the URL of the public
+// endpoint
+// +kubebuilder:validation:Enum=admin;internal
+type PublicAlias string
+
+// EndpointSpec - an endpoint of the service
+type EndpointSpec struct {
+	// +kubebuilder:validation:Required
+	// Interface - endpoint type, e.g. admin, internal or public
+	Interface string `json:"interface"`
+	// +kubebuilder:validation:Optional
+	// URL - URL of the endpoint, may be empty if it gets resolved from the
+	// EndpointURLRefs or is an alias of the public endpoint
+	URL string `json:"url,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Region - optional region of the endpoint. The endpoints get registered in
+	// the region of the KeystoneAPI, a different region is reported as error.
+	Region string `json:"region,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Enabled - whether the endpoint is enabled in keystone, if not set the
+	// enabled state is left as it is
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// DefaultEndpointOrder - the order the endpoints get reconciled in if the
+// EndpointOrder is not set, the public endpoint gets moved last
+var DefaultEndpointOrder = []string{"internal", "admin", "public"}
+
+// EndpointURLRef - references the Route or Service the URL of an endpoint gets
+// resolved from
+type EndpointURLRef struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Route;Service
+	// Kind - Route or Service
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	// Name - name of the Route or Service in the namespace of the KeystoneEndpoint
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// Port - port of the Service, defaults to its first port. Not used for a Route.
+	Port int32 `json:"port,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=http;https
+	// Scheme - scheme of the URL, defaults to https for a Route with TLS and http
+	// otherwise
+	Scheme string `json:"scheme,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Path - path appended to the resolved URL, e.g. /v2.1
+	Path string `json:"path,omitempty"`
 }
 
+const (
+	// PrunePolicyDelete - delete undeclared endpoints
+	PrunePolicyDelete = "delete"
+
+	// PrunePolicyReport - only report undeclared endpoints
+	PrunePolicyReport = "report"
+
+	// EndpointURLRefKindRoute - the endpoint URL gets resolved from a Route
+	EndpointURLRefKindRoute = "Route"
+
+	// EndpointURLRefKindService - the endpoint URL gets resolved from a Service
+	EndpointURLRefKindService = "Service"
+)
+
 // KeystoneEndpointStatus defines the observed state of KeystoneEndpoint
 type KeystoneEndpointStatus struct {
 	EndpointIDs map[string]string `json:"endpointIDs,omitempty"`
 	ServiceID   string            `json:"serviceID,omitempty"`
+	// AuthURL - identity endpoint the admin client authenticated against
+	AuthURL string `json:"authURL,omitempty"`
+	// IdentityEndpoint - identity endpoint the admin client sends its requests to
+	IdentityEndpoint string `json:"identityEndpoint,omitempty"`
+	// IdentityAPIVersion - identity API version reported by keystone, only set
+	// if the operator requests an identity microversion
+	IdentityAPIVersion string `json:"identityAPIVersion,omitempty"`
+	// ObservedGeneration - the most recent generation reconciled successfully
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ScopedProjectIDs - project IDs the endpoints are associated with
+	ScopedProjectIDs []string `json:"scopedProjectIDs,omitempty"`
+	// Hash - hash of the spec applied by the last successful reconcile
+	Hash string `json:"hash,omitempty"`
+	// LastChangeRequestID - X-OpenStack-Request-ID of the last reconcile which
+	// created, updated or deleted something in keystone
+	LastChangeRequestID string `json:"lastChangeRequestID,omitempty"`
+	// UndeclaredEndpoints - registered endpoints of the service which are not
+	// declared in the spec
+	UndeclaredEndpoints []string `json:"undeclaredEndpoints,omitempty"`
+	// ResolvedEndpoints - endpoint URLs resolved from the Spec.EndpointURLRefs,
+	// and the Spec.Endpoints with substituted Spec.URLVariablesFrom, with the
+	// endpoint type as index
+	ResolvedEndpoints map[string]string `json:"resolvedEndpoints,omitempty"`
+	// FailedAttempts - number of consecutive failed reconciles of the
+	// FailedGeneration
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// FailedGeneration - the generation the FailedAttempts were counted for
+	FailedGeneration int64 `json:"failedGeneration,omitempty"`
+	// LastFailureTime - time of the last failed reconcile
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// ManagedFields - the attributes of the endpoints the last reconcile set,
+	// with the endpoint type as index. Attributes not listed, e.g. the enabled
+	// state of an endpoint without a declared one, are left to other tools.
+	ManagedFields map[string]ManagedEndpointFields `json:"managedFields,omitempty"`
 	// Conditions
 	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
 }
@@ -70,3 +296,115 @@ func init() {
 func (instance KeystoneEndpoint) IsReady() bool {
 	return instance.Status.Conditions.IsTrue(KeystoneServiceOSEndpointsReadyCondition)
 }
+
+// GetEndpoints - returns the endpoint URLs to register with the endpoint type
+// as index, Spec.Endpoints with their variables substituted, extended by the
+// URLs resolved from the Spec.EndpointURLRefs and the Spec.PublicAliases
+func (instance KeystoneEndpoint) GetEndpoints() map[string]string {
+	declared := instance.GetDeclaredEndpoints()
+	if len(instance.Status.ResolvedEndpoints) == 0 && len(instance.Spec.PublicAliases) == 0 {
+		return declared
+	}
+
+	endpoints := make(map[string]string, len(declared)+len(instance.Spec.PublicAliases))
+	for endpointType, endpointURL := range declared {
+		endpoints[endpointType] = endpointURL
+	}
+	// the resolved URLs of Spec.Endpoints are the ones with substituted variables
+	for endpointType, endpointURL := range instance.Status.ResolvedEndpoints {
+		endpoints[endpointType] = endpointURL
+	}
+
+	publicURL, ok := endpoints["public"]
+	if !ok {
+		return endpoints
+	}
+	for _, alias := range instance.Spec.PublicAliases {
+		if _, ok := endpoints[string(alias)]; !ok {
+			endpoints[string(alias)] = publicURL
+		}
+	}
+
+	return endpoints
+}
+
+// GetDeclaredEndpoints - returns the URLs of the Spec.EndpointList and the
+// deprecated Spec.Endpoints with the endpoint type as index. Entries of the
+// EndpointList without an URL are left out.
+func (instance KeystoneEndpoint) GetDeclaredEndpoints() map[string]string {
+	if len(instance.Spec.EndpointList) == 0 {
+		return instance.Spec.Endpoints
+	}
+
+	endpoints := make(map[string]string, len(instance.Spec.EndpointList)+len(instance.Spec.Endpoints))
+	for _, e := range instance.Spec.EndpointList {
+		if e.URL != "" {
+			endpoints[e.Interface] = e.URL
+		}
+	}
+	for endpointType, endpointURL := range instance.Spec.Endpoints {
+		endpoints[endpointType] = endpointURL
+	}
+
+	return endpoints
+}
+
+// GetEndpointSpec - returns the entry of the Spec.EndpointList for the
+// endpoint type, and false if there is none
+func (instance KeystoneEndpoint) GetEndpointSpec(endpointType string) (EndpointSpec, bool) {
+	for _, e := range instance.Spec.EndpointList {
+		if e.Interface == endpointType {
+			return e, true
+		}
+	}
+
+	return EndpointSpec{}, false
+}
+
+// GetEndpointOrder - returns the endpoint types of endpoints in the order
+// they get reconciled in
+func (instance KeystoneEndpoint) GetEndpointOrder(endpoints map[string]string) []string {
+	order := instance.Spec.EndpointOrder
+	if len(order) == 0 {
+		order = DefaultEndpointOrder
+	}
+
+	ordered := make([]string, 0, len(endpoints))
+	seen := make(map[string]bool, len(endpoints))
+	for _, endpointType := range order {
+		if _, ok := endpoints[endpointType]; ok && !seen[endpointType] {
+			ordered = append(ordered, endpointType)
+			seen[endpointType] = true
+		}
+	}
+
+	rest := []string{}
+	for endpointType := range endpoints {
+		if !seen[endpointType] {
+			rest = append(rest, endpointType)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(ordered, rest...)
+}
+
+// GetCatalogServiceName - returns the name of the service in the keystone
+// catalog the endpoints belong to
+func (instance KeystoneEndpoint) GetCatalogServiceName() string {
+	if instance.Spec.AdditionalServiceName != "" {
+		return instance.Spec.AdditionalServiceName
+	}
+
+	return instance.Spec.ServiceName
+}
+
+// GetKeystoneAPINamespace - returns the namespace of the KeystoneAPI the endpoints get
+// registered with
+func (instance KeystoneEndpoint) GetKeystoneAPINamespace() string {
+	if instance.Spec.KeystoneAPINamespace != "" {
+		return instance.Spec.KeystoneAPINamespace
+	}
+
+	return instance.Namespace
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"net/url"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// KeystoneEndpointWebhookOptions - operator level options of the KeystoneEndpoint webhook
+// +kubebuilder:object:generate=false
+type KeystoneEndpointWebhookOptions struct {
+	// RequireHTTPS - endpoint types, e.g. admin or public, which must use an https URL
+	RequireHTTPS []string
+}
+
+// log is for logging in this package.
+var keystoneendpointlog = logf.Log.WithName("keystoneendpoint-resource")
+
+var keystoneEndpointWebhookOptions KeystoneEndpointWebhookOptions
+
+// SetupKeystoneEndpointWebhookOptions - sets the options used by the KeystoneEndpoint webhook
+func SetupKeystoneEndpointWebhookOptions(opts KeystoneEndpointWebhookOptions) {
+	keystoneEndpointWebhookOptions = opts
+}
+
+// SetupWebhookWithManager sets up the webhook with the Manager
+func (r *KeystoneEndpoint) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-keystone-openstack-org-v1beta1-keystoneendpoint,mutating=true,failurePolicy=fail,sideEffects=None,groups=keystone.openstack.org,resources=keystoneendpoints,verbs=create;update,versions=v1beta1,name=mkeystoneendpoint.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &KeystoneEndpoint{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *KeystoneEndpoint) Default() {
+	keystoneendpointlog.Info("default", "name", r.Name)
+
+	r.Spec.Default()
+}
+
+// Default - moves the URLs of the deprecated Endpoints into the EndpointList.
+// An URL replaces the one of the entry with the same endpoint type, new
+// entries get appended sorted by endpoint type.
+func (spec *KeystoneEndpointSpec) Default() {
+	if len(spec.Endpoints) == 0 {
+		return
+	}
+
+	endpointTypes := make([]string, 0, len(spec.Endpoints))
+	for endpointType := range spec.Endpoints {
+		endpointTypes = append(endpointTypes, endpointType)
+	}
+	sort.Strings(endpointTypes)
+
+	for _, endpointType := range endpointTypes {
+		found := false
+		for i := range spec.EndpointList {
+			if spec.EndpointList[i].Interface == endpointType {
+				spec.EndpointList[i].URL = spec.Endpoints[endpointType]
+				found = true
+			}
+		}
+		if !found {
+			spec.EndpointList = append(spec.EndpointList, EndpointSpec{
+				Interface: endpointType,
+				URL:       spec.Endpoints[endpointType],
+			})
+		}
+	}
+	spec.Endpoints = nil
+}
+
+//+kubebuilder:webhook:path=/validate-keystone-openstack-org-v1beta1-keystoneendpoint,mutating=false,failurePolicy=fail,sideEffects=None,groups=keystone.openstack.org,resources=keystoneendpoints,verbs=create;update,versions=v1beta1,name=vkeystoneendpoint.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &KeystoneEndpoint{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneEndpoint) ValidateCreate() error {
+	keystoneendpointlog.Info("validate create", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneEndpoint) ValidateUpdate(old runtime.Object) error {
+	keystoneendpointlog.Info("validate update", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneEndpoint) ValidateDelete() error {
+	keystoneendpointlog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+// validate - rejects endpoint types listed more than once in the
+// EndpointList and public aliases which are no keystone interface, and
+// validates the endpoint URLs against the webhook options
+func (r *KeystoneEndpoint) validate() error {
+	var allErrs field.ErrorList
+
+	listPath := field.NewPath("spec").Child("endpointList")
+	paths := map[string]*field.Path{}
+	for i, e := range r.Spec.EndpointList {
+		if _, ok := paths[e.Interface]; ok {
+			allErrs = append(allErrs, field.Duplicate(listPath.Index(i).Child("interface"), e.Interface))
+			continue
+		}
+		paths[e.Interface] = listPath.Index(i).Child("url")
+	}
+
+	// keystone only knows the admin, internal and public interfaces
+	for i, alias := range r.Spec.PublicAliases {
+		if alias != "admin" && alias != "internal" {
+			allErrs = append(allErrs, field.NotSupported(field.NewPath("spec").Child("publicAliases").Index(i),
+				alias, []string{"admin", "internal"}))
+		}
+	}
+
+	endpoints := r.GetEndpoints()
+	for _, endpointType := range keystoneEndpointWebhookOptions.RequireHTTPS {
+		endpointURL, ok := endpoints[endpointType]
+		if !ok {
+			continue
+		}
+		path, ok := paths[endpointType]
+		if _, legacy := r.Spec.Endpoints[endpointType]; legacy || !ok {
+			path = field.NewPath("spec").Child("endpoints").Key(endpointType)
+		}
+
+		u, err := url.Parse(endpointURL)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path, endpointURL, err.Error()))
+			continue
+		}
+		if u.Scheme != "https" {
+			allErrs = append(allErrs, field.Invalid(path, endpointURL, "the "+endpointType+" endpoint is required to use https"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "KeystoneEndpoint"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newKeystoneEndpoint(spec KeystoneEndpointSpec) *KeystoneEndpoint {
+	return &KeystoneEndpoint{
+		ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+		Spec:       spec,
+	}
+}
+
+func TestValidatePublicAliases(t *testing.T) {
+	public := []EndpointSpec{{Interface: "public", URL: "https://placement.example.com"}}
+
+	tests := []struct {
+		name    string
+		aliases []PublicAlias
+		wantErr bool
+	}{
+		{
+			name:    "no aliases",
+			wantErr: false,
+		},
+		{
+			name:    "admin and internal",
+			aliases: []PublicAlias{"admin", "internal"},
+			wantErr: false,
+		},
+		{
+			name:    "public",
+			aliases: []PublicAlias{"public"},
+			wantErr: true,
+		},
+		{
+			name:    "custom interface",
+			aliases: []PublicAlias{"internal", "cdn"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newKeystoneEndpoint(KeystoneEndpointSpec{EndpointList: public, PublicAliases: tt.aliases})
+			err := instance.ValidateCreate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetEndpointsPublicAliases(t *testing.T) {
+	instance := newKeystoneEndpoint(KeystoneEndpointSpec{
+		EndpointList: []EndpointSpec{
+			{Interface: "public", URL: "https://placement.example.com"},
+			{Interface: "internal", URL: "http://placement.openstack.svc"},
+		},
+		PublicAliases: []PublicAlias{"admin", "internal"},
+	})
+
+	endpoints := instance.GetEndpoints()
+	if endpoints["admin"] != "https://placement.example.com" {
+		t.Errorf("admin endpoint = %q, want the public URL", endpoints["admin"])
+	}
+	if endpoints["internal"] != "http://placement.openstack.svc" {
+		t.Errorf("internal endpoint = %q, want the declared URL", endpoints["internal"])
+	}
+}
+
+func TestValidateRequireHTTPS(t *testing.T) {
+	SetupKeystoneEndpointWebhookOptions(KeystoneEndpointWebhookOptions{RequireHTTPS: []string{"public", "admin"}})
+	defer SetupKeystoneEndpointWebhookOptions(KeystoneEndpointWebhookOptions{})
+
+	tests := []struct {
+		name      string
+		spec      KeystoneEndpointSpec
+		wantField string
+	}{
+		{
+			name: "https public endpoint",
+			spec: KeystoneEndpointSpec{EndpointList: []EndpointSpec{
+				{Interface: "public", URL: "https://placement.example.com"},
+				{Interface: "internal", URL: "http://placement.openstack.svc:8778"},
+			}},
+		},
+		{
+			name: "http public endpoint",
+			spec: KeystoneEndpointSpec{EndpointList: []EndpointSpec{
+				{Interface: "internal", URL: "http://placement.openstack.svc:8778"},
+				{Interface: "public", URL: "http://placement.example.com"},
+			}},
+			wantField: "spec.endpointList[1].url",
+		},
+		{
+			name: "http public endpoint in the deprecated Endpoints",
+			spec: KeystoneEndpointSpec{Endpoints: map[string]string{
+				"public": "http://placement.example.com",
+			}},
+			wantField: "spec.endpoints[public]",
+		},
+		{
+			name: "admin alias of an http public endpoint",
+			spec: KeystoneEndpointSpec{
+				EndpointList:  []EndpointSpec{{Interface: "public", URL: "http://placement.example.com"}},
+				PublicAliases: []PublicAlias{"admin"},
+			},
+			wantField: "spec.endpoints[admin]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newKeystoneEndpoint(tt.spec).ValidateCreate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("ValidateCreate() error = %v, want nil", err)
+				}
+				return
+			}
+			statusErr, ok := err.(*apierrors.StatusError)
+			if !ok {
+				t.Fatalf("ValidateCreate() error = %v, want an invalid error", err)
+			}
+			for _, cause := range statusErr.Status().Details.Causes {
+				if cause.Field == tt.wantField {
+					return
+				}
+			}
+			t.Errorf("ValidateCreate() error = %v, want an error for %s", err, tt.wantField)
+		})
+	}
+}
+
+func TestGetEndpointOrder(t *testing.T) {
+	endpoints := map[string]string{
+		"public":   "https://placement.example.com",
+		"internal": "http://placement.openstack.svc:8778",
+		"admin":    "http://placement.openstack.svc:8778",
+	}
+
+	tests := []struct {
+		name      string
+		order     []string
+		endpoints map[string]string
+		want      []string
+	}{
+		{
+			name:      "default order",
+			endpoints: endpoints,
+			want:      []string{"internal", "admin", "public"},
+		},
+		{
+			name:      "configured order",
+			order:     []string{"public", "admin", "internal"},
+			endpoints: endpoints,
+			want:      []string{"public", "admin", "internal"},
+		},
+		{
+			name:      "types not in the order follow alphabetically",
+			order:     []string{"public"},
+			endpoints: endpoints,
+			want:      []string{"public", "admin", "internal"},
+		},
+		{
+			name:      "undeclared and duplicate types in the order",
+			order:     []string{"internal", "admin", "internal"},
+			endpoints: map[string]string{"internal": "http://placement.openstack.svc:8778"},
+			want:      []string{"internal"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newKeystoneEndpoint(KeystoneEndpointSpec{EndpointOrder: tt.order})
+			got := instance.GetEndpointOrder(tt.endpoints)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetEndpointOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneRoleSpec defines the desired state of KeystoneRole
+type KeystoneRoleSpec struct {
+	// +kubebuilder:validation:Required
+	// RoleName - Name of the role, it gets created if it does not exist
+	RoleName string `json:"roleName"`
+
+	// +kubebuilder:validation:Optional
+	// ImpliedRoles - names of the roles the role implies. A user assigned the
+	// role also gets the implied roles. The implied roles get created if they
+	// do not exist. Inference rules removed from the list get deleted, rules
+	// created out-of-band are kept.
+	ImpliedRoles []string `json:"impliedRoles,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// KeystoneAPINamespace - optional namespace of the KeystoneAPI the role gets
+	// registered with, for a keystone running in a central namespace. Defaults to
+	// the namespace of the KeystoneRole.
+	KeystoneAPINamespace string `json:"keystoneAPINamespace,omitempty"`
+}
+
+// KeystoneRoleStatus defines the observed state of KeystoneRole
+type KeystoneRoleStatus struct {
+	// RoleID - ID of the role
+	RoleID string `json:"roleID,omitempty"`
+	// ImpliedRoleIDs - IDs of the implied roles the inference rules got
+	// created for, with the role name as index
+	ImpliedRoleIDs map[string]string `json:"impliedRoleIDs,omitempty"`
+	// ObservedGeneration - the most recent generation reconciled successfully
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions
+	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.roleName",description="Role"
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[0].status",description="Status"
+//+kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.conditions[0].message",description="Message"
+
+// KeystoneRole is the Schema for the keystoneroles API
+type KeystoneRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneRoleSpec   `json:"spec,omitempty"`
+	Status KeystoneRoleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KeystoneRoleList contains a list of KeystoneRole
+type KeystoneRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneRole{}, &KeystoneRoleList{})
+}
+
+// IsReady - returns true if the role and its inference rules got reconciled
+func (instance KeystoneRole) IsReady() bool {
+	return instance.Status.Conditions.IsTrue(KeystoneRoleReadyCondition)
+}
+
+// GetKeystoneAPINamespace - returns the namespace of the KeystoneAPI the role gets
+// registered with
+func (instance KeystoneRole) GetKeystoneAPINamespace() string {
+	if instance.Spec.KeystoneAPINamespace != "" {
+		return instance.Spec.KeystoneAPINamespace
+	}
+
+	return instance.Namespace
+}
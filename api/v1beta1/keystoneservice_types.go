@@ -44,11 +44,307 @@ type KeystoneServiceSpec struct {
 	// +kubebuilder:validation:Required
 	// PasswordSelector - Selector to get the ServiceUser password from the Secret, e.g. PlacementPassword
 	PasswordSelector string `json:"passwordSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	// AdditionalServices - optional list of further services registered by this
+	// KeystoneService, e.g. for components providing more than one service type.
+	// Their IDs are tracked by index in Status.AdditionalServiceIDs.
+	AdditionalServices []KeystoneServiceDefinition `json:"additionalServices,omitempty"`
+	// +kubebuilder:validation:Optional
+	// PropagateEnabledToEndpoints - disable the endpoints of the services in all
+	// regions while Enabled is false, and enable them again when it is true
+	PropagateEnabledToEndpoints bool `json:"propagateEnabledToEndpoints,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=All;Propagated
+	// +kubebuilder:default=All
+	// EndpointReenablePolicy - which endpoints get enabled again with
+	// PropagateEnabledToEndpoints once the services get enabled. All enables all
+	// endpoints of the services, Propagated only the ones the propagation
+	// disabled, keeping the endpoints disabled by other means disabled.
+	EndpointReenablePolicy string `json:"endpointReenablePolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Adopt;Fail;Rename
+	// +kubebuilder:default=Adopt
+	// ConflictPolicy - how a service already registered in keystone for the type
+	// and name is handled when the KeystoneService registers it the first time.
+	// Adopt takes it over, Fail reports an error and Rename registers a separate
+	// service with the namespace appended to the name.
+	ConflictPolicy string `json:"conflictPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Disable
+	// +kubebuilder:default=Delete
+	// DeletionPolicy - whether the services get deleted from keystone or only
+	// disabled when the KeystoneService gets deleted. With Disable the service
+	// user is kept as well.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default={managed-by-keystone-operator}
+	// Tags - tags of the services in keystone. Keystone has no tag API for
+	// services, they are kept in the tags attribute of the services, which gets
+	// reconciled to exactly this list.
+	Tags []string `json:"tags,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Domain - optional name of the domain the services get associated with, for
+	// keystone setups with domain scoped services. The domain ID is kept in the
+	// domain_id attribute of the services.
+	Domain string `json:"domain,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxRetries - optional number of failed reconciles of a generation after
+	// which the service is marked Failed. It then only gets retried on a spec
+	// change or after the resync period. 0 retries forever.
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+	// +kubebuilder:validation:Optional
+	// LocalizedDescriptions - optional descriptions of the service by locale,
+	// e.g. de, kept in the description_<locale> attributes of the service.
+	// Other description_<locale> attributes, set out-of-band or of locales
+	// removed from the map, are left as they are.
+	LocalizedDescriptions map[string]string `json:"localizedDescriptions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Backend - optional Deployment or StatefulSet serving the services. With
+	// Enabled set the services only get enabled while it is ready, and disabled
+	// while it is not, to not advertise a service nobody answers.
+	Backend *BackendRef `json:"backend,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Server;Client;Type
+	// +kubebuilder:default=Server
+	// NameLookup - how a service is looked up by its name, which keystone keeps
+	// in the extra attributes of the service. Server has keystone filter the
+	// services by name, Client lists the services of the type and matches the
+	// name in the operator, for keystone versions which do not filter by name.
+	// Type ignores the name and uses the service of the type with endpoints in
+	// the region, for catalogs with a single service per type and region. The
+	// ConflictPolicy applies to it, Rename is rejected as a conflict.
+	NameLookup string `json:"nameLookup,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Region - optional region the service gets reconciled in, the admin client
+	// authenticates in it. Defaults to the region in the status of the
+	// KeystoneAPI.
+	Region string `json:"region,omitempty"`
+	// +kubebuilder:validation:Optional
+	// KeystoneAPINamespace - optional namespace of the KeystoneAPI the services get
+	// registered with, for a keystone running in a central namespace. Defaults to
+	// the namespace of the KeystoneService.
+	KeystoneAPINamespace string `json:"keystoneAPINamespace,omitempty"`
+	// +kubebuilder:validation:Optional
+	// EndpointGroups - optional endpoint groups of the endpoint filter
+	// extension over the endpoints of the service, associated with projects to
+	// customize their catalog
+	EndpointGroups []EndpointGroupSpec `json:"endpointGroups,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	// ManageService - whether the operator creates and updates the services in
+	// keystone. If false the services, registered by another tool, are only
+	// looked up by Status.ServiceID or the NameLookup for their endpoints to
+	// get reconciled. They are neither updated nor disabled or deleted with
+	// the KeystoneService.
+	ManageService *bool `json:"manageService,omitempty"`
+	// +kubebuilder:validation:Optional
+	// DependsOn - optional names of KeystoneServices of the namespace which
+	// have to be ready before the services get reconciled, e.g. to register a
+	// service after the ones it relies on
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=64
+	// ServiceID - optional ID to register the service with, for the same ID
+	// across rebuilds of keystone. It is only used when the service gets
+	// created, keystone versions which assign the IDs themselves ignore it.
+	ServiceID string `json:"serviceID,omitempty"`
+}
+
+const (
+	// ConflictPolicyAdopt - take over an already registered service
+	ConflictPolicyAdopt = "Adopt"
+
+	// ConflictPolicyFail - do not register a service which is already registered
+	ConflictPolicyFail = "Fail"
+
+	// ConflictPolicyRename - register the service with the namespace appended to the name
+	ConflictPolicyRename = "Rename"
+
+	// DeletionPolicyDelete - delete the keystone resources on deletion of the CR
+	DeletionPolicyDelete = "Delete"
+
+	// DeletionPolicyDisable - only disable the keystone resources on deletion of the CR
+	DeletionPolicyDisable = "Disable"
+
+	// BackendKindDeployment - the backend is a Deployment
+	BackendKindDeployment = "Deployment"
+
+	// BackendKindStatefulSet - the backend is a StatefulSet
+	BackendKindStatefulSet = "StatefulSet"
+
+	// NameLookupServer - keystone filters the services by name
+	NameLookupServer = "Server"
+
+	// NameLookupClient - the operator matches the name of the services of the type
+	NameLookupClient = "Client"
+
+	// NameLookupType - the service of the type in the region is used whatever its name
+	NameLookupType = "Type"
+
+	// EndpointReenablePolicyAll - enable all endpoints with the services
+	EndpointReenablePolicyAll = "All"
+
+	// EndpointReenablePolicyPropagated - only enable the endpoints the propagation disabled
+	EndpointReenablePolicyPropagated = "Propagated"
+)
+
+// BackendRef - reference to the workload in the namespace of the
+// KeystoneService serving its services
+type BackendRef struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// Kind - kind of the workload
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	// Name - name of the workload
+	Name string `json:"name"`
+}
+
+// ManagedServiceFields - the attributes of a keystone service the operator set
+type ManagedServiceFields struct {
+	// Type - the service type
+	Type string `json:"type"`
+	// Name - the service name
+	Name string `json:"name"`
+	// Description - the service description
+	Description string `json:"description,omitempty"`
+	// Enabled - the enabled state of the service
+	Enabled bool `json:"enabled"`
+	// Tags - the tags of the service, tags set by other tools are not listed
+	Tags []string `json:"tags,omitempty"`
+}
+
+// EndpointGroupSpec - endpoint group over the endpoints of a KeystoneService
+type EndpointGroupSpec struct {
+	// +kubebuilder:validation:Required
+	// Name - name of the endpoint group in keystone
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// Description - description of the endpoint group
+	Description string `json:"description,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=public;internal;admin
+	// Interface - optional interface of the endpoints in the group
+	Interface string `json:"interface,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Region - optional region of the endpoints in the group
+	Region string `json:"region,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ProjectIDs - IDs of the projects the endpoint group gets associated with.
+	// Associations created out-of-band are kept.
+	ProjectIDs []string `json:"projectIDs,omitempty"`
+}
+
+// EndpointGroupStatus - endpoint group the operator reconciled
+type EndpointGroupStatus struct {
+	// ID - ID of the endpoint group in keystone
+	ID string `json:"id"`
+	// ProjectIDs - IDs of the projects the operator associated the endpoint
+	// group with
+	ProjectIDs []string `json:"projectIDs,omitempty"`
+}
+
+// ManagedCatalogService - a service of a KeystoneService with its endpoints
+type ManagedCatalogService struct {
+	// ID - ID of the service in keystone
+	ID string `json:"id"`
+	// Type - type of the service
+	Type string `json:"type"`
+	// Name - name of the service
+	Name string `json:"name"`
+	// Endpoints - the endpoints of the service in all regions, sorted by
+	// interface and region
+	Endpoints []ManagedCatalogEndpoint `json:"endpoints,omitempty"`
+}
+
+// ManagedCatalogEndpoint - an endpoint of a service in keystone
+type ManagedCatalogEndpoint struct {
+	// ID - ID of the endpoint in keystone
+	ID string `json:"id"`
+	// Interface - interface of the endpoint, e.g. public
+	Interface string `json:"interface"`
+	// Region - region of the endpoint
+	Region string `json:"region,omitempty"`
+	// URL - URL of the endpoint
+	URL string `json:"url"`
+	// Enabled - whether the endpoint is enabled
+	Enabled bool `json:"enabled"`
+}
+
+// KeystoneServiceDefinition - additional service registered by a KeystoneService
+type KeystoneServiceDefinition struct {
+	// +kubebuilder:validation:Required
+	// ServiceType - Type is the type of the service.
+	ServiceType string `json:"serviceType"`
+	// +kubebuilder:validation:Required
+	// ServiceName - Name of the service.
+	ServiceName string `json:"serviceName"`
+	// +kubebuilder:validation:Optional
+	// ServiceDescription - Description for the service.
+	ServiceDescription string `json:"serviceDescription,omitempty"`
 }
 
 // KeystoneServiceStatus defines the observed state of KeystoneService
 type KeystoneServiceStatus struct {
 	ServiceID string `json:"serviceID,omitempty"`
+	// AdditionalServiceIDs - IDs of the Spec.AdditionalServices, by index
+	AdditionalServiceIDs []string `json:"additionalServiceIDs,omitempty"`
+	// UnconfirmedServiceSince - when a created service, tracked by its ID,
+	// was first not visible in keystone. Until the grace period passed it is
+	// looked up again instead of getting created again.
+	UnconfirmedServiceSince *metav1.Time `json:"unconfirmedServiceSince,omitempty"`
+	// AdoptedServices - names of the services which were already registered in
+	// keystone and got adopted
+	AdoptedServices []string `json:"adoptedServices,omitempty"`
+	// DomainID - ID of the Spec.Domain the services are associated with
+	DomainID string `json:"domainID,omitempty"`
+	// AuthURL - identity endpoint the admin client authenticated against
+	AuthURL string `json:"authURL,omitempty"`
+	// IdentityEndpoint - identity endpoint the admin client sends its requests to
+	IdentityEndpoint string `json:"identityEndpoint,omitempty"`
+	// IdentityAPIVersion - identity API version reported by keystone, only set
+	// if the operator requests an identity microversion
+	IdentityAPIVersion string `json:"identityAPIVersion,omitempty"`
+	// ObservedGeneration - the most recent generation reconciled successfully
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Hash - hash of the spec applied by the last successful reconcile. While it
+	// matches the spec of a ready service, the keystone requests are skipped
+	// until the resync period passed.
+	Hash string `json:"hash,omitempty"`
+	// LastSyncTime - time of the last successful reconcile against keystone
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// LastChangeRequestID - X-OpenStack-Request-ID of the last reconcile which
+	// created, updated or deleted something in keystone
+	LastChangeRequestID string `json:"lastChangeRequestID,omitempty"`
+	// FailedAttempts - number of consecutive failed reconciles of the
+	// FailedGeneration
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// FailedGeneration - the generation the FailedAttempts were counted for
+	FailedGeneration int64 `json:"failedGeneration,omitempty"`
+	// LastFailureTime - time of the last failed reconcile
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// BackendReady - whether the Spec.Backend was ready at the last reconcile,
+	// only set with a Spec.Backend
+	BackendReady *bool `json:"backendReady,omitempty"`
+	// NameLookup - the Spec.NameLookup behavior the last reconcile looked up
+	// the services with
+	NameLookup string `json:"nameLookup,omitempty"`
+	// Region - the region the last reconcile ran in
+	Region string `json:"region,omitempty"`
+	// ManagedFields - the attributes of the keystone service the last
+	// reconcile set. Only the tags listed are removed from the service once the
+	// spec has none, tags set by other tools are kept.
+	ManagedFields *ManagedServiceFields `json:"managedFields,omitempty"`
+	// PropagationDisabledEndpointIDs - IDs of the endpoints which got disabled
+	// with the services by PropagateEnabledToEndpoints and are not enabled
+	// again yet
+	PropagationDisabledEndpointIDs []string `json:"propagationDisabledEndpointIDs,omitempty"`
+	// EndpointGroups - the Spec.EndpointGroups reconciled, by name
+	EndpointGroups map[string]EndpointGroupStatus `json:"endpointGroups,omitempty"`
+	// ManagedCatalog - the services and their endpoints in keystone as the
+	// last successful reconcile found them
+	ManagedCatalog []ManagedCatalogService `json:"managedCatalog,omitempty"`
 	// Conditions
 	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
 }
@@ -88,3 +384,66 @@ func (instance KeystoneService) IsReady() bool {
 		instance.Status.Conditions.IsTrue(KeystoneServiceOSUserReadyCondition) &&
 		instance.Status.ServiceID != ""
 }
+
+// GetKeystoneAPINamespace - returns the namespace of the KeystoneAPI the services get
+// registered with
+func (instance KeystoneService) GetKeystoneAPINamespace() string {
+	if instance.Spec.KeystoneAPINamespace != "" {
+		return instance.Spec.KeystoneAPINamespace
+	}
+
+	return instance.Namespace
+}
+
+// GetServiceID - returns the ID of the service with serviceName, which is
+// either the main service or one of the AdditionalServices. Empty if the
+// service is unknown or not yet registered.
+func (instance KeystoneService) GetServiceID(serviceName string) string {
+	if serviceName == "" || serviceName == instance.Spec.ServiceName {
+		return instance.Status.ServiceID
+	}
+
+	for i, svc := range instance.Spec.AdditionalServices {
+		if svc.ServiceName == serviceName && i < len(instance.Status.AdditionalServiceIDs) {
+			return instance.Status.AdditionalServiceIDs[i]
+		}
+	}
+
+	return ""
+}
+
+// IsEnabled - returns true if the services should be enabled in keystone,
+// Spec.Enabled is set and, with a Spec.Backend, the backend is ready
+func (instance KeystoneService) IsEnabled() bool {
+	if instance.Spec.Backend == nil {
+		return instance.Spec.Enabled
+	}
+
+	return instance.Spec.Enabled && instance.Status.BackendReady != nil && *instance.Status.BackendReady
+}
+
+// IsServiceManaged - returns Spec.ManageService, true if not set
+func (instance KeystoneService) IsServiceManaged() bool {
+	return instance.Spec.ManageService == nil || *instance.Spec.ManageService
+}
+
+// GetNameLookup - returns the Spec.NameLookup, NameLookupServer if not set
+func (instance KeystoneService) GetNameLookup() string {
+	if instance.Spec.NameLookup == "" {
+		return NameLookupServer
+	}
+
+	return instance.Spec.NameLookup
+}
+
+// GetServiceDefinitions - returns the main and the additional services the
+// KeystoneService registers
+func (instance KeystoneService) GetServiceDefinitions() []KeystoneServiceDefinition {
+	definitions := []KeystoneServiceDefinition{{
+		ServiceType:        instance.Spec.ServiceType,
+		ServiceName:        instance.Spec.ServiceName,
+		ServiceDescription: instance.Spec.ServiceDescription,
+	}}
+
+	return append(definitions, instance.Spec.AdditionalServices...)
+}
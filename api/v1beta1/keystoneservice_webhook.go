@@ -0,0 +1,274 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var keystoneservicelog = logf.Log.WithName("keystoneservice-resource")
+
+// keystoneServiceWebhookClient - used to look up the other KeystoneServices
+var keystoneServiceWebhookClient client.Reader
+
+// SetupWebhookWithManager sets up the webhook with the Manager
+func (r *KeystoneService) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	keystoneServiceWebhookClient = mgr.GetClient()
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-keystone-openstack-org-v1beta1-keystoneservice,mutating=false,failurePolicy=fail,sideEffects=None,groups=keystone.openstack.org,resources=keystoneservices,verbs=create;update,versions=v1beta1,name=vkeystoneservice.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &KeystoneService{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneService) ValidateCreate() error {
+	keystoneservicelog.Info("validate create", "name", r.Name)
+
+	return r.validate(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneService) ValidateUpdate(old runtime.Object) error {
+	keystoneservicelog.Info("validate update", "name", r.Name)
+
+	// a service getting deleted, e.g. the controller removing its finalizer,
+	// must not get stuck on an overlap with another service
+	if !r.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	oldService, ok := old.(*KeystoneService)
+	if !ok {
+		return apierrors.NewInternalError(fmt.Errorf("expected a KeystoneService, got %T", old))
+	}
+
+	return r.validate(oldService)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneService) ValidateDelete() error {
+	keystoneservicelog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+// validate - rejects services which are already managed by another
+// KeystoneService registering with the same KeystoneAPI, and dependency
+// cycles. On an update, old is the previous version of the service and only
+// the checks of changed fields run, so a pre-existing overlap does not
+// reject unrelated updates.
+func (r *KeystoneService) validate(old *KeystoneService) error {
+	if keystoneServiceWebhookClient == nil {
+		return nil
+	}
+
+	checkUnique := old == nil || !reflect.DeepEqual(old.getServiceKeys(""), r.getServiceKeys("")) ||
+		old.GetKeystoneAPINamespace() != r.GetKeystoneAPINamespace() ||
+		old.GetNameLookup() != r.GetNameLookup()
+	checkDependencies := old == nil || !reflect.DeepEqual(old.Spec.DependsOn, r.Spec.DependsOn)
+	if !checkUnique && !checkDependencies {
+		return nil
+	}
+
+	// the services of all namespaces, which register with the KeystoneAPI
+	// of the KeystoneAPINamespace
+	services := &KeystoneServiceList{}
+	err := keystoneServiceWebhookClient.List(context.TODO(), services)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+
+	if checkUnique {
+		region, err := getKeystoneAPIRegion(context.TODO(), r.GetKeystoneAPINamespace())
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		others := []KeystoneService{}
+		for _, other := range services.Items {
+			if other.GetKeystoneAPINamespace() == r.GetKeystoneAPINamespace() {
+				others = append(others, other)
+			}
+		}
+		if err := r.validateUnique(others, region); err != nil {
+			return err
+		}
+	}
+
+	if !checkDependencies {
+		return nil
+	}
+	others := []KeystoneService{}
+	for _, other := range services.Items {
+		if other.Namespace == r.Namespace {
+			others = append(others, other)
+		}
+	}
+
+	return r.validateDependencies(others)
+}
+
+// getKeystoneAPIRegion - returns the region of the KeystoneAPI in namespace
+// the services default to, empty if there is no single KeystoneAPI
+func getKeystoneAPIRegion(ctx context.Context, namespace string) (string, error) {
+	keystoneAPIs := &KeystoneAPIList{}
+	err := keystoneServiceWebhookClient.List(ctx, keystoneAPIs, client.InNamespace(namespace))
+	if err != nil {
+		return "", err
+	}
+	if len(keystoneAPIs.Items) != 1 {
+		return "", nil
+	}
+	if region := keystoneAPIs.Items[0].Status.Region; region != "" {
+		return region, nil
+	}
+
+	return keystoneAPIs.Items[0].GetRegion(), nil
+}
+
+// serviceKey - a service type and name in a region
+type serviceKey struct {
+	ServiceType string
+	ServiceName string
+	Region      string
+}
+
+// getServiceKeys - returns the keys of the service and the additional
+// services, in the Spec.Region or defaultRegion
+func (r *KeystoneService) getServiceKeys(defaultRegion string) []serviceKey {
+	region := strings.TrimSpace(r.Spec.Region)
+	if region == "" {
+		region = defaultRegion
+	}
+
+	keys := []serviceKey{}
+	for _, d := range r.GetServiceDefinitions() {
+		keys = append(keys, serviceKey{ServiceType: d.ServiceType, ServiceName: d.ServiceName, Region: region})
+	}
+
+	return keys
+}
+
+// validateUnique - rejects service type and name combinations in a region
+// which are also registered by one of the other services of the same
+// KeystoneAPI. Services looked up by type alone conflict with all services
+// of the type in the region. defaultRegion is the region of the KeystoneAPI,
+// the services without a Spec.Region get reconciled in.
+func (r *KeystoneService) validateUnique(others []KeystoneService, defaultRegion string) error {
+	managedBy := map[serviceKey]string{}
+	typeManagedBy := map[serviceKey]string{}
+	typeLookupBy := map[serviceKey]string{}
+	for _, other := range others {
+		if other.Namespace == r.Namespace && other.Name == r.Name {
+			continue
+		}
+		owner := other.Namespace + "/" + other.Name
+		for _, key := range other.getServiceKeys(defaultRegion) {
+			typeKey := serviceKey{ServiceType: key.ServiceType, Region: key.Region}
+			managedBy[key] = owner
+			typeManagedBy[typeKey] = owner
+			if other.GetNameLookup() == NameLookupType {
+				typeLookupBy[typeKey] = owner
+			}
+		}
+	}
+
+	var allErrs field.ErrorList
+	for i, key := range r.getServiceKeys(defaultRegion) {
+		typeKey := serviceKey{ServiceType: key.ServiceType, Region: key.Region}
+		owner, ok := managedBy[key]
+		if !ok && r.GetNameLookup() == NameLookupType {
+			owner, ok = typeManagedBy[typeKey]
+		}
+		if !ok {
+			owner, ok = typeLookupBy[typeKey]
+		}
+		if !ok {
+			continue
+		}
+
+		path := field.NewPath("spec").Child("serviceName")
+		if i > 0 {
+			path = field.NewPath("spec").Child("additionalServices").Index(i - 1).Child("serviceName")
+		}
+		allErrs = append(allErrs, field.Duplicate(path,
+			fmt.Sprintf("%s service %s is already managed by KeystoneService %s", key.ServiceType, key.ServiceName, owner)))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "KeystoneService"},
+		r.Name, allErrs)
+}
+
+// validateDependencies - rejects a DependsOn which leads back to the service,
+// directly or through the other services, as none of them would get ready
+func (r *KeystoneService) validateDependencies(others []KeystoneService) error {
+	dependsOn := map[string][]string{r.Name: r.Spec.DependsOn}
+	for _, other := range others {
+		if other.Name != r.Name {
+			dependsOn[other.Name] = other.Spec.DependsOn
+		}
+	}
+
+	var allErrs field.ErrorList
+	for i, dependency := range r.Spec.DependsOn {
+		visited := map[string]bool{}
+		pending := []string{dependency}
+		for len(pending) > 0 {
+			name := pending[0]
+			pending = pending[1:]
+			if name == r.Name {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("dependsOn").Index(i), dependency,
+					fmt.Sprintf("KeystoneService %s depends on itself through %s", r.Name, dependency)))
+				break
+			}
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			pending = append(pending, dependsOn[name]...)
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "KeystoneService"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newKeystoneService(namespace string, name string, spec KeystoneServiceSpec) *KeystoneService {
+	return &KeystoneService{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       spec,
+	}
+}
+
+func TestValidateUnique(t *testing.T) {
+	placement := KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement"}
+	placementEdge := KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement", Region: "edge"}
+	placementTypeLookup := KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement-v2", NameLookup: NameLookupType}
+
+	tests := []struct {
+		name    string
+		service *KeystoneService
+		others  []KeystoneService
+		wantErr bool
+	}{
+		{
+			name:    "same type and name in the default region",
+			service: newKeystoneService("openstack", "placement", placement),
+			others:  []KeystoneService{*newKeystoneService("openstack", "placement-2", placement)},
+			wantErr: true,
+		},
+		{
+			name:    "same type and name with the default region given",
+			service: newKeystoneService("openstack", "placement", KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement", Region: "regionOne"}),
+			others:  []KeystoneService{*newKeystoneService("openstack", "placement-2", placement)},
+			wantErr: true,
+		},
+		{
+			name:    "same type and name in another region",
+			service: newKeystoneService("openstack", "placement-edge", placementEdge),
+			others:  []KeystoneService{*newKeystoneService("openstack", "placement", placement)},
+			wantErr: false,
+		},
+		{
+			name:    "same type and name in another namespace",
+			service: newKeystoneService("edge", "placement", placement),
+			others:  []KeystoneService{*newKeystoneService("openstack", "placement", placement)},
+			wantErr: true,
+		},
+		{
+			name:    "type lookup conflicts with the services of the type",
+			service: newKeystoneService("openstack", "placement-v2", placementTypeLookup),
+			others:  []KeystoneService{*newKeystoneService("openstack", "placement", placement)},
+			wantErr: true,
+		},
+		{
+			name:    "the service itself",
+			service: newKeystoneService("openstack", "placement", placement),
+			others:  []KeystoneService{*newKeystoneService("openstack", "placement", placement)},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.service.validateUnique(tt.others, "regionOne")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateUnique() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateSkipsUnchanged(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	// a pre-existing overlap of two services
+	placement := newKeystoneService("openstack", "placement", KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement"})
+	duplicate := newKeystoneService("central", "placement", KeystoneServiceSpec{
+		ServiceType:          "placement",
+		ServiceName:          "placement",
+		KeystoneAPINamespace: "openstack",
+	})
+	keystoneServiceWebhookClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(placement, duplicate).Build()
+	defer func() { keystoneServiceWebhookClient = nil }()
+
+	if err := duplicate.ValidateCreate(); err == nil {
+		t.Errorf("ValidateCreate() of a duplicate in another namespace of the KeystoneAPI succeeded")
+	}
+
+	updated := duplicate.DeepCopy()
+	updated.Spec.ServiceDescription = "Placement service"
+	if err := updated.ValidateUpdate(duplicate); err != nil {
+		t.Errorf("ValidateUpdate() not changing the type, name or region: %v", err)
+	}
+
+	deleted := duplicate.DeepCopy()
+	deleted.Spec.ServiceName = "placement-2"
+	deleted.DeletionTimestamp = &metav1.Time{}
+	deleted.Finalizers = nil
+	if err := deleted.ValidateUpdate(duplicate); err != nil {
+		t.Errorf("ValidateUpdate() of a deleted service: %v", err)
+	}
+
+	renamed := duplicate.DeepCopy()
+	renamed.Spec.Region = "edge"
+	if err := renamed.ValidateUpdate(duplicate); err != nil {
+		t.Errorf("ValidateUpdate() moving the service to another region: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneServiceTemplateSpec defines the desired state of KeystoneServiceTemplate
+type KeystoneServiceTemplateSpec struct {
+	// +kubebuilder:validation:Required
+	// Template - the service with its endpoints the instances get expanded
+	// from. The ServiceType, ServiceName, ServiceDescription, ServiceUser,
+	// Secret, PasswordSelector and endpoint URLs are Go templates, rendered
+	// with the values of an instance as .Values.
+	Template KeystoneCatalogService `json:"template"`
+	// +kubebuilder:validation:Required
+	// Instances - the values of the services expanded from the Template. The
+	// services get reconciled by a KeystoneCatalog named after the
+	// KeystoneServiceTemplate, instances removed from the list get deleted.
+	Instances []KeystoneServiceTemplateInstance `json:"instances"`
+}
+
+// KeystoneServiceTemplateInstance - a service expanded from the Template of a
+// KeystoneServiceTemplate
+type KeystoneServiceTemplateInstance struct {
+	// +kubebuilder:validation:Optional
+	// Values - the values the Template gets rendered with, as .Values
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// KeystoneServiceTemplateStatus defines the observed state of KeystoneServiceTemplate
+type KeystoneServiceTemplateStatus struct {
+	// ServiceNames - names of the services expanded from the instances
+	ServiceNames []string `json:"serviceNames,omitempty"`
+	// ReadyCount - number of expanded services which are ready together with
+	// their endpoints
+	ReadyCount int `json:"readyCount,omitempty"`
+	// ObservedGeneration - the most recent generation reconciled successfully
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions
+	Conditions condition.Conditions `json:"conditions,omitempty" optional:"true"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyCount",description="Ready"
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[0].status",description="Status"
+//+kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.conditions[0].message",description="Message"
+
+// KeystoneServiceTemplate is the Schema for the keystoneservicetemplates API
+type KeystoneServiceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneServiceTemplateSpec   `json:"spec,omitempty"`
+	Status KeystoneServiceTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KeystoneServiceTemplateList contains a list of KeystoneServiceTemplate
+type KeystoneServiceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneServiceTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneServiceTemplate{}, &KeystoneServiceTemplateList{})
+}
+
+// IsReady - returns true if all services expanded from the template and
+// their endpoints are ready
+func (instance KeystoneServiceTemplate) IsReady() bool {
+	return instance.Status.Conditions.IsTrue(KeystoneServiceTemplateServicesReadyCondition)
+}
@@ -23,9 +23,116 @@ package v1beta1
 
 import (
 	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackendRef) DeepCopyInto(out *BackendRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendRef.
+func (in *BackendRef) DeepCopy() *BackendRef {
+	if in == nil {
+		return nil
+	}
+	out := new(BackendRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointGroupSpec) DeepCopyInto(out *EndpointGroupSpec) {
+	*out = *in
+	if in.ProjectIDs != nil {
+		in, out := &in.ProjectIDs, &out.ProjectIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointGroupSpec.
+func (in *EndpointGroupSpec) DeepCopy() *EndpointGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointGroupStatus) DeepCopyInto(out *EndpointGroupStatus) {
+	*out = *in
+	if in.ProjectIDs != nil {
+		in, out := &in.ProjectIDs, &out.ProjectIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointGroupStatus.
+func (in *EndpointGroupStatus) DeepCopy() *EndpointGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointHealthCheck) DeepCopyInto(out *EndpointHealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointHealthCheck.
+func (in *EndpointHealthCheck) DeepCopy() *EndpointHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointSpec) DeepCopyInto(out *EndpointSpec) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointSpec.
+func (in *EndpointSpec) DeepCopy() *EndpointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointURLRef) DeepCopyInto(out *EndpointURLRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointURLRef.
+func (in *EndpointURLRef) DeepCopy() *EndpointURLRef {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointURLRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneAPI) DeepCopyInto(out *KeystoneAPI) {
 	*out = *in
@@ -88,6 +195,11 @@ func (in *KeystoneAPIList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneAPISpec) DeepCopyInto(out *KeystoneAPISpec) {
 	*out = *in
+	if in.AuthURLs != nil {
+		in, out := &in.AuthURLs, &out.AuthURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	out.PasswordSelectors = in.PasswordSelectors
 	if in.NodeSelector != nil {
 		in, out := &in.NodeSelector, &out.NodeSelector
@@ -153,6 +265,139 @@ func (in *KeystoneAPIStatus) DeepCopy() *KeystoneAPIStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneCatalog) DeepCopyInto(out *KeystoneCatalog) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneCatalog.
+func (in *KeystoneCatalog) DeepCopy() *KeystoneCatalog {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneCatalog)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeystoneCatalog) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneCatalogList) DeepCopyInto(out *KeystoneCatalogList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KeystoneCatalog, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneCatalogList.
+func (in *KeystoneCatalogList) DeepCopy() *KeystoneCatalogList {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneCatalogList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeystoneCatalogList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneCatalogService) DeepCopyInto(out *KeystoneCatalogService) {
+	*out = *in
+	in.KeystoneServiceSpec.DeepCopyInto(&out.KeystoneServiceSpec)
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneCatalogService.
+func (in *KeystoneCatalogService) DeepCopy() *KeystoneCatalogService {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneCatalogService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneCatalogSpec) DeepCopyInto(out *KeystoneCatalogSpec) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]KeystoneCatalogService, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneCatalogSpec.
+func (in *KeystoneCatalogSpec) DeepCopy() *KeystoneCatalogSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneCatalogSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneCatalogStatus) DeepCopyInto(out *KeystoneCatalogStatus) {
+	*out = *in
+	if in.ServiceIDs != nil {
+		in, out := &in.ServiceIDs, &out.ServiceIDs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(condition.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneCatalogStatus.
+func (in *KeystoneCatalogStatus) DeepCopy() *KeystoneCatalogStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneCatalogStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneDebug) DeepCopyInto(out *KeystoneDebug) {
 	*out = *in
@@ -264,6 +509,13 @@ func (in *KeystoneEndpointList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneEndpointSpec) DeepCopyInto(out *KeystoneEndpointSpec) {
 	*out = *in
+	if in.EndpointList != nil {
+		in, out := &in.EndpointList, &out.EndpointList
+		*out = make([]EndpointSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Endpoints != nil {
 		in, out := &in.Endpoints, &out.Endpoints
 		*out = make(map[string]string, len(*in))
@@ -271,6 +523,57 @@ func (in *KeystoneEndpointSpec) DeepCopyInto(out *KeystoneEndpointSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.AdoptEndpointIDs != nil {
+		in, out := &in.AdoptEndpointIDs, &out.AdoptEndpointIDs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ProjectEndpointScope != nil {
+		in, out := &in.ProjectEndpointScope, &out.ProjectEndpointScope
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PublicAliases != nil {
+		in, out := &in.PublicAliases, &out.PublicAliases
+		*out = make([]PublicAlias, len(*in))
+		copy(*out, *in)
+	}
+	if in.EndpointURLRefs != nil {
+		in, out := &in.EndpointURLRefs, &out.EndpointURLRefs
+		*out = make(map[string]EndpointURLRef, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EndpointOrder != nil {
+		in, out := &in.EndpointOrder, &out.EndpointOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.URLVariablesFrom != nil {
+		in, out := &in.URLVariablesFrom, &out.URLVariablesFrom
+		*out = make([]v1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(EndpointHealthCheck)
+		**out = **in
+	}
+	if in.ParentRegions != nil {
+		in, out := &in.ParentRegions, &out.ParentRegions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RetryInterval != nil {
+		in, out := &in.RetryInterval, &out.RetryInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneEndpointSpec.
@@ -293,6 +596,34 @@ func (in *KeystoneEndpointStatus) DeepCopyInto(out *KeystoneEndpointStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.ScopedProjectIDs != nil {
+		in, out := &in.ScopedProjectIDs, &out.ScopedProjectIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UndeclaredEndpoints != nil {
+		in, out := &in.UndeclaredEndpoints, &out.UndeclaredEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResolvedEndpoints != nil {
+		in, out := &in.ResolvedEndpoints, &out.ResolvedEndpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ManagedFields != nil {
+		in, out := &in.ManagedFields, &out.ManagedFields
+		*out = make(map[string]ManagedEndpointFields, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(condition.Conditions, len(*in))
@@ -312,12 +643,120 @@ func (in *KeystoneEndpointStatus) DeepCopy() *KeystoneEndpointStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneRole) DeepCopyInto(out *KeystoneRole) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneRole.
+func (in *KeystoneRole) DeepCopy() *KeystoneRole {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeystoneRole) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneRoleList) DeepCopyInto(out *KeystoneRoleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KeystoneRole, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneRoleList.
+func (in *KeystoneRoleList) DeepCopy() *KeystoneRoleList {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneRoleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeystoneRoleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneRoleSpec) DeepCopyInto(out *KeystoneRoleSpec) {
+	*out = *in
+	if in.ImpliedRoles != nil {
+		in, out := &in.ImpliedRoles, &out.ImpliedRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneRoleSpec.
+func (in *KeystoneRoleSpec) DeepCopy() *KeystoneRoleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneRoleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneRoleStatus) DeepCopyInto(out *KeystoneRoleStatus) {
+	*out = *in
+	if in.ImpliedRoleIDs != nil {
+		in, out := &in.ImpliedRoleIDs, &out.ImpliedRoleIDs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(condition.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneRoleStatus.
+func (in *KeystoneRoleStatus) DeepCopy() *KeystoneRoleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneRoleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneService) DeepCopyInto(out *KeystoneService) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -339,6 +778,21 @@ func (in *KeystoneService) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneServiceDefinition) DeepCopyInto(out *KeystoneServiceDefinition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceDefinition.
+func (in *KeystoneServiceDefinition) DeepCopy() *KeystoneServiceDefinition {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneServiceDefinition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneServiceHelper) DeepCopyInto(out *KeystoneServiceHelper) {
 	*out = *in
@@ -401,6 +855,45 @@ func (in *KeystoneServiceList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneServiceSpec) DeepCopyInto(out *KeystoneServiceSpec) {
 	*out = *in
+	if in.AdditionalServices != nil {
+		in, out := &in.AdditionalServices, &out.AdditionalServices
+		*out = make([]KeystoneServiceDefinition, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LocalizedDescriptions != nil {
+		in, out := &in.LocalizedDescriptions, &out.LocalizedDescriptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Backend != nil {
+		in, out := &in.Backend, &out.Backend
+		*out = new(BackendRef)
+		**out = **in
+	}
+	if in.EndpointGroups != nil {
+		in, out := &in.EndpointGroups, &out.EndpointGroups
+		*out = make([]EndpointGroupSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ManageService != nil {
+		in, out := &in.ManageService, &out.ManageService
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceSpec.
@@ -416,6 +909,57 @@ func (in *KeystoneServiceSpec) DeepCopy() *KeystoneServiceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeystoneServiceStatus) DeepCopyInto(out *KeystoneServiceStatus) {
 	*out = *in
+	if in.AdditionalServiceIDs != nil {
+		in, out := &in.AdditionalServiceIDs, &out.AdditionalServiceIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnconfirmedServiceSince != nil {
+		in, out := &in.UnconfirmedServiceSince, &out.UnconfirmedServiceSince
+		*out = (*in).DeepCopy()
+	}
+	if in.AdoptedServices != nil {
+		in, out := &in.AdoptedServices, &out.AdoptedServices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.BackendReady != nil {
+		in, out := &in.BackendReady, &out.BackendReady
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ManagedFields != nil {
+		in, out := &in.ManagedFields, &out.ManagedFields
+		*out = new(ManagedServiceFields)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PropagationDisabledEndpointIDs != nil {
+		in, out := &in.PropagationDisabledEndpointIDs, &out.PropagationDisabledEndpointIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EndpointGroups != nil {
+		in, out := &in.EndpointGroups, &out.EndpointGroups
+		*out = make(map[string]EndpointGroupStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.ManagedCatalog != nil {
+		in, out := &in.ManagedCatalog, &out.ManagedCatalog
+		*out = make([]ManagedCatalogService, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(condition.Conditions, len(*in))
@@ -435,6 +979,212 @@ func (in *KeystoneServiceStatus) DeepCopy() *KeystoneServiceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneServiceTemplate) DeepCopyInto(out *KeystoneServiceTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceTemplate.
+func (in *KeystoneServiceTemplate) DeepCopy() *KeystoneServiceTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneServiceTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeystoneServiceTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneServiceTemplateInstance) DeepCopyInto(out *KeystoneServiceTemplateInstance) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceTemplateInstance.
+func (in *KeystoneServiceTemplateInstance) DeepCopy() *KeystoneServiceTemplateInstance {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneServiceTemplateInstance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneServiceTemplateList) DeepCopyInto(out *KeystoneServiceTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KeystoneServiceTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceTemplateList.
+func (in *KeystoneServiceTemplateList) DeepCopy() *KeystoneServiceTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneServiceTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeystoneServiceTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneServiceTemplateSpec) DeepCopyInto(out *KeystoneServiceTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]KeystoneServiceTemplateInstance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceTemplateSpec.
+func (in *KeystoneServiceTemplateSpec) DeepCopy() *KeystoneServiceTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneServiceTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoneServiceTemplateStatus) DeepCopyInto(out *KeystoneServiceTemplateStatus) {
+	*out = *in
+	if in.ServiceNames != nil {
+		in, out := &in.ServiceNames, &out.ServiceNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(condition.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoneServiceTemplateStatus.
+func (in *KeystoneServiceTemplateStatus) DeepCopy() *KeystoneServiceTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoneServiceTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedCatalogEndpoint) DeepCopyInto(out *ManagedCatalogEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedCatalogEndpoint.
+func (in *ManagedCatalogEndpoint) DeepCopy() *ManagedCatalogEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedCatalogEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedCatalogService) DeepCopyInto(out *ManagedCatalogService) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]ManagedCatalogEndpoint, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedCatalogService.
+func (in *ManagedCatalogService) DeepCopy() *ManagedCatalogService {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedCatalogService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedEndpointFields) DeepCopyInto(out *ManagedEndpointFields) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedEndpointFields.
+func (in *ManagedEndpointFields) DeepCopy() *ManagedEndpointFields {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedEndpointFields)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedServiceFields) DeepCopyInto(out *ManagedServiceFields) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedServiceFields.
+func (in *ManagedServiceFields) DeepCopy() *ManagedServiceFields {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedServiceFields)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PasswordSelector) DeepCopyInto(out *PasswordSelector) {
 	*out = *in
@@ -0,0 +1,442 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// isUnauthorized - returns true if keystone rejected the request with a 401
+func isUnauthorized(err error) bool {
+	var err401 gophercloud.ErrDefault401
+	if errors.As(err, &err401) {
+		return true
+	}
+
+	var errCode gophercloud.ErrUnexpectedResponseCode
+	return errors.As(err, &errCode) && errCode.Actual == http.StatusUnauthorized
+}
+
+// isForbidden - returns true if keystone rejected the request with a 403
+func isForbidden(err error) bool {
+	var err403 gophercloud.ErrDefault403
+	if errors.As(err, &err403) {
+		return true
+	}
+
+	var errCode gophercloud.ErrUnexpectedResponseCode
+	return errors.As(err, &errCode) && errCode.Actual == http.StatusForbidden
+}
+
+// keystoneErrorCondition - returns a False condition of type t for an error
+// returned by keystone. Rejected credentials (401), missing authorization
+// (403), an ambiguous region, an ambiguous identity endpoint, an invalid
+// client certificate and unsupported domain scoped services get their own
+// reason and message as they need different fixes, other errors use
+// errorMessage.
+func keystoneErrorCondition(
+	t condition.Type,
+	errorMessage string,
+	err error,
+) *condition.Condition {
+	if isUnauthorized(err) {
+		return condition.FalseCondition(
+			t,
+			keystonev1.AuthenticationFailedReason,
+			condition.SeverityError,
+			keystonev1.AuthenticationFailedMessage,
+			err.Error())
+	}
+	if isForbidden(err) {
+		return condition.FalseCondition(
+			t,
+			keystonev1.AuthorizationFailedReason,
+			condition.SeverityError,
+			keystonev1.AuthorizationFailedMessage,
+			err.Error())
+	}
+	if errors.Is(err, keystone.ErrAmbiguousRegion) {
+		return condition.FalseCondition(
+			t,
+			keystonev1.AmbiguousRegionReason,
+			condition.SeverityError,
+			keystonev1.AmbiguousRegionMessage,
+			err.Error())
+	}
+	if errors.Is(err, keystone.ErrAmbiguousIdentityEndpoint) {
+		return condition.FalseCondition(
+			t,
+			keystonev1.AmbiguousIdentityEndpointReason,
+			condition.SeverityError,
+			keystonev1.AmbiguousIdentityEndpointMessage,
+			err.Error())
+	}
+	if errors.Is(err, keystone.ErrClientCertificate) {
+		return condition.FalseCondition(
+			t,
+			keystonev1.ClientCertificateInvalidReason,
+			condition.SeverityError,
+			keystonev1.ClientCertificateInvalidMessage,
+			err.Error())
+	}
+
+	if errors.Is(err, keystone.ErrDomainScopedServiceUnsupported) {
+		return condition.FalseCondition(
+			t,
+			keystonev1.DomainScopedServiceUnsupportedReason,
+			condition.SeverityError,
+			keystonev1.DomainScopedServiceUnsupportedMessage,
+			err.Error())
+	}
+
+	return condition.FalseCondition(
+		t,
+		condition.ErrorReason,
+		condition.SeverityWarning,
+		errorMessage,
+		err.Error())
+}
+
+// identityClientFactory - returns newClient, or the admin IdentityClient
+// factory using opts if it is not set
+func identityClientFactory(
+	newClient keystone.IdentityClientFactory,
+	opts keystone.ClientOptions,
+) keystone.IdentityClientFactory {
+	if newClient == nil {
+		return keystone.NewAdminIdentityClientFactory(opts)
+	}
+
+	return newClient
+}
+
+// getIdentityClient - returns an admin IdentityClient for keystoneAPI using
+// newClient
+func getIdentityClient(
+	ctx context.Context,
+	h *helper.Helper,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	newClient keystone.IdentityClientFactory,
+) (keystone.IdentityClient, ctrl.Result, error) {
+	ctx, span := startSpan(ctx, "keystone.Authenticate", regionAttr.String(keystoneAPI.GetRegion()))
+	os, ctrlResult, err := newClient(ctx, h, keystoneAPI)
+	endSpan(span, err)
+
+	return os, ctrlResult, err
+}
+
+// authBreakerKey - returns the key of keystoneAPI in the AuthBreaker, the
+// failures get counted per KeystoneAPI
+func authBreakerKey(keystoneAPI *keystonev1.KeystoneAPI) string {
+	return keystoneAPI.Namespace + "/" + keystoneAPI.Name
+}
+
+// withRegion - returns keystoneAPI with region as the region the admin clients
+// authenticate in. If region is empty the default region of the KeystoneAPI
+// status is used, or its spec while the status has none. keystoneAPI is
+// returned as it is if the region does not change, otherwise a copy with the
+// RegionID reset to the region.
+func withRegion(keystoneAPI *keystonev1.KeystoneAPI, region string) *keystonev1.KeystoneAPI {
+	region = strings.TrimSpace(region)
+	if region == "" {
+		region = keystoneAPI.Status.Region
+	}
+	if region == "" || region == keystoneAPI.GetRegion() {
+		return keystoneAPI
+	}
+
+	keystoneAPI = keystoneAPI.DeepCopy()
+	keystoneAPI.Spec.Region = region
+	keystoneAPI.Spec.RegionID = ""
+
+	return keystoneAPI
+}
+
+// detachedContext - carries the values of its parent, but is not cancelled
+// with it
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// detachContext - returns a context with the values of ctx which is not
+// cancelled when the manager shuts down. An in-flight reconcile then finishes
+// its keystone changes and records them in the status, instead of failing
+// the status update and leaving keystone half updated. The manager bounds
+// the wait with its graceful shutdown timeout.
+func detachContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// withRequestID - returns ctx carrying the keystone.RequestID sent with the
+// keystone requests of a reconcile. A RequestID already in ctx is honored.
+func withRequestID(ctx context.Context) (context.Context, *keystone.RequestID) {
+	if requestID := keystone.RequestIDFromContext(ctx); requestID != nil {
+		return ctx, requestID
+	}
+	requestID := keystone.NewRequestID()
+
+	return keystone.WithRequestID(ctx, requestID), requestID
+}
+
+// changedRequestID - returns the ID of the RequestID of ctx if keystone got
+// changed by a request using it, otherwise an empty string
+func changedRequestID(ctx context.Context) string {
+	requestID := keystone.RequestIDFromContext(ctx)
+	if requestID == nil || !requestID.Changed() {
+		return ""
+	}
+
+	return requestID.ID
+}
+
+// reauthFunc - returns a newly authenticated admin client
+type reauthFunc func() (keystone.IdentityClient, ctrl.Result, error)
+
+// reauthOnUnauthorized - runs f using the admin client os. If keystone rejects the
+// token with a 401, e.g. because it expired since the client got created, a new
+// admin client is requested from reauth and f is retried once with it.
+// The client used last is returned so it can be used for subsequent calls. If the
+// new admin client is not yet ready, the ctrl.Result of reauth gets returned.
+func reauthOnUnauthorized(
+	os keystone.IdentityClient,
+	reauth reauthFunc,
+	f func(os keystone.IdentityClient) (ctrl.Result, error),
+) (keystone.IdentityClient, ctrl.Result, error) {
+	ctrlResult, err := f(os)
+	if err == nil || !isUnauthorized(err) {
+		return os, ctrlResult, err
+	}
+
+	newOS, ctrlResult, err := reauth()
+	if err != nil || (ctrlResult != ctrl.Result{}) {
+		return os, ctrlResult, err
+	}
+
+	ctrlResult, err = f(newOS)
+	return newOS, ctrlResult, err
+}
+
+// adminClientReauth - returns a reauthFunc which authenticates a new admin client
+// for keystoneAPI and reflects the outcome in the AdminServiceClientReady
+// condition the same way the initial authentication in Reconcile does.
+func adminClientReauth(
+	ctx context.Context,
+	h *helper.Helper,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	newClient keystone.IdentityClientFactory,
+	instance client.Object,
+	conditions *condition.Conditions,
+) reauthFunc {
+	return func() (keystone.IdentityClient, ctrl.Result, error) {
+		util.LogForObject(h, "Token got rejected by keystone, re-authenticating", instance)
+
+		os, ctrlResult, err := getIdentityClient(
+			ctx,
+			h,
+			keystoneAPI,
+			newClient,
+		)
+		if err != nil {
+			conditions.Set(keystoneErrorCondition(
+				keystonev1.AdminServiceClientReadyCondition,
+				keystonev1.AdminServiceClientReadyErrorMessage,
+				err))
+			return nil, ctrl.Result{}, err
+		}
+		if (ctrlResult != ctrl.Result{}) {
+			conditions.Set(condition.FalseCondition(
+				keystonev1.AdminServiceClientReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				keystonev1.AdminServiceClientReadyWaitingMessage))
+			return nil, ctrlResult, nil
+		}
+
+		return os, ctrl.Result{}, nil
+	}
+}
+
+// reauthRequested - returns true if the ReauthAnnotation of instance is true
+func reauthRequested(instance client.Object) bool {
+	return keystonev1.ReauthAnnotation != "" &&
+		instance.GetAnnotations()[keystonev1.ReauthAnnotation] == "true"
+}
+
+// getFinalizer - returns the finalizer registered on the instances which get
+// cleaned up in keystone, keystonev1.Finalizer or else the one of helper
+func getFinalizer(h *helper.Helper) string {
+	if keystonev1.Finalizer != "" {
+		return keystonev1.Finalizer
+	}
+
+	return h.GetFinalizer()
+}
+
+// finalizerDisabled - returns true if no finalizer gets registered on
+// instance, with keystonev1.DisableFinalizer or its SkipFinalizerAnnotation
+// true
+func finalizerDisabled(instance client.Object) bool {
+	return keystonev1.DisableFinalizer ||
+		keystonev1.SkipFinalizerAnnotation != "" &&
+			instance.GetAnnotations()[keystonev1.SkipFinalizerAnnotation] == "true"
+}
+
+// hasFinalizer - returns true if instance holds the finalizer, or the default
+// one of helper it got registered with before the finalizer got renamed
+func hasFinalizer(instance client.Object, h *helper.Helper) bool {
+	return controllerutil.ContainsFinalizer(instance, getFinalizer(h)) ||
+		controllerutil.ContainsFinalizer(instance, h.GetFinalizer())
+}
+
+// removeFinalizers - removes the finalizer from instance, also the default one
+// of helper
+func removeFinalizers(instance client.Object, h *helper.Helper) {
+	controllerutil.RemoveFinalizer(instance, getFinalizer(h))
+	controllerutil.RemoveFinalizer(instance, h.GetFinalizer())
+}
+
+// registerFinalizer - adds the finalizer to instance and updates it
+// immediately to avoid orphaning resources on delete. With the finalizer
+// disabled a registered one gets removed instead.
+func registerFinalizer(
+	ctx context.Context,
+	c client.Client,
+	instance client.Object,
+	h *helper.Helper,
+) error {
+	if finalizerDisabled(instance) {
+		if !hasFinalizer(instance, h) {
+			return nil
+		}
+		removeFinalizers(instance, h)
+	} else {
+		controllerutil.AddFinalizer(instance, getFinalizer(h))
+	}
+
+	return c.Update(ctx, instance)
+}
+
+// persistProgress - writes the status of instance right after a step of the
+// reconcile registered something in keystone, e.g. a service or an endpoint, so
+// a reconcile interrupted by an operator restart resumes from the tracked IDs.
+// A failed write is only logged, the status patch at the end of the reconcile
+// writes the status again.
+func persistProgress(
+	ctx context.Context,
+	c client.Client,
+	log logr.Logger,
+	instance client.Object,
+	step string,
+) {
+	if err := updateStatus(ctx, c, instance); err != nil {
+		log.Error(err, "Unable to persist the reconcile progress", "step", step)
+		return
+	}
+	log.V(1).Info("Persisted the reconcile progress", "step", step)
+}
+
+// reportClockSkew - sets the KeystoneClockInSyncCondition from the clock skew
+// measured when os authenticated. A skew above threshold is only a warning,
+// the reconcile goes on, but tokens may then be rejected before or after their
+// expected lifetime.
+func reportClockSkew(conditions *condition.Conditions, os keystone.IdentityClient, threshold time.Duration) {
+	skew := os.GetClockSkew()
+	if !keystone.ClockSkewed(skew, threshold) {
+		conditions.MarkTrue(keystonev1.KeystoneClockInSyncCondition, keystonev1.KeystoneClockInSyncMessage)
+		return
+	}
+
+	conditions.Set(condition.FalseCondition(
+		keystonev1.KeystoneClockInSyncCondition,
+		keystonev1.ClockSkewDetectedReason,
+		condition.SeverityWarning,
+		keystonev1.KeystoneClockInSyncSkewMessage,
+		skew.String()))
+}
+
+// clearReauthAnnotation - removes the ReauthAnnotation from instance. Only the
+// metadata gets patched, the status of instance is kept as it is.
+func clearReauthAnnotation(
+	ctx context.Context,
+	c client.Client,
+	instance client.Object,
+) error {
+	obj := instance.DeepCopyObject().(client.Object)
+	annotations := obj.GetAnnotations()
+	delete(annotations, keystonev1.ReauthAnnotation)
+	obj.SetAnnotations(annotations)
+	if err := c.Patch(ctx, obj, client.MergeFrom(instance)); err != nil {
+		return err
+	}
+
+	instance.SetAnnotations(obj.GetAnnotations())
+	instance.SetResourceVersion(obj.GetResourceVersion())
+
+	return nil
+}
+
+// updateStatus - updates the status of instance. On a conflict the latest
+// resourceVersion of the object is fetched and the update of the status of
+// instance retried, a bounded number of times with backoff. If it still
+// conflicts, the conflict error is returned to requeue the request.
+func updateStatus(
+	ctx context.Context,
+	c client.Client,
+	instance client.Object,
+) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		err := c.Status().Update(ctx, instance)
+		if !k8s_errors.IsConflict(err) {
+			return err
+		}
+
+		// the status subresource update only changes the status, so it is
+		// enough to reapply it on top of the latest resourceVersion
+		latest := instance.DeepCopyObject().(client.Object)
+		if getErr := c.Get(ctx, client.ObjectKeyFromObject(instance), latest); getErr != nil {
+			return getErr
+		}
+		instance.SetResourceVersion(latest.GetResourceVersion())
+
+		return err
+	})
+}
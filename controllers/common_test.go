@@ -0,0 +1,344 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("reauthOnUnauthorized", func() {
+	var (
+		oldOS      keystone.IdentityClient
+		newOS      keystone.IdentityClient
+		reauths    int
+		calls      []keystone.IdentityClient
+		err401     error
+		reauth     reauthFunc
+		failingRun func(errs ...error) func(os keystone.IdentityClient) (ctrl.Result, error)
+	)
+
+	BeforeEach(func() {
+		oldOS = &keystone.Client{}
+		newOS = &keystone.Client{}
+		reauths = 0
+		calls = nil
+		err401 = gophercloud.ErrDefault401{
+			ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{
+				Actual: http.StatusUnauthorized,
+			},
+		}
+		reauth = func() (keystone.IdentityClient, ctrl.Result, error) {
+			reauths++
+			return newOS, ctrl.Result{}, nil
+		}
+		// failingRun returns the given errors in order, one per call
+		failingRun = func(errs ...error) func(os keystone.IdentityClient) (ctrl.Result, error) {
+			return func(os keystone.IdentityClient) (ctrl.Result, error) {
+				calls = append(calls, os)
+				if len(calls) > len(errs) {
+					return ctrl.Result{}, nil
+				}
+				return ctrl.Result{}, errs[len(calls)-1]
+			}
+		}
+	})
+
+	It("detects wrapped 401 errors", func() {
+		Expect(isUnauthorized(err401)).To(BeTrue())
+		Expect(isUnauthorized(fmt.Errorf("get service: %w", err401))).To(BeTrue())
+		Expect(isUnauthorized(gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusUnauthorized})).To(BeTrue())
+		Expect(isUnauthorized(gophercloud.ErrDefault403{})).To(BeFalse())
+		Expect(isUnauthorized(fmt.Errorf("boom"))).To(BeFalse())
+	})
+
+	It("re-authenticates and retries exactly once on a 401", func() {
+		os, ctrlResult, err := reauthOnUnauthorized(oldOS, reauth, failingRun(err401))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ctrlResult).To(Equal(ctrl.Result{}))
+		Expect(reauths).To(Equal(1))
+		Expect(calls).To(HaveLen(2))
+		Expect(calls[0]).To(BeIdenticalTo(oldOS))
+		Expect(calls[1]).To(BeIdenticalTo(newOS))
+		Expect(os).To(BeIdenticalTo(newOS))
+	})
+
+	It("returns a non 401 error as is without re-authenticating", func() {
+		otherErr := fmt.Errorf("boom")
+		os, _, err := reauthOnUnauthorized(oldOS, reauth, failingRun(otherErr))
+		Expect(err).To(BeIdenticalTo(otherErr))
+		Expect(reauths).To(Equal(0))
+		Expect(calls).To(HaveLen(1))
+		Expect(os).To(BeIdenticalTo(oldOS))
+	})
+
+	It("returns a second 401 to the caller", func() {
+		_, _, err := reauthOnUnauthorized(oldOS, reauth, failingRun(err401, err401))
+		Expect(isUnauthorized(err)).To(BeTrue())
+		Expect(reauths).To(Equal(1))
+		Expect(calls).To(HaveLen(2))
+	})
+
+	It("returns the ctrl.Result when the new admin client is not yet ready", func() {
+		waiting := ctrl.Result{RequeueAfter: 10 * time.Second}
+		reauth = func() (keystone.IdentityClient, ctrl.Result, error) {
+			reauths++
+			return nil, waiting, nil
+		}
+		os, ctrlResult, err := reauthOnUnauthorized(oldOS, reauth, failingRun(err401))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ctrlResult).To(Equal(waiting))
+		Expect(calls).To(HaveLen(1))
+		Expect(os).To(BeIdenticalTo(oldOS))
+	})
+})
+
+var _ = Describe("updateStatus", func() {
+	It("retries the status update on a conflict", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+		}).Build()
+		key := client.ObjectKey{Name: "placement", Namespace: "openstack"}
+
+		stale := &keystonev1.KeystoneService{}
+		Expect(c.Get(ctx, key, stale)).To(Succeed())
+
+		// a concurrent update bumps the resourceVersion
+		latest := stale.DeepCopy()
+		latest.Labels = map[string]string{"updated": "true"}
+		Expect(c.Update(ctx, latest)).To(Succeed())
+
+		stale.Status.ServiceID = "1234"
+		Expect(updateStatus(ctx, c, stale)).To(Succeed())
+
+		Expect(c.Get(ctx, key, latest)).To(Succeed())
+		Expect(latest.Status.ServiceID).To(Equal("1234"))
+	})
+})
+
+var _ = Describe("persistProgress", func() {
+	It("writes the status tracked so far", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+		}).Build()
+		key := client.ObjectKey{Name: "placement", Namespace: "openstack"}
+
+		instance := &keystonev1.KeystoneEndpoint{}
+		Expect(c.Get(ctx, key, instance)).To(Succeed())
+		instance.Status.EndpointIDs = map[string]string{"public": "5678"}
+		persistProgress(ctx, c, logr.Discard(), instance, "public endpoint")
+
+		latest := &keystonev1.KeystoneEndpoint{}
+		Expect(c.Get(ctx, key, latest)).To(Succeed())
+		Expect(latest.Status.EndpointIDs).To(Equal(map[string]string{"public": "5678"}))
+	})
+})
+
+var _ = Describe("clearReauthAnnotation", func() {
+	It("removes the annotation and keeps the status", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "placement",
+				Namespace:   "openstack",
+				Annotations: map[string]string{keystonev1.ReauthAnnotation: "true", "other": "kept"},
+			},
+		}).Build()
+		key := client.ObjectKey{Name: "placement", Namespace: "openstack"}
+
+		instance := &keystonev1.KeystoneService{}
+		Expect(c.Get(ctx, key, instance)).To(Succeed())
+		Expect(reauthRequested(instance)).To(BeTrue())
+
+		instance.Status.ServiceID = "1234"
+		Expect(clearReauthAnnotation(ctx, c, instance)).To(Succeed())
+		Expect(reauthRequested(instance)).To(BeFalse())
+		Expect(instance.Status.ServiceID).To(Equal("1234"))
+
+		latest := &keystonev1.KeystoneService{}
+		Expect(c.Get(ctx, key, latest)).To(Succeed())
+		Expect(latest.Annotations).To(Equal(map[string]string{"other": "kept"}))
+		Expect(latest.ResourceVersion).To(Equal(instance.ResourceVersion))
+	})
+})
+
+var _ = Describe("detachContext", func() {
+	It("keeps the values, but is not cancelled with its parent", func() {
+		requestID := keystone.NewRequestID()
+		parent, cancel := context.WithCancel(keystone.WithRequestID(context.Background(), requestID))
+		ctx := detachContext(parent)
+		cancel()
+
+		Expect(parent.Err()).To(HaveOccurred())
+		Expect(ctx.Err()).NotTo(HaveOccurred())
+		Expect(ctx.Done()).To(BeNil())
+		Expect(keystone.RequestIDFromContext(ctx)).To(Equal(requestID))
+	})
+})
+
+var _ = Describe("keystoneErrorCondition", func() {
+	It("distinguishes rejected credentials from missing authorization", func() {
+		err401 := gophercloud.ErrDefault401{
+			ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusUnauthorized},
+		}
+		err403 := gophercloud.ErrDefault403{
+			ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusForbidden},
+		}
+
+		c := keystoneErrorCondition(keystonev1.KeystoneServiceOSServiceReadyCondition, keystonev1.KeystoneServiceOSServiceReadyErrorMessage, err401)
+		Expect(c.Reason).To(Equal(keystonev1.AuthenticationFailedReason))
+
+		c = keystoneErrorCondition(keystonev1.KeystoneServiceOSServiceReadyCondition, keystonev1.KeystoneServiceOSServiceReadyErrorMessage, err403)
+		Expect(c.Reason).To(Equal(keystonev1.AuthorizationFailedReason))
+		Expect(c.Message).To(ContainSubstring("lacks the admin role"))
+
+		c = keystoneErrorCondition(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyErrorMessage,
+			fmt.Errorf("%w: regionOne, regionTwo", keystone.ErrAmbiguousRegion))
+		Expect(c.Reason).To(Equal(keystonev1.AmbiguousRegionReason))
+
+		c = keystoneErrorCondition(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyErrorMessage,
+			fmt.Errorf("%w \"\": http://a:5000, http://b:5000", keystone.ErrAmbiguousIdentityEndpoint))
+		Expect(c.Reason).To(Equal(keystonev1.AmbiguousIdentityEndpointReason))
+
+		c = keystoneErrorCondition(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyErrorMessage,
+			fmt.Errorf("secret keystone-client: %w: tls: private key does not match public key", keystone.ErrClientCertificate))
+		Expect(c.Reason).To(Equal(keystonev1.ClientCertificateInvalidReason))
+		Expect(c.Message).To(ContainSubstring("clientTLSSecret"))
+
+		c = keystoneErrorCondition(keystonev1.KeystoneServiceOSServiceReadyCondition, keystonev1.KeystoneServiceOSServiceReadyErrorMessage,
+			fmt.Errorf("%w: service 1234 has no domain_id", keystone.ErrDomainScopedServiceUnsupported))
+		Expect(c.Reason).To(Equal(keystonev1.DomainScopedServiceUnsupportedReason))
+
+		c = keystoneErrorCondition(keystonev1.KeystoneServiceOSServiceReadyCondition, keystonev1.KeystoneServiceOSServiceReadyErrorMessage, fmt.Errorf("boom"))
+		Expect(c.Reason).To(Equal(condition.Reason(condition.ErrorReason)))
+
+		c = keystoneErrorCondition(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyErrorMessage,
+			fmt.Errorf("%w, last error at http://b:5000: connection refused", keystone.ErrAuthURLsUnavailable))
+		Expect(c.Reason).To(Equal(condition.Reason(condition.ErrorReason)))
+		Expect(c.Message).To(ContainSubstring("http://b:5000: connection refused"))
+	})
+})
+
+var _ = Describe("reportClockSkew", func() {
+	It("warns about a clock skew above the threshold", func() {
+		os := newFakeIdentityClient("regionOne")
+		conditions := condition.Conditions{}
+
+		os.clockSkew = 5 * time.Second
+		reportClockSkew(&conditions, os, keystone.DefaultClockSkewThreshold)
+		Expect(conditions.IsTrue(keystonev1.KeystoneClockInSyncCondition)).To(BeTrue())
+
+		os.clockSkew = -2 * time.Minute
+		reportClockSkew(&conditions, os, keystone.DefaultClockSkewThreshold)
+		c := conditions.Get(keystonev1.KeystoneClockInSyncCondition)
+		Expect(c.Status).To(BeEquivalentTo(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal(keystonev1.ClockSkewDetectedReason))
+		Expect(c.Severity).To(Equal(condition.SeverityWarning))
+		Expect(c.Message).To(ContainSubstring("-2m0s"))
+	})
+})
+
+var _ = Describe("withRegion", func() {
+	It("inherits the default region of the KeystoneAPI status unless overridden", func() {
+		keystoneAPI := &keystonev1.KeystoneAPI{
+			Spec: keystonev1.KeystoneAPISpec{
+				DefaultRegion: "regionOne",
+				RegionID:      "r1",
+			},
+		}
+
+		// without a status region the spec is used as it is
+		Expect(withRegion(keystoneAPI, "")).To(BeIdenticalTo(keystoneAPI))
+
+		keystoneAPI.Status.Region = "regionOne"
+		Expect(withRegion(keystoneAPI, "")).To(BeIdenticalTo(keystoneAPI))
+		Expect(withRegion(keystoneAPI, " regionOne ")).To(BeIdenticalTo(keystoneAPI))
+
+		regional := withRegion(keystoneAPI, "regionTwo")
+		Expect(regional.GetRegion()).To(Equal("regionTwo"))
+		Expect(regional.GetRegionID()).To(Equal("regionTwo"))
+		Expect(keystoneAPI.GetRegion()).To(Equal("regionOne"))
+		Expect(keystoneAPI.GetRegionID()).To(Equal("r1"))
+
+		// a status region not yet applied to the spec wins over the spec
+		keystoneAPI.Status.Region = "regionThree"
+		Expect(withRegion(keystoneAPI, "").GetRegion()).To(Equal("regionThree"))
+	})
+})
+
+var _ = Describe("registerFinalizer", func() {
+	AfterEach(func() {
+		keystonev1.Finalizer = ""
+	})
+
+	It("registers the configured finalizer unless it is disabled", func() {
+		s := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(s)).To(Succeed())
+		instance := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+		}
+		c := fake.NewClientBuilder().WithScheme(s).WithObjects(instance).Build()
+		h, err := helper.NewHelper(instance, c, nil, s, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(registerFinalizer(ctx, c, instance, h)).To(Succeed())
+		Expect(instance.Finalizers).To(Equal([]string{h.GetFinalizer()}))
+
+		By("renaming the finalizer")
+		keystonev1.Finalizer = "example.com/keystone-cleanup"
+		Expect(registerFinalizer(ctx, c, instance, h)).To(Succeed())
+		Expect(instance.Finalizers).To(ConsistOf(h.GetFinalizer(), "example.com/keystone-cleanup"))
+
+		By("opting out with the annotation")
+		instance.Annotations = map[string]string{keystonev1.SkipFinalizerAnnotation: "true"}
+		Expect(finalizerDisabled(instance)).To(BeTrue())
+		Expect(registerFinalizer(ctx, c, instance, h)).To(Succeed())
+		Expect(instance.Finalizers).To(BeEmpty())
+		Expect(hasFinalizer(instance, h)).To(BeFalse())
+
+		stored := &keystonev1.KeystoneService{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(instance), stored)).To(Succeed())
+		Expect(stored.Finalizers).To(BeEmpty())
+	})
+})
@@ -0,0 +1,94 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// reasons of the events recorded for the changes made in keystone
+const (
+	// ServiceCreatedReason - a service got registered in keystone
+	ServiceCreatedReason = "ServiceCreated"
+	// ServiceAdoptedReason - a service registered by someone else got adopted
+	ServiceAdoptedReason = "ServiceAdopted"
+	// ServiceUpdatedReason - a registered service got updated
+	ServiceUpdatedReason = "ServiceUpdated"
+	// ServiceDisabledReason - the services got disabled on delete
+	ServiceDisabledReason = "ServiceDisabled"
+	// ServiceDeletedReason - a service got deleted from keystone
+	ServiceDeletedReason = "ServiceDeleted"
+	// EndpointCreatedReason - an endpoint got registered in keystone
+	EndpointCreatedReason = "EndpointCreated"
+	// EndpointAdoptedReason - an endpoint got adopted by ID
+	EndpointAdoptedReason = "EndpointAdopted"
+	// EndpointUpdatedReason - a registered endpoint got updated
+	EndpointUpdatedReason = "EndpointUpdated"
+	// EndpointDisabledReason - the endpoints got disabled on delete
+	EndpointDisabledReason = "EndpointDisabled"
+	// EndpointDeletedReason - an endpoint got deleted from keystone
+	EndpointDeletedReason = "EndpointDeleted"
+	// ReconcileFailedReason - a reconcile failed without a more specific
+	// reason in the conditions
+	ReconcileFailedReason = "ReconcileFailed"
+)
+
+// recordEvent - records an event for obj, if there is a recorder
+func recordEvent(
+	recorder record.EventRecorder,
+	obj runtime.Object,
+	eventType string,
+	reason string,
+	messageFmt string,
+	args ...interface{},
+) {
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// recordFailure - records a warning event for the error a reconcile of obj
+// failed with. The reason is the one of the first false condition other than
+// the Ready condition, e.g. AuthenticationFailed, falling back to
+// ReconcileFailed.
+func recordFailure(
+	recorder record.EventRecorder,
+	obj runtime.Object,
+	conditions condition.Conditions,
+	err error,
+) {
+	if err == nil {
+		return
+	}
+
+	reason := ReconcileFailedReason
+	for _, c := range conditions {
+		if c.Type == condition.ReadyCondition || c.Status != corev1.ConditionFalse {
+			continue
+		}
+		if c.Reason != "" && c.Reason != condition.ErrorReason {
+			reason = string(c.Reason)
+		}
+		break
+	}
+
+	recordEvent(recorder, obj, corev1.EventTypeWarning, reason, "%s", err.Error())
+}
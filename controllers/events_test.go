@@ -0,0 +1,93 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("recordFailure", func() {
+	instance := &keystonev1.KeystoneService{
+		ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+	}
+
+	It("uses the reason of the failed condition", func() {
+		recorder := record.NewFakeRecorder(10)
+		conditions := condition.Conditions{}
+		conditions.Set(condition.FalseCondition(
+			keystonev1.AdminServiceClientReadyCondition,
+			keystonev1.AuthenticationFailedReason,
+			condition.SeverityWarning,
+			"%s", "rejected"))
+
+		recordFailure(recorder, instance, conditions, errors.New("401"))
+		Expect(<-recorder.Events).To(Equal("Warning AuthenticationFailed 401"))
+	})
+
+	It("falls back to ReconcileFailed", func() {
+		recorder := record.NewFakeRecorder(10)
+
+		recordFailure(recorder, instance, condition.Conditions{}, errors.New("boom"))
+		Expect(<-recorder.Events).To(Equal("Warning ReconcileFailed boom"))
+
+		recordFailure(recorder, instance, condition.Conditions{}, nil)
+		recordFailure(nil, instance, condition.Conditions{}, errors.New("boom"))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+})
+
+var _ = Describe("KeystoneEndpoint events", func() {
+	It("records the registration and update of an endpoint", func() {
+		os := newFakeIdentityClient("regionOne")
+		recorder := record.NewFakeRecorder(10)
+		r := &KeystoneEndpointReconciler{
+			Log:      ctrl.Log,
+			Recorder: recorder,
+			updates:  newUpdateTracker(time.Hour),
+		}
+		instance := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec:       keystonev1.KeystoneEndpointSpec{ServiceName: "placement"},
+		}
+		instance.Status.ServiceID = "svc"
+		keystoneAPI := &keystonev1.KeystoneAPI{}
+
+		reconcile := func(url string) {
+			allEndpoints, err := os.GetServiceEndpoints(r.Log, "svc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.reconcileEndpoint(instance, nil, keystoneAPI, os, allEndpoints, "public", url)).To(Succeed())
+		}
+
+		reconcile("http://placement")
+		Expect(<-recorder.Events).To(Equal("Normal EndpointCreated Endpoint public registered with ID 1: http://placement"))
+
+		reconcile("http://placement")
+		Expect(recorder.Events).To(BeEmpty())
+
+		reconcile("https://placement")
+		Expect(<-recorder.Events).To(Equal("Normal EndpointUpdated Endpoint public 1 updated: https://placement"))
+	})
+})
@@ -0,0 +1,664 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/endpoints"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/regions"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/services"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeIdentityClient - in memory keystone.IdentityClient recording the calls
+// which change the catalog, e.g. "CreateService placement"
+type fakeIdentityClient struct {
+	mu        sync.Mutex
+	region    string
+	nextID    int
+	services  map[string]services.Service
+	endpoints map[string]endpoints.Endpoint
+	regions   map[string]regions.Region
+	users     map[string]string
+	disabled  map[string]bool
+	implied   map[string][]string
+	groups    map[string]keystone.EndpointGroup
+	groupProj map[string]map[string]bool
+	calls     []string
+	clockSkew time.Duration
+	// lag - created services are only visible once replicated
+	lag     bool
+	lagging map[string]services.Service
+}
+
+var _ keystone.IdentityClient = &fakeIdentityClient{}
+
+func newFakeIdentityClient(region string) *fakeIdentityClient {
+	return &fakeIdentityClient{
+		region:    region,
+		services:  map[string]services.Service{},
+		endpoints: map[string]endpoints.Endpoint{},
+		regions:   map[string]regions.Region{},
+		users:     map[string]string{},
+		disabled:  map[string]bool{},
+		implied:   map[string][]string{},
+		groups:    map[string]keystone.EndpointGroup{},
+		groupProj: map[string]map[string]bool{},
+	}
+}
+
+// replicate - makes the services created with lag visible
+func (f *fakeIdentityClient) replicate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, s := range f.lagging {
+		f.services[id] = s
+	}
+	f.lagging = nil
+}
+
+// factory - returns an IdentityClientFactory always returning f
+func (f *fakeIdentityClient) factory() keystone.IdentityClientFactory {
+	return func(
+		ctx context.Context,
+		h *helper.Helper,
+		keystoneAPI *keystonev1.KeystoneAPI,
+	) (keystone.IdentityClient, ctrl.Result, error) {
+		return f, ctrl.Result{}, nil
+	}
+}
+
+// Calls - returns the recorded calls
+func (f *fakeIdentityClient) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.calls...)
+}
+
+// Endpoints - returns the URLs of the registered endpoints of the service by
+// endpoint type
+func (f *fakeIdentityClient) Endpoints(serviceID string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	urls := map[string]string{}
+	for _, e := range f.endpoints {
+		if e.ServiceID == serviceID {
+			urls[string(e.Availability)] = e.URL
+		}
+	}
+
+	return urls
+}
+
+func (f *fakeIdentityClient) record(format string, a ...interface{}) {
+	f.calls = append(f.calls, fmt.Sprintf(format, a...))
+}
+
+func (f *fakeIdentityClient) newID() string {
+	f.nextID++
+	return fmt.Sprintf("%d", f.nextID)
+}
+
+func (f *fakeIdentityClient) GetRegion() string {
+	return f.region
+}
+
+func (f *fakeIdentityClient) GetRegionID() string {
+	return f.region
+}
+
+func (f *fakeIdentityClient) GetAuthURL() string {
+	return "http://keystone.fake:5000/v3"
+}
+
+func (f *fakeIdentityClient) GetIdentityEndpoint() string {
+	return "http://keystone.fake:5000/v3/"
+}
+
+func (f *fakeIdentityClient) GetClockSkew() time.Duration {
+	return f.clockSkew
+}
+
+func (f *fakeIdentityClient) GetAPIVersion(log logr.Logger) (string, error) {
+	return "v3.14", nil
+}
+
+func (f *fakeIdentityClient) GetService(log logr.Logger, serviceType string, serviceName string) (*services.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.services {
+		if s.Type == serviceType && s.Extra["name"] == serviceName {
+			return &s, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (f *fakeIdentityClient) GetServicesByType(log logr.Logger, serviceType string) ([]services.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	allServices := []services.Service{}
+	for _, s := range f.services {
+		if s.Type == serviceType {
+			allServices = append(allServices, s)
+		}
+	}
+
+	return allServices, nil
+}
+
+func (f *fakeIdentityClient) GetServiceByID(log logr.Logger, serviceID string) (*services.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, ok := f.services[serviceID]; ok {
+		return &s, nil
+	}
+
+	return nil, nil
+}
+
+func (f *fakeIdentityClient) CreateService(log logr.Logger, s keystone.Service) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.newID()
+	service := services.Service{
+		ID:      id,
+		Type:    s.Type,
+		Enabled: s.Enabled,
+		Extra:   serviceExtra(s, nil),
+	}
+	if f.lag {
+		if f.lagging == nil {
+			f.lagging = map[string]services.Service{}
+		}
+		f.lagging[id] = service
+	} else {
+		f.services[id] = service
+	}
+	f.record("CreateService %s", s.Name)
+
+	return id, nil
+}
+
+func (f *fakeIdentityClient) UpdateService(log logr.Logger, s keystone.Service, serviceID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	service, ok := f.services[serviceID]
+	if !ok {
+		return gophercloud.ErrDefault404{}
+	}
+	// keystone merges the attributes into the stored ones
+	extra := map[string]interface{}{}
+	for k, v := range service.Extra {
+		extra[k] = v
+	}
+	for k, v := range serviceExtra(s, service.Extra["tags"]) {
+		extra[k] = v
+	}
+	f.services[serviceID] = services.Service{
+		ID:      serviceID,
+		Type:    s.Type,
+		Enabled: s.Enabled,
+		Extra:   extra,
+	}
+	f.record("UpdateService %s", s.Name)
+
+	return nil
+}
+
+// serviceExtra - returns the extra attributes of s like keystone stores them,
+// tags are kept if s has none set
+func serviceExtra(s keystone.Service, tags interface{}) map[string]interface{} {
+	if s.Tags != nil {
+		values := []interface{}{}
+		for _, tag := range s.Tags {
+			values = append(values, tag)
+		}
+		tags = values
+	}
+
+	extra := map[string]interface{}{
+		"name":        s.Name,
+		"description": s.Description,
+	}
+	if tags != nil {
+		extra["tags"] = tags
+	}
+	if s.DomainID != "" {
+		extra["domain_id"] = s.DomainID
+	}
+	for locale, description := range s.LocalizedDescriptions {
+		extra["description_"+locale] = description
+	}
+
+	return extra
+}
+
+func (f *fakeIdentityClient) DeleteService(log logr.Logger, serviceID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.services, serviceID)
+	f.record("DeleteService %s", serviceID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) GetDomainID(log logr.Logger, domainName string) (string, error) {
+	return "domain-" + domainName, nil
+}
+
+func (f *fakeIdentityClient) GetEndpoints(log logr.Logger, serviceID string, availability gophercloud.Availability) ([]endpoints.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	allEndpoints := []endpoints.Endpoint{}
+	for _, e := range f.endpoints {
+		if e.ServiceID == serviceID && e.Region == f.region &&
+			(availability == "" || e.Availability == availability) {
+			allEndpoints = append(allEndpoints, e)
+		}
+	}
+
+	return allEndpoints, nil
+}
+
+func (f *fakeIdentityClient) GetServiceEndpoints(log logr.Logger, serviceID string) ([]endpoints.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	allEndpoints := []endpoints.Endpoint{}
+	for _, e := range f.endpoints {
+		if e.ServiceID == serviceID {
+			allEndpoints = append(allEndpoints, e)
+		}
+	}
+
+	return allEndpoints, nil
+}
+
+func (f *fakeIdentityClient) GetEndpoint(log logr.Logger, endpointID string) (*endpoints.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if e, ok := f.endpoints[endpointID]; ok {
+		return &e, nil
+	}
+
+	return nil, gophercloud.ErrDefault404{}
+}
+
+func (f *fakeIdentityClient) GetCatalogEndpoints(log logr.Logger, serviceID string) (map[string]string, error) {
+	return f.Endpoints(serviceID), nil
+}
+
+func (f *fakeIdentityClient) CreateEndpoint(log logr.Logger, e keystone.Endpoint) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.newID()
+	f.endpoints[id] = endpoints.Endpoint{
+		ID:           id,
+		Availability: e.Availability,
+		Name:         e.Name,
+		Region:       f.region,
+		ServiceID:    e.ServiceID,
+		URL:          e.URL,
+	}
+	f.record("CreateEndpoint %s %s", e.Name, e.Availability)
+
+	return id, nil
+}
+
+func (f *fakeIdentityClient) UpdateEndpoint(log logr.Logger, e keystone.Endpoint, endpointID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	endpoint, ok := f.endpoints[endpointID]
+	if !ok {
+		return "", gophercloud.ErrDefault404{}
+	}
+	if e.Name != "" {
+		endpoint.Name = e.Name
+	}
+	if e.URL != "" {
+		endpoint.URL = e.URL
+	}
+	f.endpoints[endpointID] = endpoint
+	f.record("UpdateEndpoint %s %s", endpoint.Name, endpoint.Availability)
+
+	return endpointID, nil
+}
+
+func (f *fakeIdentityClient) SetServiceEndpointsEnabled(log logr.Logger, serviceID string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, e := range f.endpoints {
+		if e.ServiceID != serviceID || f.disabled[id] == !enabled {
+			continue
+		}
+		f.disabled[id] = !enabled
+		f.record("SetEndpointEnabled %s %t", id, enabled)
+	}
+
+	return nil
+}
+
+func (f *fakeIdentityClient) GetServiceEndpointsEnabled(log logr.Logger, serviceID string) (map[string]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	endpointsEnabled := map[string]bool{}
+	for id, e := range f.endpoints {
+		if e.ServiceID == serviceID {
+			endpointsEnabled[id] = !f.disabled[id]
+		}
+	}
+
+	return endpointsEnabled, nil
+}
+
+func (f *fakeIdentityClient) SetEndpointEnabled(log logr.Logger, endpointID string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.endpoints[endpointID]; !ok {
+		return gophercloud.ErrDefault404{}
+	}
+	f.disabled[endpointID] = !enabled
+	f.record("SetEndpointEnabled %s %t", endpointID, enabled)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) DeleteEndpoint(log logr.Logger, e keystone.Endpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, endpoint := range f.endpoints {
+		if endpoint.ServiceID == e.ServiceID && endpoint.Region == f.region &&
+			endpoint.Availability == e.Availability {
+			delete(f.endpoints, id)
+			f.record("DeleteEndpoint %s %s", e.Name, e.Availability)
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeIdentityClient) DeleteEndpointByID(log logr.Logger, endpointID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.endpoints, endpointID)
+	f.record("DeleteEndpointByID %s", endpointID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) AddEndpointToProject(log logr.Logger, projectID string, endpointID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.record("AddEndpointToProject %s %s", projectID, endpointID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) RemoveEndpointFromProject(log logr.Logger, projectID string, endpointID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.record("RemoveEndpointFromProject %s %s", projectID, endpointID)
+
+	return nil
+}
+
+// EndpointGroupProjectIDs - returns the IDs of the projects the endpoint
+// group with name is associated with
+func (f *fakeIdentityClient) EndpointGroupProjectIDs(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	projectIDs := []string{}
+	for id, g := range f.groups {
+		if g.Name != name {
+			continue
+		}
+		for projectID := range f.groupProj[id] {
+			projectIDs = append(projectIDs, projectID)
+		}
+	}
+	sort.Strings(projectIDs)
+
+	return projectIDs
+}
+
+func (f *fakeIdentityClient) GetEndpointGroup(log logr.Logger, name string) (*keystone.EndpointGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, g := range f.groups {
+		if g.Name == name {
+			return &g, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (f *fakeIdentityClient) CreateEndpointGroup(log logr.Logger, g keystone.EndpointGroup) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g.ID = f.newID()
+	f.groups[g.ID] = g
+	f.record("CreateEndpointGroup %s", g.Name)
+
+	return g.ID, nil
+}
+
+func (f *fakeIdentityClient) UpdateEndpointGroup(log logr.Logger, g keystone.EndpointGroup, groupID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.groups[groupID]; !ok {
+		return gophercloud.ErrDefault404{}
+	}
+	g.ID = groupID
+	f.groups[groupID] = g
+	f.record("UpdateEndpointGroup %s", g.Name)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) DeleteEndpointGroup(log logr.Logger, groupID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.groups, groupID)
+	delete(f.groupProj, groupID)
+	f.record("DeleteEndpointGroup %s", groupID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) GetEndpointGroupProjectIDs(log logr.Logger, groupID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	projectIDs := []string{}
+	for projectID := range f.groupProj[groupID] {
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	return projectIDs, nil
+}
+
+func (f *fakeIdentityClient) AddEndpointGroupToProject(log logr.Logger, groupID string, projectID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.groupProj[groupID] == nil {
+		f.groupProj[groupID] = map[string]bool{}
+	}
+	f.groupProj[groupID][projectID] = true
+	f.record("AddEndpointGroupToProject %s %s", groupID, projectID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) RemoveEndpointGroupFromProject(log logr.Logger, groupID string, projectID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.groupProj[groupID], projectID)
+	f.record("RemoveEndpointGroupFromProject %s %s", groupID, projectID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) FindRegion(log logr.Logger, regionID string) (*regions.Region, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r, ok := f.regions[regionID]; ok {
+		return &r, nil
+	}
+
+	return nil, nil
+}
+
+func (f *fakeIdentityClient) CreateRegion(log logr.Logger, r keystone.Region) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.regions[r.ID] = regions.Region{
+		ID:             r.ID,
+		Description:    r.Description,
+		ParentRegionID: r.ParentRegionID,
+	}
+	f.record("CreateRegion %s", r.ID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) UpdateRegion(log logr.Logger, r keystone.Region) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	region := f.regions[r.ID]
+	region.ParentRegionID = r.ParentRegionID
+	f.regions[r.ID] = region
+	f.record("UpdateRegion %s", r.ID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) CreateProject(log logr.Logger, p keystone.Project) (string, error) {
+	return "project-" + p.Name, nil
+}
+
+func (f *fakeIdentityClient) CreateRole(log logr.Logger, roleName string) (string, error) {
+	return "role-" + roleName, nil
+}
+
+// ImpliedRoles - returns the IDs of the roles the role with priorRoleID implies
+func (f *fakeIdentityClient) ImpliedRoles(priorRoleID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.implied[priorRoleID]...)
+}
+
+func (f *fakeIdentityClient) GetImpliedRoleIDs(log logr.Logger, priorRoleID string) ([]string, error) {
+	return f.ImpliedRoles(priorRoleID), nil
+}
+
+func (f *fakeIdentityClient) CreateImpliedRole(log logr.Logger, priorRoleID string, impliedRoleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.implied[priorRoleID] = append(f.implied[priorRoleID], impliedRoleID)
+	f.record("CreateImpliedRole %s %s", priorRoleID, impliedRoleID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) DeleteImpliedRole(log logr.Logger, priorRoleID string, impliedRoleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	implied := []string{}
+	for _, id := range f.implied[priorRoleID] {
+		if id != impliedRoleID {
+			implied = append(implied, id)
+		}
+	}
+	f.implied[priorRoleID] = implied
+	f.record("DeleteImpliedRole %s %s", priorRoleID, impliedRoleID)
+
+	return nil
+}
+
+func (f *fakeIdentityClient) CreateUser(log logr.Logger, u keystone.User) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.users[u.Name]; ok {
+		return id, nil
+	}
+	id := f.newID()
+	f.users[u.Name] = id
+	f.record("CreateUser %s", u.Name)
+
+	return id, nil
+}
+
+func (f *fakeIdentityClient) AssignUserRole(log logr.Logger, roleName string, userID string, projectID string) error {
+	return nil
+}
+
+func (f *fakeIdentityClient) DeleteUser(log logr.Logger, userName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.users, userName)
+	f.record("DeleteUser %s", userName)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// endpointUpdates - number of updates of registered endpoints, an endpoint
+// updated on every reconcile points at an update loop
+var endpointUpdates = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "keystone_operator_endpoint_updates_total",
+		Help: "Number of updates of the keystone endpoints of a KeystoneEndpoint",
+	},
+	[]string{"namespace", "name", "interface"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(endpointUpdates)
+}
+
+// updateTracker - counts the updates per key within a sliding window, a nil
+// updateTracker counts nothing
+type updateTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	updates map[string][]time.Time
+}
+
+func newUpdateTracker(window time.Duration) *updateTracker {
+	return &updateTracker{
+		window:  window,
+		updates: map[string][]time.Time{},
+	}
+}
+
+// Record - records an update of key at now and returns the number of
+// updates of key within the window
+func (t *updateTracker) Record(key string, now time.Time) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.updates[key] = append(t.expire(key, now), now)
+
+	return len(t.updates[key])
+}
+
+// Count - returns the number of updates of key within the window
+func (t *updateTracker) Count(key string, now time.Time) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	updates := t.expire(key, now)
+	if len(updates) == 0 {
+		delete(t.updates, key)
+	} else {
+		t.updates[key] = updates
+	}
+
+	return len(updates)
+}
+
+// Forget - drops the updates of key
+func (t *updateTracker) Forget(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.updates, key)
+}
+
+// expire - returns the updates of key within the window before now
+func (t *updateTracker) expire(key string, now time.Time) []time.Time {
+	updates := t.updates[key]
+	for len(updates) > 0 && now.Sub(updates[0]) >= t.window {
+		updates = updates[1:]
+	}
+
+	return updates
+}
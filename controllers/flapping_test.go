@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("updateTracker", func() {
+	It("counts the updates within the window", func() {
+		t := newUpdateTracker(time.Minute)
+		now := time.Now()
+
+		Expect(t.Record("a", now.Add(-2*time.Minute))).To(Equal(1))
+		Expect(t.Record("a", now.Add(-30*time.Second))).To(Equal(1))
+		Expect(t.Record("a", now)).To(Equal(2))
+		Expect(t.Count("b", now)).To(Equal(0))
+		Expect(t.Count("a", now.Add(45*time.Second))).To(Equal(1))
+
+		t.Forget("a")
+		Expect(t.Count("a", now)).To(Equal(0))
+	})
+})
+
+var _ = Describe("reportFlapping", func() {
+	It("sets the condition and emits an event once", func() {
+		instance := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+		}
+		instance.Status.Conditions = condition.Conditions{}
+		s := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(s)).To(Succeed())
+		h, err := helper.NewHelper(instance, fake.NewClientBuilder().WithScheme(s).Build(), nil, s, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder := record.NewFakeRecorder(10)
+		r := &KeystoneEndpointReconciler{
+			Log:               ctrl.Log,
+			FlappingThreshold: 2,
+			FlappingWindow:    time.Hour,
+			Recorder:          recorder,
+			updates:           newUpdateTracker(time.Hour),
+		}
+
+		r.recordEndpointUpdate(instance, "public")
+		r.recordEndpointUpdate(instance, "public")
+		r.reportFlapping(instance, h)
+		Expect(instance.Status.Conditions.IsTrue(keystonev1.KeystoneServiceOSEndpointsStableCondition)).To(BeTrue())
+
+		r.recordEndpointUpdate(instance, "public")
+		r.reportFlapping(instance, h)
+		c := instance.Status.Conditions.Get(keystonev1.KeystoneServiceOSEndpointsStableCondition)
+		Expect(c.Reason).To(Equal(keystonev1.EndpointFlappingReason))
+		Expect(c.Message).To(ContainSubstring("public (3 updates)"))
+		Expect(recorder.Events).To(HaveLen(1))
+
+		r.recordEndpointUpdate(instance, "public")
+		r.reportFlapping(instance, h)
+		Expect(recorder.Events).To(HaveLen(1))
+	})
+})
@@ -78,6 +78,9 @@ type KeystoneAPIReconciler struct {
 	Kclient kubernetes.Interface
 	Log     logr.Logger
 	Scheme  *runtime.Scheme
+	// ClientOptions - operator level options of the admin clients, the
+	// clouds.yaml gets the same domain defaults
+	ClientOptions keystone.ClientOptions
 }
 
 // +kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneapis,verbs=get;list;watch;create;update;patch;delete
@@ -127,7 +130,7 @@ func (r *KeystoneAPIReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		instance.Status.Conditions.Init(&cl)
 
 		// Register overall status immediately to have an early feedback e.g. in the cli
-		if err := r.Status().Update(ctx, instance); err != nil {
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
@@ -307,7 +310,7 @@ func (r *KeystoneAPIReconciler) reconcileInit(
 	}
 	if dbSyncjob.HasChanged() {
 		instance.Status.Hash[keystonev1.DbSyncHash] = dbSyncjob.GetHash()
-		if err := r.Client.Status().Update(ctx, instance); err != nil {
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
 			return ctrl.Result{}, err
 		}
 		r.Log.Info(fmt.Sprintf("Job %s hash added - %s", jobDef.Name, instance.Status.Hash[keystonev1.DbSyncHash]))
@@ -401,11 +404,13 @@ func (r *KeystoneAPIReconciler) reconcileInit(
 	}
 	if bootstrapjob.HasChanged() {
 		instance.Status.Hash[keystonev1.BootstrapHash] = bootstrapjob.GetHash()
-		if err := r.Client.Status().Update(ctx, instance); err != nil {
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
 			return ctrl.Result{}, err
 		}
 		r.Log.Info(fmt.Sprintf("Job %s hash added - %s", jobDef.Name, instance.Status.Hash[keystonev1.BootstrapHash]))
 	}
+	// the region got created by the bootstrap, expose it as default region
+	instance.Status.Region = instance.GetRegion()
 	instance.Status.Conditions.MarkTrue(condition.BootstrapReadyCondition, condition.BootstrapReadyMessage)
 
 	// run keystone bootstrap - end
@@ -674,9 +679,9 @@ func (r *KeystoneAPIReconciler) reconcileConfigMap(ctx context.Context, instance
 	openStackConfig.Clouds.Default.Auth.AuthURL = authURL
 	openStackConfig.Clouds.Default.Auth.ProjectName = instance.Spec.AdminProject
 	openStackConfig.Clouds.Default.Auth.UserName = instance.Spec.AdminUser
-	openStackConfig.Clouds.Default.Auth.UserDomainName = "Default"
-	openStackConfig.Clouds.Default.Auth.ProjectDomainName = "Default"
-	openStackConfig.Clouds.Default.RegionName = instance.Spec.Region
+	openStackConfig.Clouds.Default.Auth.UserDomainName = r.ClientOptions.GetDomainName(instance.GetAdminUserDomain())
+	openStackConfig.Clouds.Default.Auth.ProjectDomainName = r.ClientOptions.GetDomainName(instance.GetAdminProjectDomain())
+	openStackConfig.Clouds.Default.RegionName = instance.GetRegion()
 
 	cloudsYamlVal, err := yaml.Marshal(&openStackConfig)
 	if err != nil {
@@ -819,7 +824,7 @@ func (r *KeystoneAPIReconciler) createHashOfInputHashes(
 	}
 	if hashMap, changed := util.SetHash(instance.Status.Hash, common.InputHashName, hash); changed {
 		instance.Status.Hash = hashMap
-		if err := r.Client.Status().Update(ctx, instance); err != nil {
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
 			return hash, err
 		}
 		r.Log.Info(fmt.Sprintf("Input maps hash %s - %s", common.InputHashName, hash))
@@ -0,0 +1,320 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// KeystoneCatalogReconciler reconciles a KeystoneCatalog object into
+// KeystoneServices and KeystoneEndpoints
+type KeystoneCatalogReconciler struct {
+	client.Client
+	Kclient kubernetes.Interface
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystonecatalogs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystonecatalogs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystonecatalogs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservices,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneendpoints,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile keystone catalog requests
+func (r *KeystoneCatalogReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling", "keystonecatalog", req.NamespacedName)
+
+	// Fetch the KeystoneCatalog instance
+	instance := &keystonev1.KeystoneCatalog{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, err
+	}
+
+	//
+	// initialize status
+	//
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = condition.Conditions{}
+		cl := condition.CreateList(
+			condition.UnknownCondition(keystonev1.KeystoneCatalogServicesReadyCondition, condition.InitReason, keystonev1.KeystoneCatalogServicesReadyInitMessage))
+		instance.Status.Conditions.Init(&cl)
+
+		// Register overall status immediately to have an early feedback e.g. in the cli
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	helper, err := helper.NewHelper(
+		instance,
+		r.Client,
+		r.Kclient,
+		r.Scheme,
+		r.Log,
+	)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Always patch the instance status when exiting this function so we can persist any changes.
+	defer func() {
+		// update the overall status condition if the catalog is ready
+		if instance.IsReady() {
+			instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
+		}
+
+		if err := helper.SetAfter(instance); err != nil {
+			util.LogErrorForObject(helper, err, "Set after and calc patch/diff", instance)
+		}
+
+		if changed := helper.GetChanges()["status"]; changed {
+			patch := client.MergeFrom(helper.GetBeforeObject())
+
+			if err := r.Status().Patch(ctx, instance, patch); err != nil && !k8s_errors.IsNotFound(err) {
+				util.LogErrorForObject(helper, err, "Update status", instance)
+			}
+		}
+	}()
+
+	// Handle catalog delete
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, instance, helper)
+	}
+
+	// Handle non-deleted clusters
+	return r.reconcileNormal(ctx, instance, helper)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KeystoneCatalogReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&keystonev1.KeystoneCatalog{}).
+		Owns(&keystonev1.KeystoneService{}).
+		Owns(&keystonev1.KeystoneEndpoint{}).
+		Complete(r)
+}
+
+func (r *KeystoneCatalogReconciler) reconcileDelete(
+	ctx context.Context,
+	instance *keystonev1.KeystoneCatalog,
+	helper *helper.Helper,
+) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling Catalog delete", "instance", instance.Name)
+
+	// delete all KeystoneServices and KeystoneEndpoints of the catalog
+	if err := r.deleteRemoved(ctx, instance, helper, map[string]bool{}, map[string]bool{}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Services are deleted so remove the finalizer.
+	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
+	r.Log.V(1).Info("Reconciled Catalog delete successfully", "instance", instance.Name)
+	if err := r.Update(ctx, instance); err != nil && !k8s_errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KeystoneCatalogReconciler) reconcileNormal(
+	ctx context.Context,
+	instance *keystonev1.KeystoneCatalog,
+	helper *helper.Helper,
+) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling Catalog", "instance", instance.Name)
+
+	// If the catalog object doesn't have our finalizer, add it.
+	controllerutil.AddFinalizer(instance, helper.GetFinalizer())
+	// Register the finalizer immediately to avoid orphaning resources on delete
+	if err := r.Update(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	labels := map[string]string{
+		keystonev1.KeystoneCatalogLabel: instance.Name,
+	}
+
+	services := map[string]bool{}
+	endpoints := map[string]bool{}
+	serviceIDs := map[string]string{}
+	notReady := []string{}
+	var ctrlResult ctrl.Result
+	for _, svc := range instance.Spec.Services {
+		services[svc.ServiceName] = true
+
+		ksSvc := keystonev1.NewKeystoneService(svc.KeystoneServiceSpec, instance.Namespace, labels, 10)
+		svcResult, err := ksSvc.CreateOrPatch(ctx, helper)
+		if err != nil {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				keystonev1.KeystoneCatalogServicesReadyCondition,
+				condition.ErrorReason,
+				condition.SeverityWarning,
+				keystonev1.KeystoneCatalogServicesReadyErrorMessage,
+				err.Error()))
+			return ctrl.Result{}, err
+		}
+		if (svcResult != ctrl.Result{}) {
+			ctrlResult = svcResult
+		}
+		ready := ksSvc.GetConditions().IsTrue(condition.ReadyCondition)
+		if serviceID := ksSvc.GetServiceID(); serviceID != "" {
+			serviceIDs[svc.ServiceName] = serviceID
+		}
+
+		if len(svc.Endpoints) > 0 {
+			endpoints[svc.ServiceName] = true
+
+			ksEndpt := keystonev1.NewKeystoneEndpoint(
+				svc.ServiceName,
+				instance.Namespace,
+				keystonev1.KeystoneEndpointSpec{
+					ServiceName:          svc.ServiceName,
+					Endpoints:            svc.Endpoints,
+					KeystoneAPINamespace: svc.KeystoneAPINamespace,
+				},
+				labels,
+				10)
+			endptResult, err := ksEndpt.CreateOrPatch(ctx, helper)
+			if err != nil {
+				instance.Status.Conditions.Set(condition.FalseCondition(
+					keystonev1.KeystoneCatalogServicesReadyCondition,
+					condition.ErrorReason,
+					condition.SeverityWarning,
+					keystonev1.KeystoneCatalogServicesReadyErrorMessage,
+					err.Error()))
+				return ctrl.Result{}, err
+			}
+			if (endptResult != ctrl.Result{}) {
+				ctrlResult = endptResult
+			}
+			ready = ready && ksEndpt.GetConditions().IsTrue(condition.ReadyCondition)
+		}
+
+		if !ready {
+			notReady = append(notReady, svc.ServiceName)
+		}
+	}
+
+	//
+	// delete the KeystoneServices and KeystoneEndpoints removed from the catalog
+	//
+	if err := r.deleteRemoved(ctx, instance, helper, services, endpoints); err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneCatalogServicesReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			keystonev1.KeystoneCatalogServicesReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	instance.Status.ServiceIDs = serviceIDs
+	instance.Status.ReadyCount = len(instance.Spec.Services) - len(notReady)
+	if len(notReady) > 0 {
+		// the catalog gets reconciled again when the owned services change
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneCatalogServicesReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneCatalogServicesReadyWaitingMessage,
+			strings.Join(notReady, ", ")))
+		return ctrlResult, nil
+	}
+	instance.Status.Conditions.MarkTrue(
+		keystonev1.KeystoneCatalogServicesReadyCondition,
+		keystonev1.KeystoneCatalogServicesReadyMessage)
+	instance.Status.ObservedGeneration = instance.Generation
+
+	r.Log.V(1).Info("Reconciled Catalog successfully", "instance", instance.Name)
+	return ctrlResult, nil
+}
+
+// deleteRemoved - deletes the KeystoneServices and KeystoneEndpoints of the
+// catalog which are not in services and endpoints, by name
+func (r *KeystoneCatalogReconciler) deleteRemoved(
+	ctx context.Context,
+	instance *keystonev1.KeystoneCatalog,
+	helper *helper.Helper,
+	services map[string]bool,
+	endpoints map[string]bool,
+) error {
+	listOpts := []client.ListOption{
+		client.InNamespace(instance.Namespace),
+		client.MatchingLabels{keystonev1.KeystoneCatalogLabel: instance.Name},
+	}
+
+	// endpoints first, their KeystoneService is needed to delete them in keystone
+	endpointList := &keystonev1.KeystoneEndpointList{}
+	if err := r.List(ctx, endpointList, listOpts...); err != nil {
+		return err
+	}
+	removed := []string{}
+	for _, e := range endpointList.Items {
+		if !endpoints[e.Name] {
+			removed = append(removed, e.Name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		if err := keystonev1.DeleteKeystoneEndpointWithName(ctx, helper, name, instance.Namespace); err != nil {
+			return err
+		}
+	}
+
+	serviceList := &keystonev1.KeystoneServiceList{}
+	if err := r.List(ctx, serviceList, listOpts...); err != nil {
+		return err
+	}
+	removed = []string{}
+	for _, s := range serviceList.Items {
+		if !services[s.Name] {
+			removed = append(removed, s.Name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		if err := keystonev1.DeleteKeystoneServiceWithName(ctx, helper, name, instance.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
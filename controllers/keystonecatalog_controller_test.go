@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("KeystoneCatalog controller", func() {
+	var namespace string
+
+	BeforeEach(func() {
+		skipWithoutEnvtest()
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "osp-secret",
+				Namespace: namespace,
+			},
+			StringData: map[string]string{
+				"GlancePassword": "12345678",
+				"SwiftPassword":  "12345678",
+			},
+		})).To(Succeed())
+
+		createReadyKeystoneAPI(namespace)
+	})
+
+	It("registers the services of the catalog and deletes removed ones", func() {
+		catalogService := func(serviceType string, serviceName string, password string) keystonev1.KeystoneCatalogService {
+			return keystonev1.KeystoneCatalogService{
+				KeystoneServiceSpec: keystonev1.KeystoneServiceSpec{
+					ServiceType:      serviceType,
+					ServiceName:      serviceName,
+					Enabled:          true,
+					ServiceUser:      serviceName,
+					Secret:           "osp-secret",
+					PasswordSelector: password,
+				},
+				Endpoints: map[string]string{
+					"public": "https://" + serviceName + ".example.com",
+				},
+			}
+		}
+		catalog := &keystonev1.KeystoneCatalog{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "catalog",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneCatalogSpec{
+				Services: []keystonev1.KeystoneCatalogService{
+					catalogService("image", "glance", "GlancePassword"),
+					catalogService("object-store", "swift", "SwiftPassword"),
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, catalog)).To(Succeed())
+
+		catalogKey := types.NamespacedName{Name: "catalog", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, catalogKey, catalog); err != nil {
+				return false
+			}
+			return catalog.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(catalog.Status.ReadyCount).To(Equal(2))
+		Expect(catalog.Status.ServiceIDs).To(HaveKey("glance"))
+		Expect(catalog.Status.ServiceIDs).To(HaveKey("swift"))
+		Expect(identityClient.Endpoints(catalog.Status.ServiceIDs["swift"])).To(Equal(
+			map[string]string{"public": "https://swift.example.com"}))
+
+		By("removing a service from the catalog")
+		catalog.Spec.Services = catalog.Spec.Services[:1]
+		Expect(k8sClient.Update(ctx, catalog)).To(Succeed())
+		Eventually(func() bool {
+			return k8s_errors.IsNotFound(k8sClient.Get(ctx,
+				types.NamespacedName{Name: "swift", Namespace: namespace}, &keystonev1.KeystoneService{}))
+		}, timeout, interval).Should(BeTrue())
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, catalogKey, catalog); err != nil {
+				return false
+			}
+			return catalog.IsReady() && catalog.Status.ReadyCount == 1
+		}, timeout, interval).Should(BeTrue())
+	})
+})
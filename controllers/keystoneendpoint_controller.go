@@ -18,31 +18,78 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	goos "os"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/endpoints"
+	routev1 "github.com/openshift/api/route/v1"
 	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
 	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	endpoint "github.com/openstack-k8s-operators/lib-common/modules/common/endpoint"
 	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
 	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
 	openstack "github.com/openstack-k8s-operators/lib-common/modules/openstack"
+	"go.opentelemetry.io/otel/trace"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
+// endpointTypes - the endpoint interfaces known to keystone
+var endpointTypes = []string{
+	string(endpoint.EndpointAdmin),
+	string(endpoint.EndpointInternal),
+	string(endpoint.EndpointPublic),
+}
+
 // KeystoneEndpointReconciler reconciles a KeystoneEndpoint object
 type KeystoneEndpointReconciler struct {
 	client.Client
 	Kclient kubernetes.Interface
 	Log     logr.Logger
 	Scheme  *runtime.Scheme
+	// NewIdentityClient - creates the admin keystone client, defaults to
+	// keystone.NewAdminIdentityClientFactory with the ClientOptions
+	NewIdentityClient keystone.IdentityClientFactory
+	// ClientOptions - operator level options of the admin clients
+	ClientOptions keystone.ClientOptions
+	// AuthBreaker - optional circuit breaker shared by the reconcilers to stop
+	// authenticating against an unavailable keystone after repeated failures
+	AuthBreaker *keystone.CircuitBreaker
+	// ReconcileLock - optional lock shared by the reconcilers to serialize
+	// the keystone requests of reconciles of the same target
+	ReconcileLock *ReconcileLock
+	// ResyncPeriod - optional interval to requeue reconciled instances after,
+	// to correct changes made in keystone out-of-band
+	ResyncPeriod time.Duration
+	// FlappingThreshold - optional number of updates of an endpoint within
+	// the FlappingWindow above which it is reported as flapping
+	FlappingThreshold int
+	// FlappingWindow - the window the updates of an endpoint are counted in
+	FlappingWindow time.Duration
+	// Recorder - optional recorder of the events of the instances
+	Recorder record.EventRecorder
+
+	updates *updateTracker
 }
 
 //+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneendpoints,verbs=get;list;watch;create;update;patch;delete
@@ -50,10 +97,22 @@ type KeystoneEndpointReconciler struct {
 //+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneendpoints/finalizers,verbs=update
 //+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneapis,verbs=get;list
 //+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservices,verbs=get;list
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps;secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile keystone endpoint requests
-func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
 	_ = log.FromContext(ctx)
+	ctx = detachContext(ctx)
+	ctx, requestID := withRequestID(ctx)
+	r.Log.V(1).Info("Reconciling", "keystoneendpoint", req.NamespacedName, "requestID", requestID.ID)
+
+	ctx, span := startSpan(ctx, "KeystoneEndpoint.Reconcile")
+	defer func() {
+		endSpan(span, _err)
+	}()
 
 	// Fetch the KeystoneEndpoint instance
 	instance := &keystonev1.KeystoneEndpoint{}
@@ -68,6 +127,7 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
+	span.SetAttributes(serviceNameAttr.String(instance.GetCatalogServiceName()))
 
 	//
 	// initialize status
@@ -84,7 +144,7 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		instance.Status.Conditions.Init(&cl)
 
 		// Register overall status immediately to have an early feedback e.g. in the cli
-		if err := r.Status().Update(ctx, instance); err != nil {
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
@@ -100,6 +160,12 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	// a deleted endpoint without the finalizer, e.g. with the finalizer disabled,
+	// is left to get removed, its resources in keystone are kept
+	if !instance.DeletionTimestamp.IsZero() && !hasFinalizer(instance, helper) {
+		return ctrl.Result{}, nil
+	}
+
 	// Always patch the instance status when exiting this function so we can persist any changes.
 	defer func() {
 		// update the overall status condition if endpoints are ready
@@ -107,6 +173,14 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
 		}
 
+		recordFailure(r.Recorder, instance, instance.Status.Conditions, _err)
+
+		// the failed attempts of the endpoints are counted apart from the
+		// ones of their KeystoneService
+		if instance.DeletionTimestamp.IsZero() {
+			result, _err = r.countFailedAttempt(instance, result, _err)
+		}
+
 		if err := helper.SetAfter(instance); err != nil {
 			util.LogErrorForObject(helper, err, "Set after and calc patch/diff", instance)
 		}
@@ -120,10 +194,19 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}()
 
+	// failed endpoints only get retried on a spec change or after the failed
+	// retry period
+	if instance.DeletionTimestamp.IsZero() && !reauthRequested(instance) {
+		if requeueAfter, failed := r.isFailed(instance); failed {
+			r.Log.V(1).Info("Endpoints failed, waiting for a spec change", "instance", instance.Name)
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+	}
+
 	//
 	// Validate that keystoneAPI is up
 	//
-	keystoneAPI, err := keystonev1.GetKeystoneAPI(ctx, helper, instance.Namespace, map[string]string{})
+	keystoneAPI, err := keystonev1.GetKeystoneAPI(ctx, helper, instance.GetKeystoneAPINamespace(), map[string]string{})
 	if err != nil {
 		if k8s_errors.IsNotFound(err) {
 			instance.Status.Conditions.Set(condition.FalseCondition(
@@ -145,6 +228,19 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	// without status the controller of the KeystoneAPI did not run yet, e.g.
+	// it is not deployed, which takes longer than a deployment getting ready
+	if !keystoneAPI.HasStatus() {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneAPIReadyCondition,
+			keystonev1.WaitingForAPIControllerReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneAPIReadyWaitingControllerMessage))
+		util.LogForObject(helper, "KeystoneAPI status not yet populated", instance)
+
+		return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	}
+
 	if !keystoneAPI.IsReady() {
 		instance.Status.Conditions.Set(condition.FalseCondition(
 			keystonev1.KeystoneAPIReadyCondition,
@@ -155,23 +251,50 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
 	}
+	// the admin user the client authenticates with gets created by the bootstrap
+	if !keystoneAPI.IsBootstrapped() {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneAPIReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneAPIReadyWaitingBootstrapMessage))
+		util.LogForObject(helper, "KeystoneAPI bootstrap not yet completed", instance)
+
+		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
 	instance.Status.Conditions.MarkTrue(keystonev1.KeystoneAPIReadyCondition, keystonev1.KeystoneAPIReadyMessage)
 
+	// serialize the keystone requests with the reconciles of the KeystoneService
+	defer r.ReconcileLock.Lock(keystoneAPI, instance.Namespace+"/"+instance.Spec.ServiceName)()
+
 	//
 	// get admin authentication OpenStack
 	//
-	os, ctrlResult, err := keystonev1.GetAdminServiceClient(
+	// a requested re-authentication bypasses the circuit breaker
+	forceReauth := reauthRequested(instance)
+	if allowed, wait := r.AuthBreaker.Allow(authBreakerKey(keystoneAPI)); !allowed && !forceReauth {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.AdminServiceClientReadyCondition,
+			keystonev1.KeystoneUnavailableReason,
+			condition.SeverityWarning,
+			keystonev1.AdminServiceClientReadyKeystoneUnavailableMessage,
+			wait.Round(time.Second)))
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	os, ctrlResult, err := getIdentityClient(
 		ctx,
 		helper,
 		keystoneAPI,
+		identityClientFactory(r.NewIdentityClient, r.ClientOptions),
 	)
 	if err != nil {
-		instance.Status.Conditions.Set(condition.FalseCondition(
+		if keystone.IsUnavailable(err) {
+			r.AuthBreaker.Failure(authBreakerKey(keystoneAPI))
+		}
+		instance.Status.Conditions.Set(keystoneErrorCondition(
 			keystonev1.AdminServiceClientReadyCondition,
-			condition.ErrorReason,
-			condition.SeverityWarning,
 			keystonev1.AdminServiceClientReadyErrorMessage,
-			err.Error()))
+			err))
 		return ctrl.Result{}, err
 	}
 	if (ctrlResult != ctrl.Result{}) {
@@ -182,62 +305,162 @@ func (r *KeystoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			keystonev1.AdminServiceClientReadyWaitingMessage))
 		return ctrlResult, nil
 	}
+	r.AuthBreaker.Success(authBreakerKey(keystoneAPI))
 	instance.Status.Conditions.MarkTrue(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyMessage)
+	reportClockSkew(&instance.Status.Conditions, os, r.ClientOptions.ClockSkewThreshold)
+	if forceReauth {
+		if err := clearReauthAnnotation(ctx, r.Client, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		util.LogForObject(helper, "Re-authenticated as requested", instance)
+	}
+	instance.Status.AuthURL = os.GetAuthURL()
+	instance.Status.IdentityEndpoint = os.GetIdentityEndpoint()
+	span.SetAttributes(regionAttr.String(os.GetRegionID()))
+	if r.ClientOptions.Microversion != "" {
+		instance.Status.IdentityAPIVersion, err = os.GetAPIVersion(r.Log)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	// update status to save current conditions to object before sub-reconcilation rules start
-	if err := r.Status().Update(ctx, instance); err != nil {
+	if err := updateStatus(ctx, r.Client, instance); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// Handle endpoint delete
 	if !instance.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, instance, helper, os)
+		return r.reconcileDelete(ctx, instance, helper, keystoneAPI, os)
 	}
 
 	// Handle non-deleted clusters
-	return r.reconcileNormal(ctx, instance, helper, os)
+	return r.reconcileNormal(ctx, instance, helper, keystoneAPI, os)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KeystoneEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.updates = newUpdateTracker(r.FlappingWindow)
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&keystonev1.KeystoneEndpoint{}).
-		Complete(r)
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.endpointsReferencing(keystonev1.EndpointURLRefKindService)))
+
+	// Routes are only available on OpenShift
+	routeGK := routev1.SchemeGroupVersion.WithKind("Route").GroupKind()
+	if _, err := mgr.GetRESTMapper().RESTMapping(routeGK, routev1.SchemeGroupVersion.Version); err == nil {
+		b = b.Watches(
+			&source.Kind{Type: &routev1.Route{}},
+			handler.EnqueueRequestsFromMapFunc(r.endpointsReferencing(keystonev1.EndpointURLRefKindRoute)))
+	}
+
+	return b.Complete(r)
+}
+
+// endpointsReferencing - returns a handler.MapFunc requesting the
+// KeystoneEndpoints which resolve an endpoint URL from the object of kind
+func (r *KeystoneEndpointReconciler) endpointsReferencing(kind string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		endpoints := &keystonev1.KeystoneEndpointList{}
+		if err := r.Client.List(context.TODO(), endpoints, client.InNamespace(obj.GetNamespace())); err != nil {
+			r.Log.Error(err, "Unable to list KeystoneEndpoints")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, e := range endpoints.Items {
+			for _, ref := range e.Spec.EndpointURLRefs {
+				if ref.Kind == kind && ref.Name == obj.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Name: e.Name, Namespace: e.Namespace},
+					})
+					break
+				}
+			}
+		}
+
+		return requests
+	}
 }
 
 func (r *KeystoneEndpointReconciler) reconcileDelete(
 	ctx context.Context,
 	instance *keystonev1.KeystoneEndpoint,
 	helper *helper.Helper,
-	os *openstack.OpenStack,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
 ) (ctrl.Result, error) {
-	util.LogForObject(helper, "Reconciling Endpoint delete", instance)
+	r.Log.V(1).Info("Reconciling Endpoint delete", "instance", instance.Name)
+
+	reauth := adminClientReauth(ctx, helper, keystoneAPI, identityClientFactory(r.NewIdentityClient, r.ClientOptions), instance, &instance.Status.Conditions)
 
 	// Delete Endpoints -  it is ok to call delete on non existing Endpoints
-	// therefore always call delete for the spec.
-	for endpointType := range instance.Spec.Endpoints {
+	// therefore always call delete for the spec. With the Disable deletion
+	// policy the tracked endpoints get disabled instead.
+	deleteEndpoints := instance.GetEndpoints()
+	// the endpoints also declared by another KeystoneEndpoint of the
+	// service are owned by it
+	siblingEndpoints, err := r.getSiblingEndpoints(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if instance.Spec.DeletionPolicy == keystonev1.DeletionPolicyDisable {
+		deleteEndpoints = nil
+
+		var ctrlResult ctrl.Result
+		var err error
+		os, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			return ctrl.Result{}, r.disableEndpoints(instance, os)
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
+	}
+	for endpointType := range deleteEndpoints {
+		if _, ok := siblingEndpoints[endpointType]; ok {
+			continue
+		}
+
 		// get the gopher availability mapping for the endpointInterface
 		availability, err := openstack.GetAvailability(endpointType)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 
-		err = os.DeleteEndpoint(
-			r.Log,
-			openstack.Endpoint{
-				Name:         instance.Spec.ServiceName,
-				ServiceID:    instance.Status.ServiceID,
-				Availability: availability,
-			},
-		)
+		var ctrlResult ctrl.Result
+		os, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			return ctrl.Result{}, os.DeleteEndpoint(
+				r.Log,
+				keystone.Endpoint{
+					Name:         instance.GetCatalogServiceName(),
+					ServiceID:    instance.Status.ServiceID,
+					Availability: availability,
+				},
+			)
+		})
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointDeletedReason,
+			"Endpoint %s deleted", endpointType)
+	}
+
+	for _, endpointType := range endpointTypes {
+		r.updates.Forget(endpointUpdateKey(instance, endpointType))
+		endpointUpdates.DeleteLabelValues(instance.Namespace, instance.Name, endpointType)
 	}
 
 	// Endpoints are deleted so remove the finalizer.
-	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
-	util.LogForObject(helper, "Reconciled Endpoint delete successfully", instance)
+	removeFinalizers(instance, helper)
+	r.Log.V(1).Info("Reconciled Endpoint delete successfully", "instance", instance.Name)
 
 	if err := r.Update(ctx, instance); err != nil && !k8s_errors.IsNotFound(err) {
 		return ctrl.Result{}, err
@@ -250,14 +473,40 @@ func (r *KeystoneEndpointReconciler) reconcileNormal(
 	ctx context.Context,
 	instance *keystonev1.KeystoneEndpoint,
 	helper *helper.Helper,
-	os *openstack.OpenStack,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
 ) (ctrl.Result, error) {
-	util.LogForObject(helper, "Reconciling Endpoint normal", instance)
+	r.Log.V(1).Info("Reconciling Endpoint normal", "instance", instance.Name)
 
-	// If the endpoint object doesn't have our finalizer, add it.
-	controllerutil.AddFinalizer(instance, helper.GetFinalizer())
+	// Add our finalizer to the endpoint object, or remove it if it is disabled.
 	// Register the finalizer immediately to avoid orphaning resources on delete
-	if err := r.Update(ctx, instance); err != nil {
+	if err := registerFinalizer(ctx, r.Client, instance, helper); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	//
+	// resolve the endpoint URLs referencing a Route or Service
+	//
+	if err := r.resolveEndpointURLRefs(ctx, instance); err != nil {
+		if errors.Is(err, keystone.ErrEndpointURLRefNotReady) ||
+			errors.Is(err, keystone.ErrURLVariableNotFound) ||
+			k8s_errors.IsNotFound(err) {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				keystonev1.KeystoneServiceOSEndpointsReadyWaitingURLRefMessage,
+				err.Error()))
+			util.LogForObject(helper, fmt.Sprintf("Endpoint URL reference not resolvable, waiting: %s", err), instance)
+
+			return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+		}
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			keystonev1.KeystoneServiceOSEndpointsReadyErrorMessage,
+			err.Error()))
 		return ctrl.Result{}, err
 	}
 
@@ -286,140 +535,1000 @@ func (r *KeystoneEndpointReconciler) reconcileNormal(
 		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
 	}
 
-	instance.Status.ServiceID = ksSvc.Status.ServiceID
+	instance.Status.ServiceID = ksSvc.GetServiceID(instance.Spec.AdditionalServiceName)
+	serviceType := ""
+	for _, d := range ksSvc.GetServiceDefinitions() {
+		if d.ServiceName == instance.GetCatalogServiceName() {
+			serviceType = d.ServiceType
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(serviceTypeAttr.String(serviceType))
+	if instance.Status.ServiceID == "" {
+		util.LogForObject(helper, fmt.Sprintf("Service %s of KeystoneService %s not registered, waiting to create endpoints", instance.GetCatalogServiceName(), instance.Spec.ServiceName), instance)
+
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
+
+	reauth := adminClientReauth(ctx, helper, keystoneAPI, identityClientFactory(r.NewIdentityClient, r.ClientOptions), instance, &instance.Status.Conditions)
+
+	//
+	// create the region as child of its parent region
+	//
+	if keystoneAPI.Spec.ParentRegion != "" || len(instance.Spec.ParentRegions) > 0 {
+		var ctrlResult ctrl.Result
+		os, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			return r.reconcileRegion(instance, helper, keystoneAPI, os)
+		})
+		if err != nil {
+			instance.Status.Conditions.Set(keystoneErrorCondition(
+				keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+				keystonev1.KeystoneServiceOSEndpointsReadyErrorMessage,
+				err))
+			return ctrl.Result{}, err
+		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
+	}
+
+	//
+	// the endpoints depend on the service registered by the KeystoneService
+	// reconciler, wait until it is visible as a clustered keystone may lag
+	// behind on reads
+	//
+	var ctrlResult ctrl.Result
+	os, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+		return r.waitForService(instance, helper, os)
+	})
+	if err != nil {
+		instance.Status.Conditions.Set(keystoneErrorCondition(
+			keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+			keystonev1.KeystoneServiceOSEndpointsReadyErrorMessage,
+			err))
+		return ctrl.Result{}, err
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		return ctrlResult, nil
+	}
 
 	//
 	// create/update endpoints
 	//
-	err = r.reconcileEndpoints(
-		instance,
-		helper,
-		os)
+	_, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+		return ctrl.Result{}, r.reconcileEndpoints(
+			ctx,
+			instance,
+			helper,
+			keystoneAPI,
+			os,
+			serviceType)
+	})
 	if err != nil {
-		instance.Status.Conditions.Set(condition.FalseCondition(
+		var err404 gophercloud.ErrDefault404
+		if errors.As(err, &err404) {
+			// the service may not yet be visible on the keystone node
+			// which handled the write
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				keystonev1.KeystoneServiceOSEndpointsReadyWaitingServiceMessage,
+				instance.Status.ServiceID))
+			util.LogForObject(helper, fmt.Sprintf("Endpoint request failed with not found, retrying: %s", err), instance)
+
+			return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+		}
+		instance.Status.Conditions.Set(keystoneErrorCondition(
 			keystonev1.KeystoneServiceOSEndpointsReadyCondition,
-			condition.ErrorReason,
-			condition.SeverityWarning,
 			keystonev1.KeystoneServiceOSEndpointsReadyErrorMessage,
-			err.Error()))
+			err))
 		return ctrl.Result{}, err
 	}
+	if (ctrlResult != ctrl.Result{}) {
+		return ctrlResult, nil
+	}
+
+	//
+	// verify the endpoints are visible to clients in the catalog
+	//
+	if instance.Spec.VerifyCatalog {
+		var missing []string
+		_, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			catalog, err := os.GetCatalogEndpoints(r.Log, instance.Status.ServiceID)
+			missing = keystone.GetMissingCatalogEndpoints(catalog, instance.GetEndpoints())
+			return ctrl.Result{}, err
+		})
+		if err != nil {
+			instance.Status.Conditions.Set(keystoneErrorCondition(
+				keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+				keystonev1.KeystoneServiceOSEndpointsReadyErrorMessage,
+				err))
+			return ctrl.Result{}, err
+		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
+		if len(missing) > 0 {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+				condition.RequestedReason,
+				condition.SeverityInfo,
+				keystonev1.KeystoneServiceOSEndpointsReadyWaitingCatalogMessage,
+				strings.Join(missing, ", ")))
+			util.LogForObject(helper, fmt.Sprintf("Endpoints not yet in the catalog: %s", strings.Join(missing, ", ")), instance)
+
+			return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+		}
+	}
+
 	instance.Status.Conditions.MarkTrue(
 		keystonev1.KeystoneServiceOSEndpointsReadyCondition,
 		keystonev1.KeystoneServiceOSEndpointsReadyMessage,
-		instance.Spec.Endpoints,
+		instance.GetEndpoints(),
 	)
 
-	util.LogForObject(helper, "Reconciled Endpoint normal successfully", instance)
+	r.reportEndpointHealth(ctx, instance, helper)
+
+	hash, err := util.ObjectHash(instance.Spec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	instance.Status.Hash = hash
+	instance.Status.ObservedGeneration = instance.Generation
+	if requestID := changedRequestID(ctx); requestID != "" {
+		instance.Status.LastChangeRequestID = requestID
+		util.LogForObject(helper, fmt.Sprintf("Endpoints changed in keystone with request ID %s", requestID), instance)
+	}
+
+	r.Log.V(1).Info("Reconciled Endpoint normal successfully", "instance", instance.Name)
+
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// failedRetryPeriod - returns the interval failed endpoints get retried in,
+// the resync period or an hour without it
+func (r *KeystoneEndpointReconciler) failedRetryPeriod() time.Duration {
+	if r.ResyncPeriod > 0 {
+		return r.ResyncPeriod
+	}
+
+	return time.Hour
+}
+
+// isFailed - returns true if the current generation of the endpoints failed
+// more than MaxRetries times and the last attempt is less than the failed
+// retry period ago. The duration until the next retry is returned with it.
+func (r *KeystoneEndpointReconciler) isFailed(
+	instance *keystonev1.KeystoneEndpoint,
+) (time.Duration, bool) {
+	if instance.Spec.MaxRetries == 0 ||
+		instance.Status.FailedGeneration != instance.Generation ||
+		instance.Status.FailedAttempts <= instance.Spec.MaxRetries ||
+		instance.Status.LastFailureTime == nil {
+		return 0, false
+	}
+
+	remaining := r.failedRetryPeriod() - time.Since(instance.Status.LastFailureTime.Time)
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	return remaining, true
+}
+
+// countFailedAttempt - counts a reconcile which returned err as failed
+// attempt of the current generation. Once there are more than MaxRetries, the
+// endpoints are marked Failed and the error is replaced by a requeue after
+// the failed retry period. Before that, with a RetryInterval the error is
+// replaced by a requeue after the RetryInterval.
+func (r *KeystoneEndpointReconciler) countFailedAttempt(
+	instance *keystonev1.KeystoneEndpoint,
+	result ctrl.Result,
+	err error,
+) (ctrl.Result, error) {
+	if err == nil {
+		if instance.IsReady() {
+			instance.Status.FailedAttempts = 0
+			instance.Status.FailedGeneration = 0
+			instance.Status.LastFailureTime = nil
+		}
+		return result, nil
+	}
+
+	if instance.Status.FailedGeneration != instance.Generation {
+		instance.Status.FailedGeneration = instance.Generation
+		instance.Status.FailedAttempts = 0
+	}
+	instance.Status.FailedAttempts++
+	now := metav1.Now()
+	instance.Status.LastFailureTime = &now
+
+	if instance.Spec.MaxRetries == 0 || instance.Status.FailedAttempts <= instance.Spec.MaxRetries {
+		if instance.Spec.RetryInterval == nil || instance.Spec.RetryInterval.Duration <= 0 {
+			return result, err
+		}
+		r.Log.Error(err, "Endpoints failed, retrying", "instance", instance.Name,
+			"attempts", instance.Status.FailedAttempts, "retryInterval", instance.Spec.RetryInterval.Duration)
+		return ctrl.Result{RequeueAfter: instance.Spec.RetryInterval.Duration}, nil
+	}
+
+	instance.Status.Conditions.Set(condition.FalseCondition(
+		condition.ReadyCondition,
+		keystonev1.FailedReason,
+		condition.SeverityError,
+		keystonev1.KeystoneEndpointFailedMessage,
+		instance.Status.FailedAttempts,
+		r.failedRetryPeriod(),
+		err.Error()))
+	r.Log.Error(err, "Endpoints failed, waiting for a spec change", "instance", instance.Name, "attempts", instance.Status.FailedAttempts)
+
+	return ctrl.Result{RequeueAfter: r.failedRetryPeriod()}, nil
+}
+
+// disableEndpoints - disables the endpoints tracked in the status, the ones
+// deleted out-of-band are skipped
+func (r *KeystoneEndpointReconciler) disableEndpoints(
+	instance *keystonev1.KeystoneEndpoint,
+	os keystone.IdentityClient,
+) error {
+	for endpointType, endpointID := range instance.Status.EndpointIDs {
+		err := os.SetEndpointEnabled(r.Log, endpointID, false)
+		if err != nil {
+			var err404 gophercloud.ErrDefault404
+			if errors.As(err, &err404) {
+				continue
+			}
+			return err
+		}
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointDisabledReason,
+			"Endpoint %s %s disabled", endpointType, endpointID)
+	}
+
+	return nil
+}
+
+// resolveEndpointURLRefs - resolves the URLs of the Spec.EndpointURLRefs
+// without a declared URL, and the declared URLs referencing variables, into
+// Status.ResolvedEndpoints
+func (r *KeystoneEndpointReconciler) resolveEndpointURLRefs(
+	ctx context.Context,
+	instance *keystonev1.KeystoneEndpoint,
+) error {
+	declared := instance.GetDeclaredEndpoints()
+	resolved := map[string]string{}
+	for endpointType, ref := range instance.Spec.EndpointURLRefs {
+		if _, ok := declared[endpointType]; ok {
+			continue
+		}
+
+		key := types.NamespacedName{Name: ref.Name, Namespace: instance.Namespace}
+		var endpointURL string
+		var err error
+		switch ref.Kind {
+		case keystonev1.EndpointURLRefKindRoute:
+			route := &routev1.Route{}
+			if err = r.Client.Get(ctx, key, route); err != nil {
+				return err
+			}
+			endpointURL, err = keystone.GetRouteURL(route, ref)
+		case keystonev1.EndpointURLRefKindService:
+			service := &corev1.Service{}
+			if err = r.Client.Get(ctx, key, service); err != nil {
+				return err
+			}
+			endpointURL, err = keystone.GetServiceURL(service, ref)
+		default:
+			err = fmt.Errorf("unsupported endpoint URL reference kind %s", ref.Kind)
+		}
+		if err != nil {
+			return err
+		}
+		resolved[endpointType] = endpointURL
+	}
+
+	// substitute the variables referenced by the endpoint URLs
+	variables, err := r.getURLVariables(ctx, instance)
+	if err != nil {
+		return err
+	}
+	for endpointType, endpointURL := range declared {
+		if !keystone.HasURLVariables(endpointURL) {
+			continue
+		}
+		resolved[endpointType], err = keystone.ExpandURLVariables(endpointURL, variables, goos.LookupEnv)
+		if err != nil {
+			return err
+		}
+	}
+
+	instance.Status.ResolvedEndpoints = nil
+	if len(resolved) > 0 {
+		instance.Status.ResolvedEndpoints = resolved
+	}
+
+	return nil
+}
+
+// getURLVariables - returns the variables of the Spec.URLVariablesFrom,
+// later sources take precedence
+func (r *KeystoneEndpointReconciler) getURLVariables(
+	ctx context.Context,
+	instance *keystonev1.KeystoneEndpoint,
+) (map[string]string, error) {
+	variables := map[string]string{}
+	for _, from := range instance.Spec.URLVariablesFrom {
+		data := map[string]string{}
+		var optional *bool
+		var err error
+		switch {
+		case from.ConfigMapRef != nil:
+			optional = from.ConfigMapRef.Optional
+			configMap := &corev1.ConfigMap{}
+			err = r.Client.Get(ctx, types.NamespacedName{Name: from.ConfigMapRef.Name, Namespace: instance.Namespace}, configMap)
+			data = configMap.Data
+		case from.SecretRef != nil:
+			optional = from.SecretRef.Optional
+			secret := &corev1.Secret{}
+			err = r.Client.Get(ctx, types.NamespacedName{Name: from.SecretRef.Name, Namespace: instance.Namespace}, secret)
+			for key, value := range secret.Data {
+				data[key] = string(value)
+			}
+		}
+		if err != nil {
+			if k8s_errors.IsNotFound(err) && optional != nil && *optional {
+				continue
+			}
+			return nil, err
+		}
+
+		for key, value := range data {
+			variables[from.Prefix+key] = value
+		}
+	}
+
+	return variables, nil
+}
+
+// waitForService - requeues while the service the endpoints get registered
+// for is not visible in keystone
+func (r *KeystoneEndpointReconciler) waitForService(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+	os keystone.IdentityClient,
+) (ctrl.Result, error) {
+	service, err := os.GetServiceByID(r.Log, instance.Status.ServiceID)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if service == nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneServiceOSEndpointsReadyWaitingServiceMessage,
+			instance.Status.ServiceID))
+		util.LogForObject(helper, fmt.Sprintf("Service %s not yet visible, waiting to create endpoints", instance.Status.ServiceID), instance)
+
+		return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileRegion - creates the region of the keystoneAPI as child of its
+// parent region, or of the Spec.ParentRegions hierarchy, requeues while a
+// parent region does not exist
+func (r *KeystoneEndpointReconciler) reconcileRegion(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
+) (ctrl.Result, error) {
+	parentRegions := instance.Spec.ParentRegions
+	if len(parentRegions) == 0 {
+		parentRegions = []string{keystoneAPI.Spec.ParentRegion}
+	}
+	// a single parent region of the keystoneAPI is only waited for, the
+	// hierarchy of the endpoint gets created with AutoCreateRegion
+	create := len(instance.Spec.ParentRegions) > 0 && keystoneAPI.Spec.AutoCreateRegion
+
+	missing, err := keystone.ReconcileRegionHierarchy(
+		r.Log,
+		os,
+		keystone.Region{
+			ID:          os.GetRegionID(),
+			Description: os.GetRegion(),
+		},
+		parentRegions,
+		create,
+	)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if missing != "" {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceOSEndpointsReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneServiceOSEndpointsReadyWaitingParentRegionMessage,
+			missing))
+		util.LogForObject(helper, fmt.Sprintf("Parent region %s does not exist, waiting to create endpoints", missing), instance)
+
+		return ctrl.Result{RequeueAfter: time.Duration(10) * time.Second}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
 
 func (r *KeystoneEndpointReconciler) reconcileEndpoints(
+	ctx context.Context,
 	instance *keystonev1.KeystoneEndpoint,
 	helper *helper.Helper,
-	os *openstack.OpenStack,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
+	serviceType string,
 ) error {
-	util.LogForObject(helper, "Reconciling Endpoints", instance)
-
-	// delete endpoint if it does no longer exist in Spec.Endpoints
-	// but has a reference in Status.EndpointIDs
-	if instance.Status.EndpointIDs != nil {
-		for endpointType := range instance.Status.EndpointIDs {
-			if _, ok := instance.Spec.Endpoints[endpointType]; !ok {
-				// get the gopher availability mapping for the endpointInterface
-				availability, err := openstack.GetAvailability(endpointType)
-				if err != nil {
-					return err
-				}
+	r.Log.V(1).Info("Reconciling Endpoints", "instance", instance.Name)
+
+	// the endpoints get registered in the region of the admin client
+	for _, e := range instance.Spec.EndpointList {
+		if e.Region != "" && e.Region != os.GetRegionID() {
+			return fmt.Errorf("%s endpoint declared for region %s, but the endpoints get registered in region %s",
+				e.Interface, e.Region, os.GetRegionID())
+		}
+	}
 
-				err = os.DeleteEndpoint(
-					r.Log,
-					openstack.Endpoint{
-						Name:         instance.Spec.ServiceName,
-						ServiceID:    instance.Status.ServiceID,
-						Availability: availability,
-					},
-				)
+	// the endpoints of the interfaces declared by other KeystoneEndpoints
+	// of the same service are owned by them and are not drift
+	siblingEndpoints, err := r.getSiblingEndpoints(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	// the set of interfaces is declarative, delete the endpoints of all
+	// interfaces which are not declared or aliases of the public
+	// endpoint, even if they were not
+	// created by the operator. With the report prune policy they are only
+	// listed in the status, but the endpoint tracked for an interface
+	// removed from the spec still gets deleted.
+	declared := instance.GetEndpoints()
+	for _, endpointType := range endpointTypes {
+		if _, ok := declared[endpointType]; ok {
+			continue
+		}
+		if _, ok := siblingEndpoints[endpointType]; ok {
+			delete(instance.Status.EndpointIDs, endpointType)
+			continue
+		}
+		if instance.Spec.PrunePolicy == keystonev1.PrunePolicyReport {
+			if endpointID := instance.Status.EndpointIDs[endpointType]; endpointID != "" {
+				err := os.DeleteEndpointByID(r.Log, endpointID)
 				if err != nil {
 					return err
 				}
-
-				// remove endpoint reference from status
-				delete(instance.Status.EndpointIDs, endpointType)
+				recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointDeletedReason,
+					"Endpoint %s %s deleted", endpointType, endpointID)
 			}
+			delete(instance.Status.EndpointIDs, endpointType)
+			continue
 		}
-	}
 
-	// create / update endpoints
-	for endpointType, endpointURL := range instance.Spec.Endpoints {
-
-		// get the gopher availability mapping for the endpointType
+		// get the gopher availability mapping for the endpointInterface
 		availability, err := openstack.GetAvailability(endpointType)
 		if err != nil {
 			return err
 		}
 
-		// get registered endpoints for the service and endpointType
-		allEndpoints, err := os.GetEndpoints(
+		err = os.DeleteEndpoint(
 			r.Log,
-			instance.Status.ServiceID,
-			endpointType)
+			keystone.Endpoint{
+				Name:         instance.GetCatalogServiceName(),
+				ServiceID:    instance.Status.ServiceID,
+				Availability: availability,
+			},
+		)
 		if err != nil {
 			return err
 		}
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointDeletedReason,
+			"Endpoint %s deleted", endpointType)
 
-		endpointID := ""
-		if len(allEndpoints) == 0 {
-			// Create the endpoint
-			endpointID, err = os.CreateEndpoint(
+		// remove endpoint reference from status
+		delete(instance.Status.EndpointIDs, endpointType)
+	}
+
+	// list the registered endpoints of the service once, the endpoints of
+	// each interface and the undeclared endpoints get diffed against it
+	allEndpoints, err := os.GetServiceEndpoints(r.Log, instance.Status.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	// create / update endpoints, in the configured order
+	for _, endpointType := range instance.GetEndpointOrder(declared) {
+		endpointURL := declared[endpointType]
+		_, span := startSpan(ctx, "keystone.ReconcileEndpoint",
+			serviceTypeAttr.String(serviceType),
+			serviceNameAttr.String(instance.GetCatalogServiceName()),
+			regionAttr.String(os.GetRegionID()),
+			endpointInterfaceAttr.String(endpointType),
+		)
+		previousID := instance.Status.EndpointIDs[endpointType]
+		err := r.reconcileEndpoint(instance, helper, keystoneAPI, os, allEndpoints, endpointType, endpointURL)
+		endSpan(span, err)
+		if err != nil {
+			return err
+		}
+		// persist the ID of a newly registered endpoint right away
+		if instance.Status.EndpointIDs[endpointType] != previousID {
+			persistProgress(ctx, r.Client, r.Log, instance, endpointType+" endpoint")
+		}
+	}
+	r.reportFlapping(instance, helper)
+
+	err = r.reconcileEndpointsEnabled(instance, os)
+	if err != nil {
+		return err
+	}
+	recordManagedEndpointFields(instance, declared)
+
+	err = r.reconcileProjectEndpointScope(instance, os)
+	if err != nil {
+		return err
+	}
+
+	for endpointType, endpointURL := range declared {
+		siblingEndpoints[endpointType] = endpointURL
+	}
+	err = r.reportUndeclaredEndpoints(instance, helper, os, allEndpoints, siblingEndpoints)
+	if err != nil {
+		return err
+	}
+
+	r.Log.V(1).Info("Reconciled Endpoints successfully", "instance", instance.Name)
+
+	return nil
+}
+
+// reconcileEndpoint - creates or updates the endpoint of endpointType with
+// endpointURL and tracks its ID in the status. allEndpoints are the endpoints
+// registered for the service in all regions.
+func (r *KeystoneEndpointReconciler) reconcileEndpoint(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
+	allEndpoints []endpoints.Endpoint,
+	endpointType string,
+	endpointURL string,
+) error {
+	// get the gopher availability mapping for the endpointType
+	availability, err := openstack.GetAvailability(endpointType)
+	if err != nil {
+		return err
+	}
+
+	if instance.Status.EndpointIDs == nil {
+		instance.Status.EndpointIDs = map[string]string{}
+	}
+
+	// adopt an already registered endpoint by ID if requested and the
+	// endpoint type is not yet tracked in the status
+	if adoptID := instance.Spec.AdoptEndpointIDs[endpointType]; adoptID != "" &&
+		instance.Status.EndpointIDs[endpointType] == "" {
+		err = r.adoptEndpoint(instance, helper, os, availability, adoptID, endpointURL)
+		if err != nil {
+			return err
+		}
+		instance.Status.EndpointIDs[endpointType] = adoptID
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointAdoptedReason,
+			"Endpoint %s %s adopted", endpointType, adoptID)
+		return nil
+	}
+
+	// the registered endpoints for the service and endpointType
+	registered := keystone.FilterEndpoints(allEndpoints, os.GetRegionID(), availability)
+
+	endpointID := ""
+	if len(registered) == 0 {
+		// an endpoint tracked in the status got deleted out-of-band, it
+		// gets registered again to keep the catalog as declared
+		if trackedID := instance.Status.EndpointIDs[endpointType]; trackedID != "" {
+			r.reportRecreatedEndpoint(instance, helper, endpointType, trackedID)
+		}
+
+		// Create the endpoint, with AutoCreateRegion the region gets
+		// created if it is missing
+		e := keystone.Endpoint{
+			Name:         instance.GetCatalogServiceName(),
+			ServiceID:    instance.Status.ServiceID,
+			Availability: availability,
+			URL:          endpointURL,
+		}
+		if keystoneAPI.Spec.AutoCreateRegion {
+			endpointID, err = keystone.CreateEndpointCreatingRegion(r.Log, os, e)
+		} else {
+			endpointID, err = os.CreateEndpoint(r.Log, e)
+		}
+		// the endpoint got registered since it was listed, by a concurrent
+		// reconcile or before the status with its ID got lost
+		if keystone.IsConflict(err) {
+			endpointID, err = keystone.AdoptConflictingEndpoint(r.Log, os, e, err)
+			if err != nil {
+				return err
+			}
+			instance.Status.EndpointIDs[endpointType] = endpointID
+			recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointAdoptedReason,
+				"Endpoint %s %s adopted", endpointType, endpointID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointCreatedReason,
+			"Endpoint %s registered with ID %s: %s", endpointType, endpointID, endpointURL)
+	} else if len(registered) == 1 {
+		// Update the endpoint if URL or name changed, the name follows
+		// a rename of the service
+		endpoint := registered[0]
+		endpointID = endpoint.ID
+		update, changed := keystone.GetEndpointUpdate(
+			endpoint,
+			keystone.Endpoint{
+				Name:         instance.GetCatalogServiceName(),
+				Availability: availability,
+				URL:          endpointURL,
+			},
+		)
+		if changed {
+			endpointID, err = os.UpdateEndpoint(
 				r.Log,
-				openstack.Endpoint{
-					Name:         instance.Spec.ServiceName,
-					ServiceID:    instance.Status.ServiceID,
-					Availability: availability,
-					URL:          endpointURL,
-				},
+				update,
+				endpoint.ID,
 			)
 			if err != nil {
 				return err
 			}
-		} else if len(allEndpoints) == 1 {
-			// Update the endpoint if URL changed
-			endpoint := allEndpoints[0]
-			if endpointURL != endpoint.URL {
-				endpointID, err = os.UpdateEndpoint(
-					r.Log,
-					openstack.Endpoint{
-						Name:         endpoint.Name,
-						ServiceID:    endpoint.ServiceID,
-						Availability: availability,
-						URL:          endpointURL,
-					},
-					endpoint.ID,
-				)
-				if err != nil {
-					return err
-				}
+			r.recordEndpointUpdate(instance, endpointType)
+			recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointUpdatedReason,
+				"Endpoint %s %s updated: %s", endpointType, endpointID, endpointURL)
+		}
+	} else {
+		// If there are multiple endpoints for the service and endpoint type log it as an error
+		// as manual check is required
+		return util.WrapErrorForObject(
+			fmt.Sprintf("multiple endpoints registered for service:%s type: %s",
+				instance.GetCatalogServiceName(), endpointType),
+			instance, err)
+	}
+
+	if endpointID != "" {
+		instance.Status.EndpointIDs[endpointType] = endpointID
+	}
+
+	return nil
+}
+
+// reportRecreatedEndpoint - logs and records a warning event for the endpoint
+// of endpointType with the tracked endpointID, which got deleted out-of-band
+// and is registered again
+func (r *KeystoneEndpointReconciler) reportRecreatedEndpoint(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+	endpointType string,
+	endpointID string,
+) {
+	msg := fmt.Sprintf("Endpoint %s %s got deleted out-of-band, registering it again", endpointType, endpointID)
+	util.LogForObject(helper, msg, instance)
+	if r.Recorder != nil {
+		r.Recorder.Event(instance, corev1.EventTypeWarning, string(keystonev1.EndpointRecreatedReason), msg)
+	}
+}
+
+// recordManagedEndpointFields - records the URL of the declared endpoints and
+// the enabled state of the ones the EndpointList declares it for as the
+// ManagedFields in the status
+func recordManagedEndpointFields(
+	instance *keystonev1.KeystoneEndpoint,
+	declared map[string]string,
+) {
+	managed := map[string]keystonev1.ManagedEndpointFields{}
+	for endpointType, endpointURL := range declared {
+		managed[endpointType] = keystonev1.ManagedEndpointFields{URL: endpointURL}
+	}
+	for _, e := range instance.Spec.EndpointList {
+		fields, ok := managed[e.Interface]
+		if !ok || e.Enabled == nil {
+			continue
+		}
+		enabled := *e.Enabled
+		fields.Enabled = &enabled
+		managed[e.Interface] = fields
+	}
+	instance.Status.ManagedFields = managed
+}
+
+// reconcileEndpointsEnabled - enables or disables the endpoints of the
+// Spec.EndpointList entries with Enabled set, if their state differs
+func (r *KeystoneEndpointReconciler) reconcileEndpointsEnabled(
+	instance *keystonev1.KeystoneEndpoint,
+	os keystone.IdentityClient,
+) error {
+	var endpointsEnabled map[string]bool
+	for _, e := range instance.Spec.EndpointList {
+		endpointID := instance.Status.EndpointIDs[e.Interface]
+		if e.Enabled == nil || endpointID == "" {
+			continue
+		}
+
+		// only list the endpoints if an enabled state is declared
+		if endpointsEnabled == nil {
+			var err error
+			endpointsEnabled, err = os.GetServiceEndpointsEnabled(r.Log, instance.Status.ServiceID)
+			if err != nil {
+				return err
 			}
-		} else {
-			// If there are multiple endpoints for the service and endpoint type log it as an error
-			// as manual check is required
-			return util.WrapErrorForObject(
-				fmt.Sprintf("multiple endpoints registered for service:%s type: %s",
-					instance.Spec.ServiceName, endpointType),
-				instance, err)
+		}
+		if enabled, ok := endpointsEnabled[endpointID]; ok && enabled == *e.Enabled {
+			continue
 		}
 
-		if instance.Status.EndpointIDs == nil {
-			instance.Status.EndpointIDs = map[string]string{}
+		err := os.SetEndpointEnabled(r.Log, endpointID, *e.Enabled)
+		if err != nil {
+			return err
 		}
-		if _, ok := instance.Spec.Endpoints[endpointType]; ok && endpointID != "" {
-			instance.Status.EndpointIDs[endpointType] = endpointID
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointUpdatedReason,
+			"Endpoint %s %s enabled: %t", e.Interface, endpointID, *e.Enabled)
+	}
+
+	return nil
+}
+
+// endpointUpdateKey - key of the endpoint of endpointType of instance in the
+// updateTracker
+func endpointUpdateKey(instance *keystonev1.KeystoneEndpoint, endpointType string) string {
+	return instance.Namespace + "/" + instance.Name + "/" + endpointType
+}
+
+// recordEndpointUpdate - counts an update of the endpoint of endpointType
+func (r *KeystoneEndpointReconciler) recordEndpointUpdate(
+	instance *keystonev1.KeystoneEndpoint,
+	endpointType string,
+) {
+	endpointUpdates.WithLabelValues(instance.Namespace, instance.Name, endpointType).Inc()
+	r.updates.Record(endpointUpdateKey(instance, endpointType), time.Now())
+}
+
+// reportFlapping - sets the KeystoneServiceOSEndpointsStable condition to
+// false if an endpoint got updated more than FlappingThreshold times within
+// the FlappingWindow, e.g. because the registered URL never matches the spec
+// or gets changed out-of-band. A warning event is emitted when an endpoint
+// starts flapping.
+func (r *KeystoneEndpointReconciler) reportFlapping(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+) {
+	if r.FlappingThreshold <= 0 {
+		return
+	}
+
+	now := time.Now()
+	flapping := []string{}
+	for _, endpointType := range endpointTypes {
+		updates := r.updates.Count(endpointUpdateKey(instance, endpointType), now)
+		if updates > r.FlappingThreshold {
+			flapping = append(flapping, fmt.Sprintf("%s (%d updates)", endpointType, updates))
 		}
 	}
+	if len(flapping) == 0 {
+		instance.Status.Conditions.MarkTrue(
+			keystonev1.KeystoneServiceOSEndpointsStableCondition,
+			keystonev1.KeystoneServiceOSEndpointsStableMessage)
+		return
+	}
 
-	util.LogForObject(helper, "Reconciled Endpoints successfully", instance)
+	wasFlapping := false
+	if c := instance.Status.Conditions.Get(keystonev1.KeystoneServiceOSEndpointsStableCondition); c != nil {
+		wasFlapping = c.Reason == keystonev1.EndpointFlappingReason
+	}
+	instance.Status.Conditions.Set(condition.FalseCondition(
+		keystonev1.KeystoneServiceOSEndpointsStableCondition,
+		keystonev1.EndpointFlappingReason,
+		condition.SeverityWarning,
+		keystonev1.KeystoneServiceOSEndpointsStableFlappingMessage,
+		strings.Join(flapping, ", "),
+		r.FlappingWindow))
+	msg := fmt.Sprintf("Endpoints updated repeatedly within %s: %s", r.FlappingWindow, strings.Join(flapping, ", "))
+	util.LogForObject(helper, msg, instance)
+	if !wasFlapping && r.Recorder != nil {
+		r.Recorder.Event(instance, corev1.EventTypeWarning, string(keystonev1.EndpointFlappingReason), msg)
+	}
+}
+
+// reportEndpointHealth - probes the endpoints with the Spec.HealthCheck and
+// sets a KeystoneEndpoint<Type>Reachable condition per endpoint type. The
+// conditions of endpoint types no longer probed get removed. A failed probe is
+// only a warning.
+func (r *KeystoneEndpointReconciler) reportEndpointHealth(
+	ctx context.Context,
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+) {
+	endpoints := map[string]string{}
+	if instance.Spec.HealthCheck != nil {
+		endpoints = instance.GetEndpoints()
+	}
+
+	conditions := condition.Conditions{}
+	for _, c := range instance.Status.Conditions {
+		if keystonev1.IsKeystoneEndpointReachableCondition(c.Type) {
+			continue
+		}
+		conditions = append(conditions, c)
+	}
+	instance.Status.Conditions = conditions
+
+	if len(endpoints) == 0 {
+		return
+	}
+
+	expectedStatus := instance.Spec.HealthCheck.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	for _, endpointType := range sets.StringKeySet(endpoints).List() {
+		conditionType := keystonev1.KeystoneEndpointReachableCondition(endpointType)
+		err := keystone.CheckEndpointHealth(ctx, r.ClientOptions.Transports.Default(), endpoints[endpointType], instance.Spec.HealthCheck.Path, expectedStatus)
+		if err != nil {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				conditionType,
+				keystonev1.EndpointUnreachableReason,
+				condition.SeverityWarning,
+				keystonev1.KeystoneEndpointReachableErrorMessage,
+				endpointType,
+				err.Error()))
+			util.LogForObject(helper, fmt.Sprintf("Endpoint %s health check failed: %s", endpointType, err), instance)
+			continue
+		}
+		instance.Status.Conditions.MarkTrue(conditionType, keystonev1.KeystoneEndpointReachableMessage, endpointType)
+	}
+}
+
+// reportUndeclaredEndpoints - lists the registered endpoints of the service
+// which are not declared in the spec in the status, without deleting them.
+// allEndpoints are the endpoints registered for the service in all regions,
+// endpoints created since are declared and do not matter. declared are the
+// endpoints declared by instance and the other KeystoneEndpoints of the
+// service.
+func (r *KeystoneEndpointReconciler) reportUndeclaredEndpoints(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+	os keystone.IdentityClient,
+	allEndpoints []endpoints.Endpoint,
+	declared map[string]string,
+) error {
+	undeclared := keystone.GetUndeclaredEndpoints(allEndpoints, os.GetRegionID(), declared)
+	if len(undeclared) == 0 {
+		instance.Status.UndeclaredEndpoints = nil
+		instance.Status.Conditions.MarkTrue(
+			keystonev1.KeystoneServiceOSEndpointsInSyncCondition,
+			keystonev1.KeystoneServiceOSEndpointsInSyncMessage)
+		return nil
+	}
+
+	instance.Status.UndeclaredEndpoints = undeclared
+	instance.Status.Conditions.Set(condition.FalseCondition(
+		keystonev1.KeystoneServiceOSEndpointsInSyncCondition,
+		keystonev1.EndpointDriftReason,
+		condition.SeverityWarning,
+		keystonev1.KeystoneServiceOSEndpointsInSyncDriftMessage,
+		strings.Join(undeclared, ", ")))
+	util.LogForObject(helper, fmt.Sprintf("Endpoints not declared in the spec: %s", strings.Join(undeclared, ", ")), instance)
+
+	return nil
+}
+
+// getSiblingEndpoints - returns the endpoints declared by the other
+// KeystoneEndpoints in the namespace of instance which register endpoints for
+// the same keystone service, merged into one map of endpoint type to URL
+func (r *KeystoneEndpointReconciler) getSiblingEndpoints(
+	ctx context.Context,
+	instance *keystonev1.KeystoneEndpoint,
+) (map[string]string, error) {
+	endpointList := &keystonev1.KeystoneEndpointList{}
+	err := r.Client.List(ctx, endpointList, client.InNamespace(instance.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	siblingEndpoints := map[string]string{}
+	for _, e := range endpointList.Items {
+		if e.Name == instance.Name || !e.DeletionTimestamp.IsZero() ||
+			e.Spec.ServiceName != instance.Spec.ServiceName ||
+			e.GetCatalogServiceName() != instance.GetCatalogServiceName() {
+			continue
+		}
+		for endpointType, endpointURL := range e.GetEndpoints() {
+			siblingEndpoints[endpointType] = endpointURL
+		}
+	}
+
+	return siblingEndpoints, nil
+}
+
+// reconcileProjectEndpointScope - associates the endpoints with the projects
+// in Spec.ProjectEndpointScope and removes the associations of projects which
+// got removed from it
+func (r *KeystoneEndpointReconciler) reconcileProjectEndpointScope(
+	instance *keystonev1.KeystoneEndpoint,
+	os keystone.IdentityClient,
+) error {
+	scope := sets.NewString(instance.Spec.ProjectEndpointScope...)
+	for _, projectID := range instance.Status.ScopedProjectIDs {
+		if scope.Has(projectID) {
+			continue
+		}
+		for _, endpointID := range instance.Status.EndpointIDs {
+			err := os.RemoveEndpointFromProject(r.Log, projectID, endpointID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, projectID := range instance.Spec.ProjectEndpointScope {
+		for _, endpointID := range instance.Status.EndpointIDs {
+			err := os.AddEndpointToProject(r.Log, projectID, endpointID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	instance.Status.ScopedProjectIDs = instance.Spec.ProjectEndpointScope
+
+	return nil
+}
+
+// adoptEndpoint - takes over the management of the registered endpoint with
+// endpointID. The endpoint must belong to the service and have the expected
+// availability, its URL and name get updated if they differ from the spec.
+func (r *KeystoneEndpointReconciler) adoptEndpoint(
+	instance *keystonev1.KeystoneEndpoint,
+	helper *helper.Helper,
+	os keystone.IdentityClient,
+	availability gophercloud.Availability,
+	endpointID string,
+	endpointURL string,
+) error {
+	endpoint, err := os.GetEndpoint(r.Log, endpointID)
+	if err != nil {
+		return err
+	}
+
+	if endpoint.ServiceID != instance.Status.ServiceID || endpoint.Availability != availability {
+		return fmt.Errorf("endpoint %s to adopt is registered for service %s interface %s, expected service %s interface %s",
+			endpointID, endpoint.ServiceID, endpoint.Availability, instance.Status.ServiceID, availability)
+	}
+
+	update, changed := keystone.GetEndpointUpdate(
+		*endpoint,
+		keystone.Endpoint{
+			Name:         instance.GetCatalogServiceName(),
+			Availability: availability,
+			URL:          endpointURL,
+		},
+	)
+	if changed {
+		_, err = os.UpdateEndpoint(
+			r.Log,
+			update,
+			endpoint.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	util.LogForObject(helper, fmt.Sprintf("Adopted %s endpoint with ID %s", availability, endpointID), instance)
 
 	return nil
 }
@@ -0,0 +1,378 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KeystoneRoleReconciler reconciles a KeystoneRole object into a keystone
+// role and its inference rules
+type KeystoneRoleReconciler struct {
+	client.Client
+	Kclient kubernetes.Interface
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	// NewIdentityClient - creates the admin keystone client, defaults to
+	// keystone.NewAdminIdentityClientFactory with the ClientOptions
+	NewIdentityClient keystone.IdentityClientFactory
+	// ClientOptions - operator level options of the admin clients
+	ClientOptions keystone.ClientOptions
+	// AuthBreaker - optional circuit breaker shared by the reconcilers to stop
+	// authenticating against an unavailable keystone after repeated failures
+	AuthBreaker *keystone.CircuitBreaker
+	// ReconcileLock - optional lock shared by the reconcilers to serialize
+	// the keystone requests of reconciles of the same target
+	ReconcileLock *ReconcileLock
+}
+
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneroles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneroles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneroles/finalizers,verbs=update
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneapis,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// Reconcile keystone role requests
+func (r *KeystoneRoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling", "keystonerole", req.NamespacedName)
+
+	// Fetch the KeystoneRole instance
+	instance := &keystonev1.KeystoneRole{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, err
+	}
+
+	//
+	// initialize status
+	//
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = condition.Conditions{}
+		cl := condition.CreateList(
+			condition.UnknownCondition(keystonev1.KeystoneAPIReadyCondition, condition.InitReason, keystonev1.KeystoneAPIReadyInitMessage),
+			condition.UnknownCondition(keystonev1.AdminServiceClientReadyCondition, condition.InitReason, keystonev1.AdminServiceClientReadyInitMessage),
+			condition.UnknownCondition(keystonev1.KeystoneRoleReadyCondition, condition.InitReason, keystonev1.KeystoneRoleReadyInitMessage))
+		instance.Status.Conditions.Init(&cl)
+
+		// Register overall status immediately to have an early feedback e.g. in the cli
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	helper, err := helper.NewHelper(
+		instance,
+		r.Client,
+		r.Kclient,
+		r.Scheme,
+		r.Log,
+	)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// a deleted role without the finalizer, e.g. with the finalizer disabled,
+	// is left to get removed, its resources in keystone are kept
+	if !instance.DeletionTimestamp.IsZero() && !hasFinalizer(instance, helper) {
+		return ctrl.Result{}, nil
+	}
+
+	// Always patch the instance status when exiting this function so we can persist any changes.
+	defer func() {
+		// update the overall status condition if the role is ready
+		if instance.IsReady() {
+			instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
+		}
+
+		if err := helper.SetAfter(instance); err != nil {
+			util.LogErrorForObject(helper, err, "Set after and calc patch/diff", instance)
+		}
+
+		if changed := helper.GetChanges()["status"]; changed {
+			patch := client.MergeFrom(helper.GetBeforeObject())
+
+			if err := r.Status().Patch(ctx, instance, patch); err != nil && !k8s_errors.IsNotFound(err) {
+				util.LogErrorForObject(helper, err, "Update status", instance)
+			}
+		}
+	}()
+
+	// without inference rules created there is nothing to clean up in keystone
+	if !instance.DeletionTimestamp.IsZero() && len(instance.Status.ImpliedRoleIDs) == 0 {
+		return r.removeFinalizer(ctx, instance, helper)
+	}
+
+	//
+	// Validate that keystoneAPI is up
+	//
+	keystoneAPI, err := keystonev1.GetKeystoneAPI(ctx, helper, instance.GetKeystoneAPINamespace(), map[string]string{})
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			instance.Status.Conditions.Set(condition.FalseCondition(
+				keystonev1.KeystoneAPIReadyCondition,
+				condition.ErrorReason,
+				condition.SeverityWarning,
+				keystonev1.KeystoneAPIReadyNotFoundMessage,
+			))
+			r.Log.Info("KeystoneAPI not found!")
+			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		}
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneAPIReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			keystonev1.KeystoneAPIReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	if !keystoneAPI.IsReady() || !keystoneAPI.IsBootstrapped() {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneAPIReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneAPIReadyWaitingMessage))
+		r.Log.Info("KeystoneAPI not yet ready")
+		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
+	instance.Status.Conditions.MarkTrue(keystonev1.KeystoneAPIReadyCondition, keystonev1.KeystoneAPIReadyMessage)
+
+	// roles only get serialized with the reconciles using the same credentials
+	defer r.ReconcileLock.Lock(keystoneAPI, "")()
+
+	//
+	// get admin authentication OpenStack
+	//
+	if allowed, wait := r.AuthBreaker.Allow(authBreakerKey(keystoneAPI)); !allowed {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.AdminServiceClientReadyCondition,
+			keystonev1.KeystoneUnavailableReason,
+			condition.SeverityWarning,
+			keystonev1.AdminServiceClientReadyKeystoneUnavailableMessage,
+			wait.Round(time.Second)))
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	os, ctrlResult, err := getIdentityClient(
+		ctx,
+		helper,
+		keystoneAPI,
+		identityClientFactory(r.NewIdentityClient, r.ClientOptions),
+	)
+	if err != nil {
+		if keystone.IsUnavailable(err) {
+			r.AuthBreaker.Failure(authBreakerKey(keystoneAPI))
+		}
+		instance.Status.Conditions.Set(keystoneErrorCondition(
+			keystonev1.AdminServiceClientReadyCondition,
+			keystonev1.AdminServiceClientReadyErrorMessage,
+			err))
+		return ctrl.Result{}, err
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.AdminServiceClientReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.AdminServiceClientReadyWaitingMessage))
+		return ctrlResult, nil
+	}
+	r.AuthBreaker.Success(authBreakerKey(keystoneAPI))
+	instance.Status.Conditions.MarkTrue(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyMessage)
+	reportClockSkew(&instance.Status.Conditions, os, r.ClientOptions.ClockSkewThreshold)
+
+	reauth := adminClientReauth(ctx, helper, keystoneAPI, identityClientFactory(r.NewIdentityClient, r.ClientOptions), instance, &instance.Status.Conditions)
+
+	// Handle role delete
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, instance, helper, os, reauth)
+	}
+
+	// Handle non-deleted clusters
+	return r.reconcileNormal(ctx, instance, helper, os, reauth)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KeystoneRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&keystonev1.KeystoneRole{}).
+		Complete(r)
+}
+
+// reconcileDelete - deletes the inference rules created for the role. The
+// role itself is kept, as it can be assigned to users outside of the operator.
+func (r *KeystoneRoleReconciler) reconcileDelete(
+	ctx context.Context,
+	instance *keystonev1.KeystoneRole,
+	helper *helper.Helper,
+	os keystone.IdentityClient,
+	reauth reauthFunc,
+) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling Role delete", "instance", instance.Name)
+
+	_, ctrlResult, err := reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+		for _, impliedRoleID := range sortedValues(instance.Status.ImpliedRoleIDs) {
+			if err := os.DeleteImpliedRole(r.Log, instance.Status.RoleID, impliedRoleID); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{}, nil
+	})
+	if err != nil {
+		instance.Status.Conditions.Set(keystoneErrorCondition(
+			keystonev1.KeystoneRoleReadyCondition,
+			keystonev1.KeystoneRoleReadyErrorMessage,
+			err))
+		return ctrl.Result{}, err
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		return ctrlResult, nil
+	}
+	instance.Status.ImpliedRoleIDs = nil
+
+	return r.removeFinalizer(ctx, instance, helper)
+}
+
+// removeFinalizer - removes the finalizer of the deleted role
+func (r *KeystoneRoleReconciler) removeFinalizer(
+	ctx context.Context,
+	instance *keystonev1.KeystoneRole,
+	helper *helper.Helper,
+) (ctrl.Result, error) {
+	removeFinalizers(instance, helper)
+	r.Log.V(1).Info("Reconciled Role delete successfully", "instance", instance.Name)
+	if err := r.Update(ctx, instance); err != nil && !k8s_errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KeystoneRoleReconciler) reconcileNormal(
+	ctx context.Context,
+	instance *keystonev1.KeystoneRole,
+	helper *helper.Helper,
+	os keystone.IdentityClient,
+	reauth reauthFunc,
+) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling Role", "instance", instance.Name)
+
+	// Add our finalizer to the role object, or remove it if it is disabled.
+	// Register the finalizer immediately to avoid orphaning resources on delete
+	if err := registerFinalizer(ctx, r.Client, instance, helper); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	_, ctrlResult, err := reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+		return ctrl.Result{}, r.reconcileRole(instance, os)
+	})
+	if err != nil {
+		instance.Status.Conditions.Set(keystoneErrorCondition(
+			keystonev1.KeystoneRoleReadyCondition,
+			keystonev1.KeystoneRoleReadyErrorMessage,
+			err))
+		return ctrl.Result{}, err
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		return ctrlResult, nil
+	}
+	instance.Status.Conditions.MarkTrue(
+		keystonev1.KeystoneRoleReadyCondition,
+		keystonev1.KeystoneRoleReadyMessage,
+		instance.Spec.RoleName)
+	instance.Status.ObservedGeneration = instance.Generation
+
+	r.Log.V(1).Info("Reconciled Role successfully", "instance", instance.Name)
+	return ctrl.Result{}, nil
+}
+
+// reconcileRole - creates the role and the implied roles, and reconciles the
+// inference rules of the role to the ImpliedRoles of the spec
+func (r *KeystoneRoleReconciler) reconcileRole(
+	instance *keystonev1.KeystoneRole,
+	os keystone.IdentityClient,
+) error {
+	roleID, err := os.CreateRole(r.Log, instance.Spec.RoleName)
+	if err != nil {
+		return err
+	}
+	// a role recreated with the same name has a new ID, the rules of the
+	// previous one are gone with it
+	if instance.Status.RoleID != roleID {
+		instance.Status.ImpliedRoleIDs = nil
+	}
+	instance.Status.RoleID = roleID
+
+	impliedRoleIDs := map[string]string{}
+	for _, roleName := range instance.Spec.ImpliedRoles {
+		impliedRoleIDs[roleName], err = os.CreateRole(r.Log, roleName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = keystone.ReconcileImpliedRoles(
+		r.Log,
+		os,
+		roleID,
+		sortedValues(impliedRoleIDs),
+		sortedValues(instance.Status.ImpliedRoleIDs))
+	if err != nil {
+		return err
+	}
+	instance.Status.ImpliedRoleIDs = impliedRoleIDs
+
+	return nil
+}
+
+// sortedValues - returns the values of m sorted by key
+func sortedValues(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(m))
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+
+	return values
+}
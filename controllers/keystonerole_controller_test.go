@@ -0,0 +1,83 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("KeystoneRole controller", func() {
+	var namespace string
+
+	BeforeEach(func() {
+		skipWithoutEnvtest()
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		createReadyKeystoneAPI(namespace)
+	})
+
+	It("reconciles the implied roles and deletes them with the role", func() {
+		role := &keystonev1.KeystoneRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "operator",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneRoleSpec{
+				RoleName:     "operator",
+				ImpliedRoles: []string{"member", "reader"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+		roleKey := types.NamespacedName{Name: "operator", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, roleKey, role); err != nil {
+				return false
+			}
+			return role.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(role.Status.RoleID).To(Equal("role-operator"))
+		Expect(identityClient.ImpliedRoles("role-operator")).To(ConsistOf("role-member", "role-reader"))
+
+		By("removing an implied role")
+		role.Spec.ImpliedRoles = []string{"reader"}
+		Expect(k8sClient.Update(ctx, role)).To(Succeed())
+		Eventually(func() []string {
+			return identityClient.ImpliedRoles("role-operator")
+		}, timeout, interval).Should(ConsistOf("role-reader"))
+
+		By("deleting the role")
+		Expect(k8sClient.Delete(ctx, role)).To(Succeed())
+		Eventually(func() bool {
+			return k8s_errors.IsNotFound(k8sClient.Get(ctx, roleKey, &keystonev1.KeystoneRole{}))
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.ImpliedRoles("role-operator")).To(BeEmpty())
+	})
+})
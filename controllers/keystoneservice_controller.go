@@ -18,25 +18,33 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
 	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
 	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
-	secret "github.com/openstack-k8s-operators/lib-common/modules/common/secret"
 	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
 
-	openstack "github.com/openstack-k8s-operators/lib-common/modules/openstack"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // GetClient -
@@ -65,16 +73,47 @@ type KeystoneServiceReconciler struct {
 	Kclient kubernetes.Interface
 	Log     logr.Logger
 	Scheme  *runtime.Scheme
+	// NewIdentityClient - creates the admin keystone client, defaults to
+	// keystone.NewAdminIdentityClientFactory with the ClientOptions
+	NewIdentityClient keystone.IdentityClientFactory
+	// ClientOptions - operator level options of the admin clients
+	ClientOptions keystone.ClientOptions
+	// AuthBreaker - optional circuit breaker shared by the reconcilers to stop
+	// authenticating against an unavailable keystone after repeated failures
+	AuthBreaker *keystone.CircuitBreaker
+	// ReconcileLock - optional lock shared by the reconcilers to serialize
+	// the keystone requests of reconciles of the same target
+	ReconcileLock *ReconcileLock
+	// ResyncPeriod - optional interval to requeue reconciled instances after,
+	// to correct changes made in keystone out-of-band
+	ResyncPeriod time.Duration
+	// Recorder - optional recorder of the events of the instances
+	Recorder record.EventRecorder
+	// StatusConfigMap - optional name of a ConfigMap the summarized reconcile
+	// state of the KeystoneServices of a namespace is exported to
+	StatusConfigMap string
 }
 
 // +kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservices,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservices/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservices/finalizers,verbs=update
 // +kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneapis,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch
 
 // Reconcile keystone service requests
-func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
 	_ = r.Log.WithValues("keystoneservice", req.NamespacedName)
+	ctx = detachContext(ctx)
+	ctx, requestID := withRequestID(ctx)
+	r.Log.V(1).Info("Reconciling", "keystoneservice", req.NamespacedName, "requestID", requestID.ID)
+
+	ctx, span := startSpan(ctx, "KeystoneService.Reconcile")
+	defer func() {
+		endSpan(span, _err)
+	}()
 
 	// Fetch the KeystoneService instance
 	instance := &keystonev1.KeystoneService{}
@@ -84,11 +123,19 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			// The deleted service gets removed from the exported state
+			if r.StatusConfigMap != "" {
+				return ctrl.Result{}, exportServiceStatus(ctx, r.Client, r.StatusConfigMap, req.Namespace, nil)
+			}
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
+	span.SetAttributes(
+		serviceTypeAttr.String(instance.Spec.ServiceType),
+		serviceNameAttr.String(instance.Spec.ServiceName),
+	)
 
 	//
 	// initialize status
@@ -103,7 +150,7 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		instance.Status.Conditions.Init(&cl)
 
 		// Register overall status immediately to have an early feedback e.g. in the cli
-		if err := r.Status().Update(ctx, instance); err != nil {
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
@@ -119,6 +166,12 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// a deleted service without the finalizer, e.g. with the finalizer disabled,
+	// is left to get removed, its resources in keystone are kept
+	if !instance.DeletionTimestamp.IsZero() && !hasFinalizer(instance, helper) {
+		return ctrl.Result{}, nil
+	}
+
 	// Always patch the instance status when exiting this function so we can persist any changes.
 	defer func() {
 		// update the overall status condition if service is ready
@@ -126,6 +179,13 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
 		}
 
+		recordFailure(r.Recorder, instance, instance.Status.Conditions, _err)
+
+		// after MaxRetries failed reconciles the service is marked failed
+		if instance.DeletionTimestamp.IsZero() {
+			result, _err = r.countFailedAttempt(instance, result, _err)
+		}
+
 		if err := helper.SetAfter(instance); err != nil {
 			util.LogErrorForObject(helper, err, "Set after and calc patch/diff", instance)
 		}
@@ -136,13 +196,62 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			if err := r.Status().Patch(ctx, instance, patch); err != nil && !k8s_errors.IsNotFound(err) {
 				util.LogErrorForObject(helper, err, "Update status", instance)
 			}
+
+			if r.StatusConfigMap != "" {
+				err := exportServiceStatus(ctx, r.Client, r.StatusConfigMap, instance.Namespace, instance)
+				if err != nil {
+					util.LogErrorForObject(helper, err, "Export status", instance)
+				}
+			}
 		}
 	}()
 
+	// a requested re-authentication bypasses the skips and the circuit breaker
+	forceReauth := reauthRequested(instance)
+
+	// the services are only enabled while the backend is ready, a change of
+	// its readiness bypasses the skip of an unchanged service
+	backendChanged := false
+	if instance.DeletionTimestamp.IsZero() {
+		backendChanged, err = r.reconcileBackendReady(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// skip the keystone requests for an unchanged ready service, e.g. after a
+	// restart of the operator, until the resync period passed
+	if instance.DeletionTimestamp.IsZero() && !forceReauth && !backendChanged {
+		if requeueAfter, unchanged := r.isUnchanged(instance); unchanged {
+			r.Log.V(1).Info("Service unchanged since the last sync, skipping it", "instance", instance.Name)
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+	}
+
+	// a failed service only gets retried on a spec change or after the
+	// failed retry period
+	if instance.DeletionTimestamp.IsZero() && !forceReauth {
+		if requeueAfter, failed := r.isFailed(instance); failed {
+			r.Log.V(1).Info("Service failed, waiting for a spec change", "instance", instance.Name)
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+	}
+
+	// the services wait for the KeystoneServices they depend on
+	if instance.DeletionTimestamp.IsZero() {
+		waiting, err := r.reconcileDependencies(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if waiting {
+			return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+		}
+	}
+
 	//
 	// Validate that keystoneAPI is up
 	//
-	keystoneAPI, err := keystonev1.GetKeystoneAPI(ctx, helper, instance.Namespace, map[string]string{})
+	keystoneAPI, err := keystonev1.GetKeystoneAPI(ctx, helper, instance.GetKeystoneAPINamespace(), map[string]string{})
 	if err != nil {
 		if k8s_errors.IsNotFound(err) {
 			instance.Status.Conditions.Set(condition.FalseCondition(
@@ -163,6 +272,18 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// without status the controller of the KeystoneAPI did not run yet, e.g.
+	// it is not deployed, which takes longer than a deployment getting ready
+	if !keystoneAPI.HasStatus() {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneAPIReadyCondition,
+			keystonev1.WaitingForAPIControllerReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneAPIReadyWaitingControllerMessage))
+		r.Log.Info("KeystoneAPI status not yet populated")
+		return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	}
+
 	if !keystoneAPI.IsReady() {
 		instance.Status.Conditions.Set(condition.FalseCondition(
 			keystonev1.KeystoneAPIReadyCondition,
@@ -172,23 +293,50 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		r.Log.Info("KeystoneAPI not yet ready")
 		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
 	}
+	// the admin user the client authenticates with gets created by the bootstrap
+	if !keystoneAPI.IsBootstrapped() {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneAPIReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneAPIReadyWaitingBootstrapMessage))
+		r.Log.Info("KeystoneAPI bootstrap not yet completed")
+		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
 	instance.Status.Conditions.MarkTrue(keystonev1.KeystoneAPIReadyCondition, keystonev1.KeystoneAPIReadyMessage)
 
+	keystoneAPI = withRegion(keystoneAPI, instance.Spec.Region)
+	instance.Status.Region = keystoneAPI.GetRegion()
+
+	// serialize the keystone requests with the reconciles of its KeystoneEndpoints
+	defer r.ReconcileLock.Lock(keystoneAPI, instance.Namespace+"/"+instance.Name)()
+
 	//
 	// get admin authentication OpenStack
 	//
-	os, ctrlResult, err := keystonev1.GetAdminServiceClient(
+	if allowed, wait := r.AuthBreaker.Allow(authBreakerKey(keystoneAPI)); !allowed && !forceReauth {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.AdminServiceClientReadyCondition,
+			keystonev1.KeystoneUnavailableReason,
+			condition.SeverityWarning,
+			keystonev1.AdminServiceClientReadyKeystoneUnavailableMessage,
+			wait.Round(time.Second)))
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	os, ctrlResult, err := getIdentityClient(
 		ctx,
 		helper,
 		keystoneAPI,
+		identityClientFactory(r.NewIdentityClient, r.ClientOptions),
 	)
 	if err != nil {
-		instance.Status.Conditions.Set(condition.FalseCondition(
+		if keystone.IsUnavailable(err) {
+			r.AuthBreaker.Failure(authBreakerKey(keystoneAPI))
+		}
+		instance.Status.Conditions.Set(keystoneErrorCondition(
 			keystonev1.AdminServiceClientReadyCondition,
-			condition.ErrorReason,
-			condition.SeverityWarning,
 			keystonev1.AdminServiceClientReadyErrorMessage,
-			err.Error()))
+			err))
 		return ctrl.Result{}, err
 	}
 	if (ctrlResult != ctrl.Result{}) {
@@ -199,65 +347,446 @@ func (r *KeystoneServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			keystonev1.AdminServiceClientReadyWaitingMessage))
 		return ctrlResult, nil
 	}
+	r.AuthBreaker.Success(authBreakerKey(keystoneAPI))
 	instance.Status.Conditions.MarkTrue(keystonev1.AdminServiceClientReadyCondition, keystonev1.AdminServiceClientReadyMessage)
+	reportClockSkew(&instance.Status.Conditions, os, r.ClientOptions.ClockSkewThreshold)
+	if forceReauth {
+		if err := clearReauthAnnotation(ctx, r.Client, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Re-authenticated as requested", "instance", instance.Name)
+	}
+	instance.Status.AuthURL = os.GetAuthURL()
+	instance.Status.IdentityEndpoint = os.GetIdentityEndpoint()
+	span.SetAttributes(regionAttr.String(os.GetRegionID()))
+	if r.ClientOptions.Microversion != "" {
+		instance.Status.IdentityAPIVersion, err = os.GetAPIVersion(r.Log)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	// update status to save current conditions to object before sub-reconcilation rules start
-	if err := r.Status().Update(ctx, instance); err != nil {
+	if err := updateStatus(ctx, r.Client, instance); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// Handle service delete
 	if !instance.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, instance, helper, os)
+		return r.reconcileDelete(ctx, instance, helper, keystoneAPI, os)
 	}
 
 	// Handle non-deleted clusters
-	return r.reconcileNormal(ctx, instance, helper, os)
+	return r.reconcileNormal(ctx, instance, helper, keystoneAPI, os)
+
+}
+
+// isUnchanged - returns true if the service is ready and its spec got applied
+// by the last sync, which is less than the resync period ago. The duration
+// until the next resync is returned with it.
+func (r *KeystoneServiceReconciler) isUnchanged(
+	instance *keystonev1.KeystoneService,
+) (time.Duration, bool) {
+	if !instance.IsReady() ||
+		instance.Status.ObservedGeneration != instance.Generation ||
+		instance.Status.LastSyncTime == nil {
+		return 0, false
+	}
+	hash, err := util.ObjectHash(instance.Spec)
+	if err != nil || hash != instance.Status.Hash {
+		return 0, false
+	}
+	if r.ResyncPeriod == 0 {
+		return 0, true
+	}
+
+	remaining := r.ResyncPeriod - time.Since(instance.Status.LastSyncTime.Time)
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	return remaining, true
+}
+
+// failedRetryPeriod - returns the interval a failed service gets retried
+// in, the resync period or an hour without it
+func (r *KeystoneServiceReconciler) failedRetryPeriod() time.Duration {
+	if r.ResyncPeriod > 0 {
+		return r.ResyncPeriod
+	}
+
+	return time.Hour
+}
+
+// isFailed - returns true if the current generation of the service failed
+// more than MaxRetries times and the last attempt is less than the failed
+// retry period ago. The duration until the next retry is returned with it.
+func (r *KeystoneServiceReconciler) isFailed(
+	instance *keystonev1.KeystoneService,
+) (time.Duration, bool) {
+	if instance.Spec.MaxRetries == 0 ||
+		instance.Status.FailedGeneration != instance.Generation ||
+		instance.Status.FailedAttempts <= instance.Spec.MaxRetries ||
+		instance.Status.LastFailureTime == nil {
+		return 0, false
+	}
+
+	remaining := r.failedRetryPeriod() - time.Since(instance.Status.LastFailureTime.Time)
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	return remaining, true
+}
+
+// countFailedAttempt - counts a reconcile which returned err as failed
+// attempt of the current generation. Once there are more than MaxRetries, the
+// service is marked Failed and the error is replaced by a requeue after the
+// failed retry period.
+func (r *KeystoneServiceReconciler) countFailedAttempt(
+	instance *keystonev1.KeystoneService,
+	result ctrl.Result,
+	err error,
+) (ctrl.Result, error) {
+	if err == nil {
+		if instance.IsReady() {
+			instance.Status.FailedAttempts = 0
+			instance.Status.FailedGeneration = 0
+			instance.Status.LastFailureTime = nil
+		}
+		return result, nil
+	}
+
+	if instance.Status.FailedGeneration != instance.Generation {
+		instance.Status.FailedGeneration = instance.Generation
+		instance.Status.FailedAttempts = 0
+	}
+	instance.Status.FailedAttempts++
+	now := metav1.Now()
+	instance.Status.LastFailureTime = &now
+
+	if instance.Spec.MaxRetries == 0 || instance.Status.FailedAttempts <= instance.Spec.MaxRetries {
+		return result, err
+	}
+
+	instance.Status.Conditions.Set(condition.FalseCondition(
+		condition.ReadyCondition,
+		keystonev1.FailedReason,
+		condition.SeverityError,
+		keystonev1.KeystoneServiceFailedMessage,
+		instance.Status.FailedAttempts,
+		r.failedRetryPeriod(),
+		err.Error()))
+	r.Log.Error(err, "Service failed, waiting for a spec change", "instance", instance.Name, "attempts", instance.Status.FailedAttempts)
+
+	return ctrl.Result{RequeueAfter: r.failedRetryPeriod()}, nil
+}
+
+// reconcileBackendReady - sets Status.BackendReady to the readiness of the
+// Spec.Backend and returns true if it changed. A missing backend is not ready.
+func (r *KeystoneServiceReconciler) reconcileBackendReady(
+	ctx context.Context,
+	instance *keystonev1.KeystoneService,
+) (bool, error) {
+	if instance.Spec.Backend == nil {
+		changed := instance.Status.BackendReady != nil
+		instance.Status.BackendReady = nil
+		return changed, nil
+	}
+
+	key := types.NamespacedName{Name: instance.Spec.Backend.Name, Namespace: instance.Namespace}
+	ready := false
+	switch instance.Spec.Backend.Kind {
+	case keystonev1.BackendKindDeployment:
+		deployment := &appsv1.Deployment{}
+		err := r.Client.Get(ctx, key, deployment)
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+		if err == nil {
+			ready = workloadReady(deployment.Generation, deployment.Status.ObservedGeneration,
+				deployment.Spec.Replicas, deployment.Status.ReadyReplicas)
+		}
+	case keystonev1.BackendKindStatefulSet:
+		statefulSet := &appsv1.StatefulSet{}
+		err := r.Client.Get(ctx, key, statefulSet)
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+		if err == nil {
+			ready = workloadReady(statefulSet.Generation, statefulSet.Status.ObservedGeneration,
+				statefulSet.Spec.Replicas, statefulSet.Status.ReadyReplicas)
+		}
+	default:
+		return false, fmt.Errorf("unsupported backend kind %s", instance.Spec.Backend.Kind)
+	}
+
+	changed := instance.Status.BackendReady == nil || *instance.Status.BackendReady != ready
+	if changed {
+		r.Log.Info(fmt.Sprintf("Backend %s %s ready: %t", instance.Spec.Backend.Kind, instance.Spec.Backend.Name, ready))
+	}
+	instance.Status.BackendReady = &ready
+
+	return changed, nil
+}
+
+// reconcileDependencies - sets the KeystoneServiceDependenciesReadyCondition
+// and returns true while one of the Spec.DependsOn KeystoneServices is missing
+// or not ready
+func (r *KeystoneServiceReconciler) reconcileDependencies(
+	ctx context.Context,
+	instance *keystonev1.KeystoneService,
+) (bool, error) {
+	if len(instance.Spec.DependsOn) == 0 {
+		// the dependencies got removed from the spec
+		if instance.Status.Conditions.Get(keystonev1.KeystoneServiceDependenciesReadyCondition) != nil {
+			instance.Status.Conditions.MarkTrue(
+				keystonev1.KeystoneServiceDependenciesReadyCondition,
+				keystonev1.KeystoneServiceDependenciesReadyMessage)
+		}
+		return false, nil
+	}
+
+	notReady := []string{}
+	for _, name := range instance.Spec.DependsOn {
+		dependency := &keystonev1.KeystoneService{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, dependency)
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+		if err != nil || !dependency.IsReady() {
+			notReady = append(notReady, name)
+		}
+	}
+
+	if len(notReady) > 0 {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceDependenciesReadyCondition,
+			keystonev1.WaitingForDependencyReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneServiceDependenciesReadyWaitingMessage,
+			strings.Join(notReady, ", ")))
+		r.Log.Info(fmt.Sprintf("Service %s waiting for the KeystoneServices %s", instance.Spec.ServiceName, strings.Join(notReady, ", ")))
+		return true, nil
+	}
+	instance.Status.Conditions.MarkTrue(
+		keystonev1.KeystoneServiceDependenciesReadyCondition,
+		keystonev1.KeystoneServiceDependenciesReadyMessage)
+
+	return false, nil
+}
+
+// reportServiceID - sets the KeystoneServiceIDAppliedCondition if the
+// Spec.ServiceID is set. A service registered with another ID, as keystone
+// assigned its own or the service got registered before, is only a warning,
+// the service keeps its ID.
+func reportServiceID(instance *keystonev1.KeystoneService) {
+	if instance.Spec.ServiceID == "" {
+		if instance.Status.Conditions.Get(keystonev1.KeystoneServiceIDAppliedCondition) == nil {
+			return
+		}
+		// the ServiceID got removed from the spec
+		conditions := condition.Conditions{}
+		for _, c := range instance.Status.Conditions {
+			if c.Type != keystonev1.KeystoneServiceIDAppliedCondition {
+				conditions = append(conditions, c)
+			}
+		}
+		instance.Status.Conditions = conditions
+		return
+	}
 
+	if instance.Status.ServiceID != instance.Spec.ServiceID {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceIDAppliedCondition,
+			keystonev1.ServiceIDNotAppliedReason,
+			condition.SeverityWarning,
+			keystonev1.KeystoneServiceIDAppliedMismatchMessage,
+			instance.Status.ServiceID,
+			instance.Spec.ServiceID))
+		return
+	}
+	instance.Status.Conditions.MarkTrue(
+		keystonev1.KeystoneServiceIDAppliedCondition,
+		keystonev1.KeystoneServiceIDAppliedMessage)
+}
+
+// workloadReady - returns true if a Deployment or StatefulSet observed its
+// current generation and all of its, at least one, replicas are ready
+func workloadReady(generation int64, observedGeneration int64, replicas *int32, readyReplicas int32) bool {
+	desired := int32(1)
+	if replicas != nil {
+		desired = *replicas
+	}
+
+	return observedGeneration >= generation && desired > 0 && readyReplicas >= desired
 }
 
 // SetupWithManager x
 func (r *KeystoneServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&keystonev1.KeystoneService{}).
+		Watches(
+			&source.Kind{Type: &appsv1.Deployment{}},
+			handler.EnqueueRequestsFromMapFunc(r.servicesWithBackend(keystonev1.BackendKindDeployment))).
+		Watches(
+			&source.Kind{Type: &appsv1.StatefulSet{}},
+			handler.EnqueueRequestsFromMapFunc(r.servicesWithBackend(keystonev1.BackendKindStatefulSet))).
+		Watches(
+			&source.Kind{Type: &keystonev1.KeystoneService{}},
+			handler.EnqueueRequestsFromMapFunc(r.servicesDependingOn)).
 		Complete(r)
 }
 
+// servicesDependingOn - requests the KeystoneServices of the namespace whose
+// Spec.DependsOn lists the KeystoneService obj
+func (r *KeystoneServiceReconciler) servicesDependingOn(obj client.Object) []reconcile.Request {
+	services := &keystonev1.KeystoneServiceList{}
+	if err := r.Client.List(context.TODO(), services, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Unable to list KeystoneServices")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, s := range services.Items {
+		for _, name := range s.Spec.DependsOn {
+			if name == obj.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: s.Name, Namespace: s.Namespace},
+				})
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// servicesWithBackend - returns a handler.MapFunc requesting the
+// KeystoneServices whose Spec.Backend is the object of kind
+func (r *KeystoneServiceReconciler) servicesWithBackend(kind string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		services := &keystonev1.KeystoneServiceList{}
+		if err := r.Client.List(context.TODO(), services, client.InNamespace(obj.GetNamespace())); err != nil {
+			r.Log.Error(err, "Unable to list KeystoneServices")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, s := range services.Items {
+			if s.Spec.Backend != nil && s.Spec.Backend.Kind == kind && s.Spec.Backend.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: s.Name, Namespace: s.Namespace},
+				})
+			}
+		}
+
+		return requests
+	}
+}
+
 func (r *KeystoneServiceReconciler) reconcileDelete(
 	ctx context.Context,
 	instance *keystonev1.KeystoneService,
 	helper *helper.Helper,
-	os *openstack.OpenStack,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
 ) (ctrl.Result, error) {
-	r.Log.Info("Reconciling Service delete")
+	r.Log.V(1).Info("Reconciling Service delete")
 
 	// only cleanup the service if there is the ServiceID reference in the
 	// object status
-	if instance.Status.ServiceID != "" {
+	if instance.Status.ServiceID != "" && instance.Spec.DeletionPolicy == keystonev1.DeletionPolicyDisable && !instance.IsServiceManaged() {
+		r.Log.Info(fmt.Sprintf("Not disabling service %s as the operator does not manage it", instance.Spec.ServiceName))
+
+	} else if instance.Status.ServiceID != "" && instance.Spec.DeletionPolicy == keystonev1.DeletionPolicyDisable {
+		reauth := adminClientReauth(ctx, helper, keystoneAPI, identityClientFactory(r.NewIdentityClient, r.ClientOptions), instance, &instance.Status.Conditions)
+
+		// Disable Service and the additional services, the user is kept
+		_, ctrlResult, err := reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			for _, serviceID := range append([]string{instance.Status.ServiceID}, instance.Status.AdditionalServiceIDs...) {
+				err := keystone.DisableService(
+					r.Log,
+					os,
+					serviceID)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+
+			return ctrl.Result{}, nil
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, ServiceDisabledReason,
+			"Service %s with ID %s disabled", instance.Spec.ServiceName, instance.Status.ServiceID)
+
+	} else if instance.Status.ServiceID != "" {
+		reauth := adminClientReauth(ctx, helper, keystoneAPI, identityClientFactory(r.NewIdentityClient, r.ClientOptions), instance, &instance.Status.Conditions)
+
 		// Delete User
-		err := os.DeleteUser(
-			r.Log,
-			instance.Spec.ServiceUser)
+		var ctrlResult ctrl.Result
+		var err error
+		os, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			return ctrl.Result{}, os.DeleteUser(
+				r.Log,
+				instance.Spec.ServiceUser)
+		})
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
 
-		// Delete Service
-		err = os.DeleteService(
-			r.Log,
-			instance.Status.ServiceID)
+		// Delete the endpoint groups, Service and the additional services,
+		// services the operator does not manage are kept
+		_, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+			for _, g := range instance.Status.EndpointGroups {
+				err := os.DeleteEndpointGroup(r.Log, g.ID)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			if !instance.IsServiceManaged() {
+				return ctrl.Result{}, nil
+			}
+			for _, serviceID := range instance.Status.AdditionalServiceIDs {
+				err := os.DeleteService(
+					r.Log,
+					serviceID)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+
+			return ctrl.Result{}, os.DeleteService(
+				r.Log,
+				instance.Status.ServiceID)
+		})
 		if err != nil {
 			r.Log.Info(err.Error())
 			return ctrl.Result{}, err
 		}
+		if (ctrlResult != ctrl.Result{}) {
+			return ctrlResult, nil
+		}
+		if instance.IsServiceManaged() {
+			recordEvent(r.Recorder, instance, corev1.EventTypeNormal, ServiceDeletedReason,
+				"Service %s with ID %s deleted", instance.Spec.ServiceName, instance.Status.ServiceID)
+		}
 
 	} else {
 		r.Log.Info(fmt.Sprintf("Not deleting service %s as there is no stores service ID", instance.Spec.ServiceName))
 	}
 
 	// Service is deleted so remove the finalizer.
-	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
-	r.Log.Info("Reconciled Service delete successfully")
+	removeFinalizers(instance, helper)
+	r.Log.V(1).Info("Reconciled Service delete successfully")
 	if err := r.Update(ctx, instance); err != nil && !k8s_errors.IsNotFound(err) {
 		return ctrl.Result{}, err
 	}
@@ -269,52 +798,83 @@ func (r *KeystoneServiceReconciler) reconcileNormal(
 	ctx context.Context,
 	instance *keystonev1.KeystoneService,
 	helper *helper.Helper,
-	os *openstack.OpenStack,
+	keystoneAPI *keystonev1.KeystoneAPI,
+	os keystone.IdentityClient,
 ) (ctrl.Result, error) {
-	r.Log.Info("Reconciling Service")
+	r.Log.V(1).Info("Reconciling Service")
 
-	// If the service object doesn't have our finalizer, add it.
-	controllerutil.AddFinalizer(instance, helper.GetFinalizer())
+	// Add our finalizer to the service object, or remove it if it is disabled.
 	// Register the finalizer immediately to avoid orphaning resources on delete
-	if err := r.Update(ctx, instance); err != nil {
+	if err := registerFinalizer(ctx, r.Client, instance, helper); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	reauth := adminClientReauth(ctx, helper, keystoneAPI, identityClientFactory(r.NewIdentityClient, r.ClientOptions), instance, &instance.Status.Conditions)
+
 	//
 	// Create new service if ServiceID is not already set
 	//
-	err := r.reconcileService(instance, os)
-	if err != nil {
+	os, ctrlResult, err := reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+		if err := r.reconcileService(ctx, instance, os); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, r.reportManagedCatalog(instance, os)
+	})
+	if errors.Is(err, keystone.ErrServiceNotConfirmed) {
+		// the created service is not visible on all keystone replicas yet,
+		// it gets looked up again instead of creating a duplicate
 		instance.Status.Conditions.Set(condition.FalseCondition(
 			keystonev1.KeystoneServiceOSServiceReadyCondition,
-			condition.ErrorReason,
-			condition.SeverityWarning,
-			keystonev1.KeystoneServiceOSServiceReadyErrorMessage,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneServiceOSServiceReadyNotConfirmedMessage,
+			instance.Spec.ServiceName,
 			err.Error()))
+		return ctrl.Result{RequeueAfter: keystone.ServiceConfirmInterval}, nil
+	}
+	if err != nil {
+		instance.Status.Conditions.Set(keystoneErrorCondition(
+			keystonev1.KeystoneServiceOSServiceReadyCondition,
+			keystonev1.KeystoneServiceOSServiceReadyErrorMessage,
+			err))
 		return ctrl.Result{}, err
 	}
-	instance.Status.Conditions.MarkTrue(
-		keystonev1.KeystoneServiceOSServiceReadyCondition,
-		keystonev1.KeystoneServiceOSServiceReadyMessage,
-		instance.Spec.ServiceName,
-		instance.Status.ServiceID,
-	)
+	if (ctrlResult != ctrl.Result{}) {
+		return ctrlResult, nil
+	}
+	if len(instance.Status.AdoptedServices) > 0 {
+		instance.Status.Conditions.MarkTrue(
+			keystonev1.KeystoneServiceOSServiceReadyCondition,
+			keystonev1.KeystoneServiceOSServiceReadyAdoptedMessage,
+			instance.Spec.ServiceName,
+			instance.Status.ServiceID,
+			strings.Join(instance.Status.AdoptedServices, ", "),
+		)
+	} else {
+		instance.Status.Conditions.MarkTrue(
+			keystonev1.KeystoneServiceOSServiceReadyCondition,
+			keystonev1.KeystoneServiceOSServiceReadyMessage,
+			instance.Spec.ServiceName,
+			instance.Status.ServiceID,
+		)
+	}
 
 	//
 	// create/update service user
 	//
-	ctrlResult, err := r.reconcileUser(
-		ctx,
-		helper,
-		instance,
-		os)
+	_, ctrlResult, err = reauthOnUnauthorized(os, reauth, func(os keystone.IdentityClient) (ctrl.Result, error) {
+		return r.reconcileUser(
+			ctx,
+			helper,
+			instance,
+			os)
+	})
 	if err != nil {
-		instance.Status.Conditions.Set(condition.FalseCondition(
+		instance.Status.Conditions.Set(keystoneErrorCondition(
 			keystonev1.KeystoneServiceOSUserReadyCondition,
-			condition.ErrorReason,
-			condition.SeverityWarning,
 			keystonev1.KeystoneServiceOSUserReadyErrorMessage,
-			err.Error()))
+			err))
 		return ctrlResult, err
 	} else if (ctrlResult != ctrl.Result{}) {
 		instance.Status.Conditions.Set(condition.FalseCondition(
@@ -330,77 +890,436 @@ func (r *KeystoneServiceReconciler) reconcileNormal(
 		instance.Spec.ServiceUser,
 	)
 
-	r.Log.Info("Reconciled Service successfully")
-	return ctrl.Result{}, nil
+	hash, err := util.ObjectHash(instance.Spec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	instance.Status.Hash = hash
+	instance.Status.ObservedGeneration = instance.Generation
+	now := metav1.Now()
+	instance.Status.LastSyncTime = &now
+	if requestID := changedRequestID(ctx); requestID != "" {
+		instance.Status.LastChangeRequestID = requestID
+		r.Log.Info(fmt.Sprintf("Service %s changed in keystone with request ID %s", instance.Spec.ServiceName, requestID))
+	}
+
+	r.Log.V(1).Info("Reconciled Service successfully")
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
 }
 
 func (r *KeystoneServiceReconciler) reconcileService(
+	ctx context.Context,
 	instance *keystonev1.KeystoneService,
-	os *openstack.OpenStack,
+	os keystone.IdentityClient,
 ) error {
-	r.Log.Info(fmt.Sprintf("Reconciling Service %s", instance.Spec.ServiceName))
+	r.Log.V(1).Info(fmt.Sprintf("Reconciling Service %s", instance.Spec.ServiceName))
 
-	// verify if there is already a service in keystone for the type and name
-	service, err := os.GetService(
-		r.Log,
-		instance.Spec.ServiceType,
-		instance.Spec.ServiceName,
+	// the services are only enabled while the backend is ready
+	spec := instance.Spec
+	spec.Enabled = instance.IsEnabled()
+	spec.NameLookup = instance.GetNameLookup()
+	instance.Status.NameLookup = spec.NameLookup
+
+	// the services registered by another tool are only looked up
+	if !instance.IsServiceManaged() {
+		return r.lookupServices(instance, os, spec)
+	}
+
+	// the services get associated with the domain by ID
+	instance.Status.DomainID = ""
+	if instance.Spec.Domain != "" {
+		domainID, err := os.GetDomainID(r.Log, instance.Spec.Domain)
+		if err != nil {
+			return err
+		}
+		instance.Status.DomainID = domainID
+	}
+
+	// the changes of the services get recorded as a single update event,
+	// unless the service got registered or adopted
+	changedBefore := changedRequestID(ctx) != ""
+
+	serviceID, adopted, err := r.reconcileOSService(
+		ctx,
+		os,
+		spec,
+		instance.Status,
+		instance.Namespace,
 	)
-	// If the service is not found, don't count that as an error here,
-	// it gets created bellow
-	if err != nil && !strings.Contains(err.Error(), openstack.ServiceNotFound) {
+	if errors.Is(err, keystone.ErrServiceNotConfirmed) {
+		// track the created service to find it by ID instead of creating it again
+		instance.Status.ServiceID = serviceID
+		markServiceNotConfirmed(instance)
+	}
+	if err != nil {
 		return err
 	}
+	// persist the ID of a newly registered service right away
+	registered := instance.Status.ServiceID != serviceID
+	instance.Status.ServiceID = serviceID
+	if registered {
+		persistProgress(ctx, r.Client, r.Log, instance, "service "+instance.Spec.ServiceName)
+	}
+	adoptedServices := sets.NewString(instance.Status.AdoptedServices...)
+	if adopted {
+		adoptedServices.Insert(instance.Spec.ServiceName)
+	}
+	r.recordServiceRegistered(instance, instance.Spec.ServiceName, serviceID, registered, adopted)
+	reportServiceID(instance)
 
-	if service == nil {
-		// create the service
-		instance.Status.ServiceID, err = os.CreateService(
-			r.Log,
-			openstack.Service{
-				Name:        instance.Spec.ServiceName,
-				Type:        instance.Spec.ServiceType,
-				Description: instance.Spec.ServiceDescription,
-				Enabled:     instance.Spec.Enabled,
+	//
+	// create/update the additional services, tracked by index in the status
+	//
+	serviceIDs := []string{}
+	for i, svc := range instance.Spec.AdditionalServices {
+		status := keystonev1.KeystoneServiceStatus{
+			DomainID:                instance.Status.DomainID,
+			ManagedFields:           instance.Status.ManagedFields,
+			UnconfirmedServiceSince: instance.Status.UnconfirmedServiceSince,
+		}
+		if i < len(instance.Status.AdditionalServiceIDs) {
+			status.ServiceID = instance.Status.AdditionalServiceIDs[i]
+		}
+
+		serviceID, adopted, err := r.reconcileOSService(
+			ctx,
+			os,
+			keystonev1.KeystoneServiceSpec{
+				ServiceType:        svc.ServiceType,
+				ServiceName:        svc.ServiceName,
+				ServiceDescription: svc.ServiceDescription,
+				Enabled:            spec.Enabled,
+				NameLookup:         spec.NameLookup,
+				ConflictPolicy:     instance.Spec.ConflictPolicy,
+				Tags:               instance.Spec.Tags,
+			},
+			status,
+			instance.Namespace,
+		)
+		if errors.Is(err, keystone.ErrServiceNotConfirmed) {
+			// track the created service by its index, keeping the IDs of
+			// the services not reconciled yet
+			ids := append(serviceIDs, serviceID)
+			if i+1 < len(instance.Status.AdditionalServiceIDs) {
+				ids = append(ids, instance.Status.AdditionalServiceIDs[i+1:]...)
+			}
+			instance.Status.AdditionalServiceIDs = ids
+			markServiceNotConfirmed(instance)
+		}
+		if err != nil {
+			return err
+		}
+		serviceIDs = append(serviceIDs, serviceID)
+		if adopted {
+			adoptedServices.Insert(svc.ServiceName)
+		}
+		r.recordServiceRegistered(instance, svc.ServiceName, serviceID, status.ServiceID != serviceID, adopted)
+	}
+
+	// delete the services which got removed from Spec.AdditionalServices
+	current := sets.NewString(serviceIDs...)
+	current.Insert(instance.Status.ServiceID)
+	for _, serviceID := range instance.Status.AdditionalServiceIDs {
+		if current.Has(serviceID) {
+			continue
+		}
+		err = os.DeleteService(r.Log, serviceID)
+		if err != nil {
+			return err
+		}
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, ServiceDeletedReason,
+			"Additional service with ID %s deleted", serviceID)
+	}
+	instance.Status.AdditionalServiceIDs = serviceIDs
+	// all created services are visible
+	instance.Status.UnconfirmedServiceSince = nil
+	// the additional services share the tags of the service, the record
+	// gets replaced once all of them are reconciled
+	instance.Status.ManagedFields = &keystonev1.ManagedServiceFields{
+		Type:        spec.ServiceType,
+		Name:        spec.ServiceName,
+		Description: spec.ServiceDescription,
+		Enabled:     spec.Enabled,
+		Tags:        spec.Tags,
+	}
+	if adoptedServices.Len() > 0 {
+		instance.Status.AdoptedServices = adoptedServices.List()
+	}
+
+	//
+	// propagate the enabled state of the services to their endpoints
+	//
+	if instance.Spec.PropagateEnabledToEndpoints {
+		err = r.propagateEnabled(instance, os, current.List(), spec.Enabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.reconcileEndpointGroups(instance, os)
+	if err != nil {
+		return err
+	}
+
+	if requestID := changedRequestID(ctx); requestID != "" && !changedBefore && !registered && !adopted {
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, ServiceUpdatedReason,
+			"Service %s updated with request ID %s", instance.Spec.ServiceName, requestID)
+	}
+
+	r.Log.V(1).Info("Reconciled Service successfully")
+	return nil
+}
+
+// markServiceNotConfirmed - records when a created service was first not
+// visible in keystone, to bound how long it is looked up again
+func markServiceNotConfirmed(instance *keystonev1.KeystoneService) {
+	if instance.Status.UnconfirmedServiceSince == nil {
+		now := metav1.Now()
+		instance.Status.UnconfirmedServiceSince = &now
+	}
+}
+
+// lookupServices - looks up the service and the additional services the
+// operator does not manage, by their IDs in the status or the NameLookup, and
+// reconciles the endpoint groups over them. Nothing of the services gets
+// created or updated.
+func (r *KeystoneServiceReconciler) lookupServices(
+	instance *keystonev1.KeystoneService,
+	os keystone.IdentityClient,
+	spec keystonev1.KeystoneServiceSpec,
+) error {
+	serviceIDs := []string{}
+	for _, d := range instance.GetServiceDefinitions() {
+		spec.ServiceType = d.ServiceType
+		spec.ServiceName = d.ServiceName
+
+		serviceID, err := keystone.LookupService(r.Log, os, spec, instance.GetServiceID(d.ServiceName))
+		if err != nil {
+			return err
+		}
+		serviceIDs = append(serviceIDs, serviceID)
+	}
+	instance.Status.ServiceID = serviceIDs[0]
+	instance.Status.AdditionalServiceIDs = nil
+	if len(serviceIDs) > 1 {
+		instance.Status.AdditionalServiceIDs = serviceIDs[1:]
+	}
+	// the operator set none of the attributes of the services
+	instance.Status.ManagedFields = nil
+	instance.Status.AdoptedServices = nil
+
+	return r.reconcileEndpointGroups(instance, os)
+}
+
+// reportManagedCatalog - sets Status.ManagedCatalog to the services of
+// instance and their endpoints in keystone, in all regions
+func (r *KeystoneServiceReconciler) reportManagedCatalog(
+	instance *keystonev1.KeystoneService,
+	os keystone.IdentityClient,
+) error {
+	catalog := []keystonev1.ManagedCatalogService{}
+	for _, d := range instance.GetServiceDefinitions() {
+		serviceID := instance.GetServiceID(d.ServiceName)
+		if serviceID == "" {
+			continue
+		}
+
+		allEndpoints, err := os.GetServiceEndpoints(r.Log, serviceID)
+		if err != nil {
+			return err
+		}
+		endpointsEnabled, err := os.GetServiceEndpointsEnabled(r.Log, serviceID)
+		if err != nil {
+			return err
+		}
+
+		service := keystonev1.ManagedCatalogService{
+			ID:   serviceID,
+			Type: d.ServiceType,
+			Name: d.ServiceName,
+		}
+		for _, e := range allEndpoints {
+			service.Endpoints = append(service.Endpoints, keystonev1.ManagedCatalogEndpoint{
+				ID:        e.ID,
+				Interface: string(e.Availability),
+				Region:    e.Region,
+				URL:       e.URL,
+				Enabled:   endpointsEnabled[e.ID],
 			})
+		}
+		sort.Slice(service.Endpoints, func(i, j int) bool {
+			a, b := service.Endpoints[i], service.Endpoints[j]
+			if a.Interface != b.Interface {
+				return a.Interface < b.Interface
+			}
+			if a.Region != b.Region {
+				return a.Region < b.Region
+			}
+			return a.ID < b.ID
+		})
+		catalog = append(catalog, service)
+	}
+	instance.Status.ManagedCatalog = catalog
+
+	return nil
+}
+
+// propagateEnabled - enables or disables the endpoints of the services with
+// serviceIDs in the same reconcile as the services. The endpoints disabled by
+// the propagation are recorded in the status, with the Propagated
+// EndpointReenablePolicy only they get enabled again.
+func (r *KeystoneServiceReconciler) propagateEnabled(
+	instance *keystonev1.KeystoneService,
+	os keystone.IdentityClient,
+	serviceIDs []string,
+	enabled bool,
+) error {
+	propagated := sets.NewString(instance.Status.PropagationDisabledEndpointIDs...)
+	existing := sets.NewString()
+	toggled := []string{}
+	for _, serviceID := range serviceIDs {
+		endpointsEnabled, err := os.GetServiceEndpointsEnabled(r.Log, serviceID)
 		if err != nil {
 			return err
 		}
-	} else if service.Enabled != instance.Spec.Enabled ||
-		service.Extra["description"] != instance.Spec.ServiceDescription {
-		// update the service ONLY if Enabled or Description changed.
-		err := os.UpdateService(
+
+		for endpointID, endpointEnabled := range endpointsEnabled {
+			existing.Insert(endpointID)
+			if endpointEnabled == enabled {
+				continue
+			}
+			if enabled && !propagated.Has(endpointID) &&
+				instance.Spec.EndpointReenablePolicy == keystonev1.EndpointReenablePolicyPropagated {
+				continue
+			}
+
+			err = os.SetEndpointEnabled(r.Log, endpointID, enabled)
+			if err != nil {
+				return err
+			}
+			toggled = append(toggled, endpointID)
+		}
+	}
+
+	// the record only keeps the endpoints which still exist
+	if enabled {
+		propagated.Delete(toggled...)
+	} else {
+		propagated.Insert(toggled...)
+	}
+	instance.Status.PropagationDisabledEndpointIDs = propagated.Intersection(existing).List()
+
+	if len(toggled) > 0 {
+		sort.Strings(toggled)
+		r.Log.Info(fmt.Sprintf("Endpoints %s of service %s set enabled %t", strings.Join(toggled, ", "), instance.Spec.ServiceName, enabled))
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, EndpointUpdatedReason,
+			"Endpoints %s set enabled %t with the services", strings.Join(toggled, ", "), enabled)
+	}
+
+	return nil
+}
+
+// reconcileEndpointGroups - creates or updates the Spec.EndpointGroups over
+// the endpoints of the service and their project associations, and deletes
+// the endpoint groups which got removed from the spec
+func (r *KeystoneServiceReconciler) reconcileEndpointGroups(
+	instance *keystonev1.KeystoneService,
+	os keystone.IdentityClient,
+) error {
+	groups := map[string]keystonev1.EndpointGroupStatus{}
+	for _, g := range instance.Spec.EndpointGroups {
+		filters := map[string]string{"service_id": instance.Status.ServiceID}
+		if g.Interface != "" {
+			filters["interface"] = g.Interface
+		}
+		if g.Region != "" {
+			filters["region_id"] = g.Region
+		}
+
+		groupID, err := keystone.ReconcileEndpointGroup(
 			r.Log,
-			openstack.Service{
-				Name:        instance.Spec.ServiceName,
-				Type:        instance.Spec.ServiceType,
-				Description: instance.Spec.ServiceDescription,
-				Enabled:     instance.Spec.Enabled,
-			},
-			service.ID)
+			os,
+			keystone.EndpointGroup{Name: g.Name, Description: g.Description, Filters: filters},
+			g.ProjectIDs,
+			instance.Status.EndpointGroups[g.Name].ProjectIDs,
+		)
 		if err != nil {
 			return err
 		}
+		groups[g.Name] = keystonev1.EndpointGroupStatus{ID: groupID, ProjectIDs: g.ProjectIDs}
+	}
+
+	for name, g := range instance.Status.EndpointGroups {
+		if _, ok := groups[name]; ok {
+			continue
+		}
+		err := os.DeleteEndpointGroup(r.Log, g.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	instance.Status.EndpointGroups = nil
+	if len(groups) > 0 {
+		instance.Status.EndpointGroups = groups
 	}
 
-	r.Log.Info("Reconciled Service successfully")
 	return nil
 }
 
+// recordServiceRegistered - records an event for the service serviceName
+// with serviceID if it got registered or adopted
+func (r *KeystoneServiceReconciler) recordServiceRegistered(
+	instance *keystonev1.KeystoneService,
+	serviceName string,
+	serviceID string,
+	registered bool,
+	adopted bool,
+) {
+	switch {
+	case adopted:
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, ServiceAdoptedReason,
+			"Service %s with ID %s adopted", serviceName, serviceID)
+	case registered:
+		recordEvent(r.Recorder, instance, corev1.EventTypeNormal, ServiceCreatedReason,
+			"Service %s registered with ID %s", serviceName, serviceID)
+	}
+}
+
+// reconcileOSService - creates or updates the keystone service of spec,
+// traced as a keystone.ReconcileService span
+func (r *KeystoneServiceReconciler) reconcileOSService(
+	ctx context.Context,
+	os keystone.IdentityClient,
+	spec keystonev1.KeystoneServiceSpec,
+	status keystonev1.KeystoneServiceStatus,
+	renameSuffix string,
+) (string, bool, error) {
+	_, span := startSpan(ctx, "keystone.ReconcileService",
+		serviceTypeAttr.String(spec.ServiceType),
+		serviceNameAttr.String(spec.ServiceName),
+		regionAttr.String(os.GetRegionID()),
+	)
+	serviceID, adopted, err := keystone.ReconcileService(r.Log, os, spec, status, renameSuffix)
+	endSpan(span, err)
+
+	return serviceID, adopted, err
+}
+
 func (r *KeystoneServiceReconciler) reconcileUser(
 	ctx context.Context,
 	h *helper.Helper,
 	instance *keystonev1.KeystoneService,
-	os *openstack.OpenStack,
+	os keystone.IdentityClient,
 ) (reconcile.Result, error) {
-	r.Log.Info(fmt.Sprintf("Reconciling User %s", instance.Spec.ServiceUser))
+	r.Log.V(1).Info(fmt.Sprintf("Reconciling User %s", instance.Spec.ServiceUser))
 	roleName := "admin"
 
 	// get the password of the service user from the secret
-	password, ctrlResult, err := secret.GetDataFromSecret(
+	password, ctrlResult, err := r.ClientOptions.GetCredentials().GetCredential(
 		ctx,
 		h,
 		instance.Spec.Secret,
-		10,
 		instance.Spec.PasswordSelector)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -414,7 +1333,7 @@ func (r *KeystoneServiceReconciler) reconcileUser(
 	//
 	serviceProjectID, err := os.CreateProject(
 		r.Log,
-		openstack.Project{
+		keystone.Project{
 			Name:        "service",
 			Description: "service",
 		})
@@ -437,7 +1356,7 @@ func (r *KeystoneServiceReconciler) reconcileUser(
 	//
 	userID, err := os.CreateUser(
 		r.Log,
-		openstack.User{
+		keystone.User{
 			Name:      instance.Spec.ServiceUser,
 			Password:  password,
 			ProjectID: serviceProjectID,
@@ -458,6 +1377,6 @@ func (r *KeystoneServiceReconciler) reconcileUser(
 		return ctrl.Result{}, err
 	}
 
-	r.Log.Info("Reconciled User successfully")
+	r.Log.V(1).Info("Reconciled User successfully")
 	return ctrl.Result{}, nil
 }
@@ -0,0 +1,1419 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	keystone "github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	timeout  = time.Second * 20
+	interval = time.Millisecond * 250
+)
+
+// createReadyKeystoneAPI - creates a bootstrapped KeystoneAPI in namespace
+// and marks it ready, the reconcilers only wait for it as the fake client is
+// used
+func createReadyKeystoneAPI(namespace string) {
+	keystoneAPI := createReadyKeystoneAPIWithoutBootstrapHash(namespace, map[string]string{})
+
+	keystoneAPI.Status.Hash = map[string]string{keystonev1.BootstrapHash: "bootstrapped"}
+	Expect(k8sClient.Status().Update(ctx, keystoneAPI)).To(Succeed())
+}
+
+// createReadyKeystoneAPIWithoutBootstrapHash - creates a KeystoneAPI with
+// annotations in namespace and marks it ready, without the bootstrap hash in
+// the status
+func createReadyKeystoneAPIWithoutBootstrapHash(namespace string, annotations map[string]string) *keystonev1.KeystoneAPI {
+	keystoneAPI := &keystonev1.KeystoneAPI{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "keystone",
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: keystonev1.KeystoneAPISpec{
+			Secret: "osp-secret",
+		},
+	}
+	Expect(k8sClient.Create(ctx, keystoneAPI)).To(Succeed())
+
+	keystoneAPI.Status.Conditions = condition.Conditions{}
+	keystoneAPI.Status.Conditions.MarkTrue(condition.ExposeServiceReadyCondition, condition.ReadyMessage)
+	keystoneAPI.Status.Conditions.MarkTrue(condition.DeploymentReadyCondition, condition.ReadyMessage)
+	Expect(k8sClient.Status().Update(ctx, keystoneAPI)).To(Succeed())
+
+	return keystoneAPI
+}
+
+var _ = Describe("KeystoneService controller", func() {
+	var namespace string
+
+	BeforeEach(func() {
+		skipWithoutEnvtest()
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "osp-secret",
+				Namespace: namespace,
+			},
+			StringData: map[string]string{
+				"PlacementPassword": "12345678",
+			},
+		})).To(Succeed())
+
+		createReadyKeystoneAPI(namespace)
+	})
+
+	It("registers the service, its user and endpoints", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType:        "placement",
+				ServiceName:        "placement",
+				ServiceDescription: "Placement service",
+				Enabled:            true,
+				ServiceUser:        "placement",
+				Secret:             "osp-secret",
+				PasswordSelector:   "PlacementPassword",
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		serviceKey := types.NamespacedName{Name: "placement", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, serviceKey, service); err != nil {
+				return false
+			}
+			return service.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(service.Status.AuthURL).To(Equal(identityClient.GetAuthURL()))
+		Expect(service.Status.IdentityEndpoint).To(Equal(identityClient.GetIdentityEndpoint()))
+		Expect(service.Status.ObservedGeneration).To(Equal(service.Generation))
+		Expect(service.Status.LastSyncTime).NotTo(BeNil())
+		Expect(identityClient.Calls()).To(ContainElements(
+			"CreateService placement",
+			"CreateUser placement",
+		))
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "placement",
+				Endpoints: map[string]string{
+					"public":   "https://placement.example.com",
+					"internal": "http://placement.internal:8778",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "placement", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(endpoint.Status.ServiceID).To(Equal(service.Status.ServiceID))
+		Expect(endpoint.Status.EndpointIDs).To(HaveLen(2))
+		Expect(identityClient.Endpoints(service.Status.ServiceID)).To(Equal(endpoint.Spec.Endpoints))
+
+		By("deleting the endpoints")
+		Expect(k8sClient.Delete(ctx, endpoint)).To(Succeed())
+		Eventually(func() bool {
+			return k8s_errors.IsNotFound(k8sClient.Get(ctx, endpointKey, endpoint))
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Endpoints(service.Status.ServiceID)).To(BeEmpty())
+	})
+
+	It("does not register the service while the KeystoneAPI is not ready", func() {
+		other := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, other)).To(Succeed())
+
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "nova",
+				Namespace: other.Name,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "compute",
+				ServiceName: "nova",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		serviceKey := types.NamespacedName{Name: "nova", Namespace: other.Name}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, serviceKey, service); err != nil {
+				return false
+			}
+			return service.Status.Conditions.IsFalse(keystonev1.KeystoneAPIReadyCondition)
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Calls()).NotTo(ContainElement("CreateService nova"))
+	})
+
+	It("registers the service with the KeystoneAPI of the KeystoneAPINamespace", func() {
+		other := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, other)).To(Succeed())
+
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cinder",
+				Namespace: other.Name,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType:          "volumev3",
+				ServiceName:          "cinder",
+				Enabled:              true,
+				KeystoneAPINamespace: namespace,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		serviceKey := types.NamespacedName{Name: "cinder", Namespace: other.Name}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, serviceKey, service); err != nil {
+				return false
+			}
+			return service.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Calls()).To(ContainElement("CreateService cinder"))
+	})
+
+	It("waits for the bootstrap signaled by the status or the annotation", func() {
+		for _, annotations := range []map[string]string{
+			{},
+			{keystonev1.BootstrapHashAnnotation: "bootstrapped"},
+		} {
+			other := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "keystone-",
+				},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			createReadyKeystoneAPIWithoutBootstrapHash(other.Name, annotations)
+
+			service := &keystonev1.KeystoneService{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cinder",
+					Namespace: other.Name,
+				},
+				Spec: keystonev1.KeystoneServiceSpec{
+					ServiceType: "volumev3",
+					ServiceName: "cinder",
+					Enabled:     true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+			serviceKey := types.NamespacedName{Name: "cinder", Namespace: other.Name}
+			bootstrapped := len(annotations) > 0
+			Eventually(func() bool {
+				if err := k8sClient.Get(ctx, serviceKey, service); err != nil {
+					return false
+				}
+				if bootstrapped {
+					return service.Status.Conditions.IsTrue(keystonev1.KeystoneAPIReadyCondition)
+				}
+				c := service.Status.Conditions.Get(keystonev1.KeystoneAPIReadyCondition)
+				return c != nil && c.Message == keystonev1.KeystoneAPIReadyWaitingBootstrapMessage
+			}, timeout, interval).Should(BeTrue())
+		}
+	})
+
+	It("reports a KeystoneAPI without status as waiting for its controller", func() {
+		other := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, other)).To(Succeed())
+		Expect(k8sClient.Create(ctx, &keystonev1.KeystoneAPI{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "keystone",
+				Namespace: other.Name,
+			},
+			Spec: keystonev1.KeystoneAPISpec{
+				Secret: "osp-secret",
+			},
+		})).To(Succeed())
+
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "glance",
+				Namespace: other.Name,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "image",
+				ServiceName: "glance",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		serviceKey := types.NamespacedName{Name: "glance", Namespace: other.Name}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, serviceKey, service); err != nil {
+				return false
+			}
+			c := service.Status.Conditions.Get(keystonev1.KeystoneAPIReadyCondition)
+			return c != nil && c.Reason == keystonev1.WaitingForAPIControllerReason
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("resolves endpoint URLs from a referenced Service", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "placement",
+				ServiceName: "placement",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "placement",
+				Endpoints: map[string]string{
+					"public": "https://placement.example.com",
+				},
+				EndpointURLRefs: map[string]keystonev1.EndpointURLRef{
+					"internal": {
+						Kind: keystonev1.EndpointURLRefKindService,
+						Name: "placement-internal",
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "placement", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			c := endpoint.Status.Conditions.Get(keystonev1.KeystoneServiceOSEndpointsReadyCondition)
+			return c != nil && c.Reason == condition.RequestedReason
+		}, timeout, interval).Should(BeTrue())
+
+		Expect(k8sClient.Create(ctx, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement-internal",
+				Namespace: namespace,
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Name: "api", Port: 8778}},
+			},
+		})).To(Succeed())
+
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(endpoint.Status.ResolvedEndpoints).To(Equal(map[string]string{
+			"internal": "http://placement-internal." + namespace + ".svc:8778",
+		}))
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).To(HaveKeyWithValue(
+			"internal", "http://placement-internal."+namespace+".svc:8778"))
+	})
+
+	It("substitutes the variables of the endpoint URLs", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "placement",
+				ServiceName: "placement",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "endpoint-hosts",
+				Namespace: namespace,
+			},
+			Data: map[string]string{
+				"PUBLIC_HOST": "placement.example.com",
+			},
+		})).To(Succeed())
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "placement",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "placement",
+				Endpoints: map[string]string{
+					"public": "https://$(KEYSTONE_PUBLIC_HOST)",
+				},
+				URLVariablesFrom: []corev1.EnvFromSource{{
+					Prefix: "KEYSTONE_",
+					ConfigMapRef: &corev1.ConfigMapEnvSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "endpoint-hosts"},
+					},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "placement", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).To(HaveKeyWithValue(
+			"public", "https://placement.example.com"))
+	})
+
+	It("registers the endpoints of the EndpointList with their enabled state", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "glance",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "image",
+				ServiceName: "glance",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		disabled := false
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "glance",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "glance",
+				EndpointList: []keystonev1.EndpointSpec{
+					{Interface: "public", URL: "https://glance.example.com"},
+					{Interface: "internal", URL: "http://glance.internal:9292", Enabled: &disabled},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "glance", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).To(Equal(map[string]string{
+			"public":   "https://glance.example.com",
+			"internal": "http://glance.internal:9292",
+		}))
+		Expect(identityClient.Calls()).To(ContainElement(
+			"SetEndpointEnabled " + endpoint.Status.EndpointIDs["internal"] + " false"))
+		Expect(identityClient.Calls()).NotTo(ContainElement(
+			"SetEndpointEnabled " + endpoint.Status.EndpointIDs["public"] + " false"))
+	})
+
+	It("only enables the service while its backend is ready", func() {
+		labels := map[string]string{"app": "barbican"}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "barbican",
+				Namespace: namespace,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "api", Image: "barbican"}},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "barbican",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "key-manager",
+				ServiceName: "barbican",
+				Enabled:     true,
+				Backend: &keystonev1.BackendRef{
+					Kind: keystonev1.BackendKindDeployment,
+					Name: "barbican",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		// serviceEnabled - returns the enabled state of the registered service,
+		// empty while it is not registered
+		serviceKey := types.NamespacedName{Name: "barbican", Namespace: namespace}
+		serviceEnabled := func() string {
+			if err := k8sClient.Get(ctx, serviceKey, service); err != nil || !service.IsReady() {
+				return ""
+			}
+			s, err := identityClient.GetServiceByID(logr.Discard(), service.Status.ServiceID)
+			if err != nil || s == nil {
+				return ""
+			}
+			return fmt.Sprint(s.Enabled)
+		}
+		Eventually(serviceEnabled, timeout, interval).Should(Equal("false"))
+		backendReady := false
+		Expect(service.Status.BackendReady).To(Equal(&backendReady))
+
+		By("the backend getting ready")
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "barbican", Namespace: namespace}, deployment)).To(Succeed())
+		deployment.Status.ObservedGeneration = deployment.Generation
+		deployment.Status.Replicas = 1
+		deployment.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+		Eventually(serviceEnabled, timeout, interval).Should(Equal("true"))
+	})
+
+	It("keeps the endpoints declared by other KeystoneEndpoints of the service", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "heat",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "orchestration",
+				ServiceName: "heat",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		for endpointType, endpointURL := range map[string]string{
+			"public":   "https://heat.example.com",
+			"internal": "http://heat.internal:8004",
+		} {
+			Expect(k8sClient.Create(ctx, &keystonev1.KeystoneEndpoint{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "heat-" + endpointType,
+					Namespace: namespace,
+				},
+				Spec: keystonev1.KeystoneEndpointSpec{
+					ServiceName: "heat",
+					Endpoints:   map[string]string{endpointType: endpointURL},
+				},
+			})).To(Succeed())
+		}
+
+		endpoint := &keystonev1.KeystoneEndpoint{}
+		endpointKey := types.NamespacedName{Name: "heat-public", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady() &&
+				endpoint.Status.Conditions.IsTrue(keystonev1.KeystoneServiceOSEndpointsInSyncCondition) &&
+				len(identityClient.Endpoints(endpoint.Status.ServiceID)) == 2
+		}, timeout, interval).Should(BeTrue())
+		Expect(endpoint.Status.UndeclaredEndpoints).To(BeEmpty())
+
+		By("deleting one of the KeystoneEndpoints")
+		Expect(k8sClient.Delete(ctx, endpoint)).To(Succeed())
+		Eventually(func() bool {
+			return k8s_errors.IsNotFound(k8sClient.Get(ctx, endpointKey, endpoint))
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).To(Equal(map[string]string{
+			"internal": "http://heat.internal:8004",
+		}))
+	})
+
+	It("reports the health check result per endpoint type", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthcheck" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "barbican",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "key-manager",
+				ServiceName: "barbican",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "barbican",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "barbican",
+				Endpoints: map[string]string{
+					"internal": server.URL,
+					"public":   server.URL + "/v1",
+				},
+				HealthCheck: &keystonev1.EndpointHealthCheck{
+					Path: "/healthcheck",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "barbican", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady() &&
+				endpoint.Status.Conditions.Get(keystonev1.KeystoneEndpointReachableCondition("public")) != nil
+		}, timeout, interval).Should(BeTrue())
+		Expect(endpoint.Status.Conditions.IsTrue(keystonev1.KeystoneEndpointReachableCondition("internal"))).To(BeTrue())
+		c := endpoint.Status.Conditions.Get(keystonev1.KeystoneEndpointReachableCondition("public"))
+		Expect(c.Reason).To(Equal(keystonev1.EndpointUnreachableReason))
+		Expect(c.Message).To(ContainSubstring("/v1/healthcheck returned status 404"))
+	})
+
+	It("registers an endpoint deleted out-of-band again", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "designate",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "dns",
+				ServiceName: "designate",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "designate",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "designate",
+				Endpoints: map[string]string{
+					"internal": "http://designate.internal:9001",
+					"public":   "https://designate.example.com",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "designate", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady() && len(endpoint.Status.EndpointIDs) == 2
+		}, timeout, interval).Should(BeTrue())
+		deletedID := endpoint.Status.EndpointIDs["public"]
+
+		By("deleting the public endpoint in keystone")
+		Expect(identityClient.DeleteEndpointByID(logr.Discard(), deletedID)).To(Succeed())
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).NotTo(HaveKey("public"))
+
+		// any reconcile converges, e.g. one of the resync period
+		endpoint.Annotations = map[string]string{"test": "resync"}
+		Expect(k8sClient.Update(ctx, endpoint)).To(Succeed())
+		Eventually(func() string {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return ""
+			}
+			return endpoint.Status.EndpointIDs["public"]
+		}, timeout, interval).ShouldNot(Or(BeEmpty(), Equal(deletedID)))
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).To(Equal(map[string]string{
+			"internal": "http://designate.internal:9001",
+			"public":   "https://designate.example.com",
+		}))
+	})
+
+	It("deletes the endpoint of an interface removed from the EndpointList", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "octavia",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "load-balancer",
+				ServiceName: "octavia",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "octavia",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "octavia",
+				EndpointList: []keystonev1.EndpointSpec{
+					{Interface: "public", URL: "https://octavia.example.com"},
+					{Interface: "internal", URL: "http://octavia.internal:9876"},
+				},
+				PrunePolicy: keystonev1.PrunePolicyReport,
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "octavia", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		internalID := endpoint.Status.EndpointIDs["internal"]
+
+		endpoint.Spec.EndpointList = endpoint.Spec.EndpointList[:1]
+		Expect(k8sClient.Update(ctx, endpoint)).To(Succeed())
+		Eventually(func() map[string]string {
+			return identityClient.Endpoints(endpoint.Status.ServiceID)
+		}, timeout, interval).Should(Equal(map[string]string{
+			"public": "https://octavia.example.com",
+		}))
+		Expect(identityClient.Calls()).To(ContainElement("DeleteEndpointByID " + internalID))
+	})
+
+	It("only disables the endpoints with the Disable deletion policy", func() {
+		service := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "swift",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "object-store",
+				ServiceName: "swift",
+				Enabled:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+
+		endpoint := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "swift",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "swift",
+				Endpoints: map[string]string{
+					"public": "https://swift.example.com",
+				},
+				DeletionPolicy: keystonev1.DeletionPolicyDisable,
+			},
+		}
+		Expect(k8sClient.Create(ctx, endpoint)).To(Succeed())
+
+		endpointKey := types.NamespacedName{Name: "swift", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, endpointKey, endpoint); err != nil {
+				return false
+			}
+			return endpoint.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		endpointID := endpoint.Status.EndpointIDs["public"]
+
+		Expect(k8sClient.Delete(ctx, endpoint)).To(Succeed())
+		Eventually(func() bool {
+			return k8s_errors.IsNotFound(k8sClient.Get(ctx, endpointKey, endpoint))
+		}, timeout, interval).Should(BeTrue())
+		Expect(identityClient.Calls()).To(ContainElement("SetEndpointEnabled " + endpointID + " false"))
+		Expect(identityClient.Endpoints(endpoint.Status.ServiceID)).To(HaveKey("public"))
+	})
+})
+
+var _ = Describe("KeystoneEndpointSpec Default", func() {
+	It("moves the deprecated Endpoints into the EndpointList", func() {
+		disabled := false
+		spec := keystonev1.KeystoneEndpointSpec{
+			EndpointList: []keystonev1.EndpointSpec{
+				{Interface: "public", URL: "https://old.example.com", Enabled: &disabled},
+			},
+			Endpoints: map[string]string{
+				"public":   "https://placement.example.com",
+				"internal": "http://placement.internal:8778",
+				"admin":    "http://placement.admin:8778",
+			},
+		}
+		declared := keystonev1.KeystoneEndpoint{Spec: spec}.GetDeclaredEndpoints()
+
+		spec.Default()
+		Expect(spec.Endpoints).To(BeNil())
+		Expect(spec.EndpointList).To(Equal([]keystonev1.EndpointSpec{
+			{Interface: "public", URL: "https://placement.example.com", Enabled: &disabled},
+			{Interface: "admin", URL: "http://placement.admin:8778"},
+			{Interface: "internal", URL: "http://placement.internal:8778"},
+		}))
+		Expect(keystonev1.KeystoneEndpoint{Spec: spec}.GetDeclaredEndpoints()).To(Equal(declared))
+	})
+})
+
+var _ = Describe("KeystoneService MaxRetries", func() {
+	It("marks the service failed after MaxRetries failed attempts of a generation", func() {
+		r := &KeystoneServiceReconciler{Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Generation: 1},
+			Spec:       keystonev1.KeystoneServiceSpec{MaxRetries: 2},
+		}
+		instance.Status.Conditions = condition.Conditions{}
+		boom := fmt.Errorf("boom")
+
+		for i := 0; i < 2; i++ {
+			_, err := r.countFailedAttempt(instance, ctrl.Result{}, boom)
+			Expect(err).To(Equal(boom))
+		}
+		_, failed := r.isFailed(instance)
+		Expect(failed).To(BeFalse())
+
+		result, err := r.countFailedAttempt(instance, ctrl.Result{}, boom)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(time.Hour))
+		Expect(instance.Status.FailedAttempts).To(Equal(int32(3)))
+		Expect(instance.Status.Conditions.Get(condition.ReadyCondition).Reason).To(Equal(keystonev1.FailedReason))
+		_, failed = r.isFailed(instance)
+		Expect(failed).To(BeTrue())
+
+		By("changing the spec")
+		instance.Generation = 2
+		_, failed = r.isFailed(instance)
+		Expect(failed).To(BeFalse())
+		_, err = r.countFailedAttempt(instance, ctrl.Result{}, boom)
+		Expect(err).To(Equal(boom))
+		Expect(instance.Status.FailedAttempts).To(Equal(int32(1)))
+	})
+})
+
+var _ = Describe("KeystoneEndpoint MaxRetries", func() {
+	It("counts the failed attempts of the endpoints with their own retry policy", func() {
+		r := &KeystoneEndpointReconciler{Log: ctrl.Log, ResyncPeriod: 10 * time.Minute}
+		instance := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Generation: 1},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				MaxRetries:    1,
+				RetryInterval: &metav1.Duration{Duration: 10 * time.Second},
+			},
+		}
+		instance.Status.Conditions = condition.Conditions{}
+		boom := fmt.Errorf("boom")
+
+		// retried after the RetryInterval instead of the backoff
+		result, err := r.countFailedAttempt(instance, ctrl.Result{}, boom)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(10 * time.Second))
+		Expect(instance.Status.FailedAttempts).To(Equal(int32(1)))
+		_, failed := r.isFailed(instance)
+		Expect(failed).To(BeFalse())
+
+		result, err = r.countFailedAttempt(instance, ctrl.Result{}, boom)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(10 * time.Minute))
+		Expect(instance.Status.Conditions.Get(condition.ReadyCondition).Reason).To(Equal(keystonev1.FailedReason))
+		_, failed = r.isFailed(instance)
+		Expect(failed).To(BeTrue())
+
+		By("reconciling the next generation successfully")
+		instance.Generation = 2
+		instance.Status.Conditions.MarkTrue(keystonev1.KeystoneServiceOSEndpointsReadyCondition, "ready")
+		_, err = r.countFailedAttempt(instance, ctrl.Result{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.FailedAttempts).To(BeZero())
+		Expect(instance.Status.LastFailureTime).To(BeNil())
+
+		By("without RetryInterval the error is returned for the backoff")
+		instance.Spec.RetryInterval = nil
+		_, err = r.countFailedAttempt(instance, ctrl.Result{}, boom)
+		Expect(err).To(Equal(boom))
+	})
+})
+
+var _ = Describe("KeystoneEndpoint ManagedFields", func() {
+	It("records the URLs and only the declared enabled states", func() {
+		disabled := false
+		instance := &keystonev1.KeystoneEndpoint{
+			Spec: keystonev1.KeystoneEndpointSpec{
+				EndpointList: []keystonev1.EndpointSpec{
+					{Interface: "internal", URL: "http://placement.internal:8778"},
+					{Interface: "public", URL: "https://placement.example.com", Enabled: &disabled},
+				},
+			},
+		}
+
+		recordManagedEndpointFields(instance, instance.GetEndpoints())
+		Expect(instance.Status.ManagedFields).To(HaveLen(2))
+		Expect(instance.Status.ManagedFields["internal"]).To(Equal(keystonev1.ManagedEndpointFields{
+			URL: "http://placement.internal:8778",
+		}))
+		Expect(instance.Status.ManagedFields["public"].URL).To(Equal("https://placement.example.com"))
+		Expect(*instance.Status.ManagedFields["public"].Enabled).To(BeFalse())
+	})
+})
+
+var _ = Describe("KeystoneService propagateEnabled", func() {
+	It("only enables the endpoints it disabled with the Propagated policy", func() {
+		os := newFakeIdentityClient("regionOne")
+		publicID, err := os.CreateEndpoint(logr.Discard(), keystone.Endpoint{ServiceID: "s1", Availability: gophercloud.AvailabilityPublic})
+		Expect(err).NotTo(HaveOccurred())
+		internalID, err := os.CreateEndpoint(logr.Discard(), keystone.Endpoint{ServiceID: "s1", Availability: gophercloud.AvailabilityInternal})
+		Expect(err).NotTo(HaveOccurred())
+		// disabled by someone else before the service
+		Expect(os.SetEndpointEnabled(logr.Discard(), internalID, false)).To(Succeed())
+
+		r := &KeystoneServiceReconciler{Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			Spec: keystonev1.KeystoneServiceSpec{
+				EndpointReenablePolicy: keystonev1.EndpointReenablePolicyPropagated,
+			},
+		}
+
+		Expect(r.propagateEnabled(instance, os, []string{"s1"}, false)).To(Succeed())
+		Expect(instance.Status.PropagationDisabledEndpointIDs).To(Equal([]string{publicID}))
+
+		Expect(r.propagateEnabled(instance, os, []string{"s1"}, true)).To(Succeed())
+		Expect(instance.Status.PropagationDisabledEndpointIDs).To(BeEmpty())
+		enabled, err := os.GetServiceEndpointsEnabled(logr.Discard(), "s1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(enabled).To(Equal(map[string]bool{publicID: true, internalID: false}))
+
+		By("enabling all endpoints with the All policy")
+		instance.Spec.EndpointReenablePolicy = keystonev1.EndpointReenablePolicyAll
+		Expect(r.propagateEnabled(instance, os, []string{"s1"}, true)).To(Succeed())
+		enabled, err = os.GetServiceEndpointsEnabled(logr.Discard(), "s1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(enabled).To(Equal(map[string]bool{publicID: true, internalID: true}))
+	})
+})
+
+var _ = Describe("KeystoneService EndpointGroups", func() {
+	It("reconciles the endpoint groups and their projects", func() {
+		os := newFakeIdentityClient("regionOne")
+		r := &KeystoneServiceReconciler{Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			Spec: keystonev1.KeystoneServiceSpec{
+				EndpointGroups: []keystonev1.EndpointGroupSpec{
+					{Name: "placement-internal", Interface: "internal", ProjectIDs: []string{"p1", "p2"}},
+				},
+			},
+		}
+		instance.Status.ServiceID = "s1"
+
+		Expect(r.reconcileEndpointGroups(instance, os)).To(Succeed())
+		group, err := os.GetEndpointGroup(logr.Discard(), "placement-internal")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(group.Filters).To(Equal(map[string]string{"service_id": "s1", "interface": "internal"}))
+		Expect(os.EndpointGroupProjectIDs("placement-internal")).To(Equal([]string{"p1", "p2"}))
+		Expect(instance.Status.EndpointGroups).To(HaveKey("placement-internal"))
+
+		By("removing a project from the spec")
+		instance.Spec.EndpointGroups[0].ProjectIDs = []string{"p2"}
+		Expect(r.reconcileEndpointGroups(instance, os)).To(Succeed())
+		Expect(os.EndpointGroupProjectIDs("placement-internal")).To(Equal([]string{"p2"}))
+
+		By("removing the endpoint group from the spec")
+		instance.Spec.EndpointGroups = nil
+		Expect(r.reconcileEndpointGroups(instance, os)).To(Succeed())
+		group, err = os.GetEndpointGroup(logr.Discard(), "placement-internal")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(group).To(BeNil())
+		Expect(instance.Status.EndpointGroups).To(BeNil())
+	})
+})
+
+var _ = Describe("KeystoneService ManageService", func() {
+	It("only looks up a service it does not manage", func() {
+		os := newFakeIdentityClient("regionOne")
+		serviceID, err := os.CreateService(logr.Discard(), keystone.Service{Type: "placement", Name: "placement"})
+		Expect(err).NotTo(HaveOccurred())
+
+		manage := false
+		r := &KeystoneServiceReconciler{Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType:   "placement",
+				ServiceName:   "placement",
+				Enabled:       true,
+				ManageService: &manage,
+			},
+		}
+
+		Expect(r.reconcileService(ctx, instance, os)).To(Succeed())
+		Expect(instance.Status.ServiceID).To(Equal(serviceID))
+		Expect(instance.Status.ManagedFields).To(BeNil())
+		service, err := os.GetServiceByID(logr.Discard(), serviceID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(service.Enabled).To(BeFalse())
+		Expect(os.Calls()).To(Equal([]string{"CreateService placement"}))
+
+		By("failing on a service which is not registered")
+		instance.Spec.AdditionalServices = []keystonev1.KeystoneServiceDefinition{
+			{ServiceType: "placement-v2", ServiceName: "placement-v2"},
+		}
+		err = r.reconcileService(ctx, instance, os)
+		Expect(err).To(MatchError(keystone.ErrServiceNotRegistered))
+	})
+})
+
+var _ = Describe("KeystoneService unconfirmed service", func() {
+	It("looks up a created service which is not visible yet instead of creating it again", func() {
+		os := newFakeIdentityClient("regionOne")
+		os.lag = true
+
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+		r := &KeystoneServiceReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "placement",
+				ServiceName: "placement",
+				Enabled:     true,
+			},
+		}
+
+		err := r.reconcileService(context.Background(), instance, os)
+		Expect(err).To(MatchError(keystone.ErrServiceNotConfirmed))
+		Expect(instance.Status.ServiceID).NotTo(BeEmpty())
+		Expect(instance.Status.UnconfirmedServiceSince).NotTo(BeNil())
+		since := instance.Status.UnconfirmedServiceSince
+
+		By("reconciling before the service got replicated")
+		err = r.reconcileService(context.Background(), instance, os)
+		Expect(err).To(MatchError(keystone.ErrServiceNotConfirmed))
+		Expect(instance.Status.UnconfirmedServiceSince).To(Equal(since))
+		Expect(os.Calls()).To(Equal([]string{"CreateService placement"}))
+
+		By("reconciling after the service got replicated")
+		os.replicate()
+		Expect(r.reconcileService(context.Background(), instance, os)).To(Succeed())
+		Expect(instance.Status.UnconfirmedServiceSince).To(BeNil())
+		Expect(os.Calls()).To(Equal([]string{"CreateService placement"}))
+	})
+})
+
+var _ = Describe("KeystoneService DependsOn", func() {
+	It("waits until the KeystoneServices it depends on are ready", func() {
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+
+		compute := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "nova", Namespace: "openstack"},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(compute).Build()
+		r := &KeystoneServiceReconciler{Client: c, Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceName: "placement",
+				DependsOn:   []string{"nova", "glance"},
+			},
+			Status: keystonev1.KeystoneServiceStatus{Conditions: condition.Conditions{}},
+		}
+
+		waiting, err := r.reconcileDependencies(ctx, instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(waiting).To(BeTrue())
+		cond := instance.Status.Conditions.Get(keystonev1.KeystoneServiceDependenciesReadyCondition)
+		Expect(cond.Reason).To(Equal(keystonev1.WaitingForDependencyReason))
+		Expect(cond.Message).To(ContainSubstring("nova, glance"))
+
+		By("marking the dependency ready")
+		compute.Status.ServiceID = "1234"
+		compute.Status.Conditions = condition.Conditions{}
+		compute.Status.Conditions.MarkTrue(keystonev1.KeystoneServiceOSServiceReadyCondition, "ready")
+		compute.Status.Conditions.MarkTrue(keystonev1.KeystoneServiceOSUserReadyCondition, "ready")
+		Expect(c.Status().Update(ctx, compute)).To(Succeed())
+		instance.Spec.DependsOn = []string{"nova"}
+
+		waiting, err = r.reconcileDependencies(ctx, instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(waiting).To(BeFalse())
+		Expect(instance.Status.Conditions.IsTrue(keystonev1.KeystoneServiceDependenciesReadyCondition)).To(BeTrue())
+	})
+})
+
+var _ = Describe("KeystoneService ServiceID", func() {
+	It("reports whether the service got registered with the ServiceID", func() {
+		instance := &keystonev1.KeystoneService{
+			Spec: keystonev1.KeystoneServiceSpec{ServiceID: "placement-id"},
+			Status: keystonev1.KeystoneServiceStatus{
+				ServiceID:  "1",
+				Conditions: condition.Conditions{},
+			},
+		}
+
+		// keystone assigned its own ID
+		reportServiceID(instance)
+		cond := instance.Status.Conditions.Get(keystonev1.KeystoneServiceIDAppliedCondition)
+		Expect(cond.Reason).To(Equal(keystonev1.ServiceIDNotAppliedReason))
+		Expect(cond.Message).To(ContainSubstring("ID 1 instead of placement-id"))
+
+		instance.Status.ServiceID = "placement-id"
+		reportServiceID(instance)
+		Expect(instance.Status.Conditions.IsTrue(keystonev1.KeystoneServiceIDAppliedCondition)).To(BeTrue())
+
+		By("removing the ServiceID from the spec")
+		instance.Spec.ServiceID = ""
+		reportServiceID(instance)
+		Expect(instance.Status.Conditions.Get(keystonev1.KeystoneServiceIDAppliedCondition)).To(BeNil())
+	})
+})
+
+var _ = Describe("KeystoneService ManagedCatalog", func() {
+	It("lists the services with their endpoints in keystone", func() {
+		os := newFakeIdentityClient("regionOne")
+		serviceID, err := os.CreateService(logr.Discard(), keystone.Service{Type: "placement", Name: "placement"})
+		Expect(err).NotTo(HaveOccurred())
+		publicID, err := os.CreateEndpoint(logr.Discard(), keystone.Endpoint{
+			Name: "placement", ServiceID: serviceID, Availability: gophercloud.AvailabilityPublic, URL: "https://placement.example.com",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		internalID, err := os.CreateEndpoint(logr.Discard(), keystone.Endpoint{
+			Name: "placement", ServiceID: serviceID, Availability: gophercloud.AvailabilityInternal, URL: "http://placement.openstack.svc:8778",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.SetEndpointEnabled(logr.Discard(), internalID, false)).To(Succeed())
+
+		r := &KeystoneServiceReconciler{Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "placement",
+				ServiceName: "placement",
+				AdditionalServices: []keystonev1.KeystoneServiceDefinition{
+					{ServiceType: "placement-v2", ServiceName: "placement-v2"},
+				},
+			},
+			Status: keystonev1.KeystoneServiceStatus{ServiceID: serviceID},
+		}
+
+		// the additional service is not registered yet
+		Expect(r.reportManagedCatalog(instance, os)).To(Succeed())
+		Expect(instance.Status.ManagedCatalog).To(Equal([]keystonev1.ManagedCatalogService{{
+			ID:   serviceID,
+			Type: "placement",
+			Name: "placement",
+			Endpoints: []keystonev1.ManagedCatalogEndpoint{
+				{ID: internalID, Interface: "internal", Region: "regionOne", URL: "http://placement.openstack.svc:8778", Enabled: false},
+				{ID: publicID, Interface: "public", Region: "regionOne", URL: "https://placement.example.com", Enabled: true},
+			},
+		}}))
+	})
+})
+
+var _ = Describe("KeystoneEndpoint PrunePolicy", func() {
+	var (
+		os         *fakeIdentityClient
+		r          *KeystoneEndpointReconciler
+		instance   *keystonev1.KeystoneEndpoint
+		h          *helper.Helper
+		publicID   string
+		internalID string
+		adminID    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		os = newFakeIdentityClient("regionOne")
+		publicID, err = os.CreateEndpoint(logr.Discard(), keystone.Endpoint{
+			Name: "placement", ServiceID: "s1", Availability: gophercloud.AvailabilityPublic, URL: "https://placement.example.com",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		// registered out-of-band, not tracked in the status
+		internalID, err = os.CreateEndpoint(logr.Discard(), keystone.Endpoint{
+			Name: "placement", ServiceID: "s1", Availability: gophercloud.AvailabilityInternal, URL: "http://placement.openstack.svc:8778",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		// tracked in the status, but removed from the spec
+		adminID, err = os.CreateEndpoint(logr.Discard(), keystone.Endpoint{
+			Name: "placement", ServiceID: "s1", Availability: gophercloud.AvailabilityAdmin, URL: "http://placement.openstack.svc:8778",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		instance = &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "placement",
+				EndpointList: []keystonev1.EndpointSpec{
+					{Interface: "public", URL: "https://placement.example.com"},
+				},
+			},
+			Status: keystonev1.KeystoneEndpointStatus{
+				ServiceID:   "s1",
+				EndpointIDs: map[string]string{"public": publicID, "admin": adminID},
+				Conditions:  condition.Conditions{},
+			},
+		}
+
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance.DeepCopy()).Build()
+		h, err = helper.NewHelper(instance, c, nil, scheme, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		r = &KeystoneEndpointReconciler{
+			Client:   c,
+			Log:      ctrl.Log,
+			Recorder: record.NewFakeRecorder(10),
+			updates:  newUpdateTracker(time.Hour),
+		}
+	})
+
+	It("deletes the endpoints of the undeclared interfaces by default", func() {
+		Expect(r.reconcileEndpoints(context.Background(), instance, h, &keystonev1.KeystoneAPI{}, os, "placement")).To(Succeed())
+
+		Expect(os.Calls()[3:]).To(ConsistOf("DeleteEndpoint placement admin", "DeleteEndpoint placement internal"))
+		Expect(os.Endpoints("s1")).To(Equal(map[string]string{"public": "https://placement.example.com"}))
+		Expect(instance.Status.EndpointIDs).To(Equal(map[string]string{"public": publicID}))
+		Expect(instance.Status.UndeclaredEndpoints).To(BeEmpty())
+		Expect(instance.Status.Conditions.IsTrue(keystonev1.KeystoneServiceOSEndpointsInSyncCondition)).To(BeTrue())
+	})
+
+	It("only reports the untracked endpoints with the report policy", func() {
+		instance.Spec.PrunePolicy = keystonev1.PrunePolicyReport
+
+		Expect(r.reconcileEndpoints(context.Background(), instance, h, &keystonev1.KeystoneAPI{}, os, "placement")).To(Succeed())
+
+		// the endpoint tracked for the removed interface still gets deleted
+		Expect(os.Calls()[3:]).To(Equal([]string{"DeleteEndpointByID " + adminID}))
+		Expect(os.Endpoints("s1")).To(Equal(map[string]string{
+			"public":   "https://placement.example.com",
+			"internal": "http://placement.openstack.svc:8778",
+		}))
+		Expect(instance.Status.EndpointIDs).To(Equal(map[string]string{"public": publicID}))
+		Expect(instance.Status.UndeclaredEndpoints).To(Equal([]string{
+			"regionOne/internal http://placement.openstack.svc:8778 (" + internalID + ")",
+		}))
+		cond := instance.Status.Conditions.Get(keystonev1.KeystoneServiceOSEndpointsInSyncCondition)
+		Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(keystonev1.EndpointDriftReason))
+	})
+})
+
+var _ = Describe("KeystoneService AdditionalServices", func() {
+	It("registers the additional services tracked by index", func() {
+		os := newFakeIdentityClient("regionOne")
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+		r := &KeystoneServiceReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Log: ctrl.Log}
+		instance := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec: keystonev1.KeystoneServiceSpec{
+				ServiceType: "placement",
+				ServiceName: "placement",
+				Enabled:     true,
+				AdditionalServices: []keystonev1.KeystoneServiceDefinition{
+					{ServiceType: "placement-v2", ServiceName: "placement-v2"},
+					{ServiceType: "placement-v3", ServiceName: "placement-v3"},
+				},
+			},
+			Status: keystonev1.KeystoneServiceStatus{Conditions: condition.Conditions{}},
+		}
+
+		Expect(r.reconcileService(context.Background(), instance, os)).To(Succeed())
+		Expect(os.Calls()).To(Equal([]string{
+			"CreateService placement",
+			"CreateService placement-v2",
+			"CreateService placement-v3",
+		}))
+		Expect(instance.Status.AdditionalServiceIDs).To(HaveLen(2))
+		v2ID := instance.Status.AdditionalServiceIDs[0]
+		v3ID := instance.Status.AdditionalServiceIDs[1]
+		Expect(instance.GetServiceID("placement-v2")).To(Equal(v2ID))
+		Expect(instance.GetServiceID("placement-v3")).To(Equal(v3ID))
+		service, err := os.GetServiceByID(logr.Discard(), v3ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(service.Type).To(Equal("placement-v3"))
+
+		By("reconciling again")
+		Expect(r.reconcileService(context.Background(), instance, os)).To(Succeed())
+		Expect(os.Calls()).To(HaveLen(3))
+		Expect(instance.Status.AdditionalServiceIDs).To(Equal([]string{v2ID, v3ID}))
+
+		By("removing an additional service from the spec")
+		instance.Spec.AdditionalServices = instance.Spec.AdditionalServices[1:]
+		Expect(r.reconcileService(context.Background(), instance, os)).To(Succeed())
+		Expect(os.Calls()[3:]).To(Equal([]string{"DeleteService " + v2ID}))
+		Expect(instance.Status.AdditionalServiceIDs).To(Equal([]string{v3ID}))
+		Expect(instance.GetServiceID("placement-v3")).To(Equal(v3ID))
+		Expect(instance.GetServiceID("placement-v2")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("KeystoneService ResyncPeriod", func() {
+	It("skips an unchanged ready service until the resync period passed", func() {
+		instance := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Generation: 1},
+			Spec:       keystonev1.KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement"},
+			Status: keystonev1.KeystoneServiceStatus{
+				ServiceID:          "1",
+				ObservedGeneration: 1,
+				Conditions:         condition.Conditions{},
+			},
+		}
+		instance.Status.Conditions.MarkTrue(keystonev1.KeystoneServiceOSServiceReadyCondition, "ready")
+		instance.Status.Conditions.MarkTrue(keystonev1.KeystoneServiceOSUserReadyCondition, "ready")
+		hash, err := util.ObjectHash(instance.Spec)
+		Expect(err).NotTo(HaveOccurred())
+		instance.Status.Hash = hash
+		lastSync := metav1.NewTime(time.Now().Add(-4 * time.Minute))
+		instance.Status.LastSyncTime = &lastSync
+
+		// without a resync period an unchanged service is never resynced
+		r := &KeystoneServiceReconciler{Log: ctrl.Log}
+		requeueAfter, unchanged := r.isUnchanged(instance)
+		Expect(unchanged).To(BeTrue())
+		Expect(requeueAfter).To(BeZero())
+
+		r.ResyncPeriod = 5 * time.Minute
+		requeueAfter, unchanged = r.isUnchanged(instance)
+		Expect(unchanged).To(BeTrue())
+		Expect(requeueAfter).To(BeNumerically("~", time.Minute, 5*time.Second))
+
+		By("passing the resync period")
+		lastSync = metav1.NewTime(time.Now().Add(-6 * time.Minute))
+		_, unchanged = r.isUnchanged(instance)
+		Expect(unchanged).To(BeFalse())
+
+		By("changing the spec")
+		lastSync = metav1.Now()
+		instance.Spec.ServiceDescription = "Placement service"
+		_, unchanged = r.isUnchanged(instance)
+		Expect(unchanged).To(BeFalse())
+	})
+})
+
+var _ = Describe("KeystoneEndpoint EndpointOrder", func() {
+	It("registers the endpoints in the configured order", func() {
+		os := newFakeIdentityClient("regionOne")
+		instance := &keystonev1.KeystoneEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec: keystonev1.KeystoneEndpointSpec{
+				ServiceName: "placement",
+				EndpointList: []keystonev1.EndpointSpec{
+					{Interface: "public", URL: "https://placement.example.com"},
+					{Interface: "internal", URL: "http://placement.openstack.svc:8778"},
+				},
+			},
+			Status: keystonev1.KeystoneEndpointStatus{ServiceID: "s1", Conditions: condition.Conditions{}},
+		}
+
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance.DeepCopy()).Build()
+		h, err := helper.NewHelper(instance, c, nil, scheme, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		r := &KeystoneEndpointReconciler{
+			Client:   c,
+			Log:      ctrl.Log,
+			Recorder: record.NewFakeRecorder(10),
+			updates:  newUpdateTracker(time.Hour),
+		}
+
+		// the public endpoint moves last by default
+		Expect(r.reconcileEndpoints(context.Background(), instance, h, &keystonev1.KeystoneAPI{}, os, "placement")).To(Succeed())
+		Expect(os.Calls()).To(Equal([]string{
+			"CreateEndpoint placement internal",
+			"CreateEndpoint placement public",
+		}))
+
+		By("moving both URLs with the public endpoint first")
+		instance.Spec.EndpointOrder = []string{"public", "internal"}
+		instance.Spec.EndpointList[0].URL = "https://placement.cloud.example.com"
+		instance.Spec.EndpointList[1].URL = "http://placement.openstack.svc:8779"
+		Expect(r.reconcileEndpoints(context.Background(), instance, h, &keystonev1.KeystoneAPI{}, os, "placement")).To(Succeed())
+		Expect(os.Calls()[2:]).To(Equal([]string{
+			"UpdateEndpoint placement public",
+			"UpdateEndpoint placement internal",
+		}))
+		Expect(os.Endpoints("s1")).To(Equal(map[string]string{
+			"public":   "https://placement.cloud.example.com",
+			"internal": "http://placement.openstack.svc:8779",
+		}))
+	})
+})
@@ -0,0 +1,267 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/go-logr/logr"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	helper "github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	util "github.com/openstack-k8s-operators/lib-common/modules/common/util"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// KeystoneServiceTemplateReconciler reconciles a KeystoneServiceTemplate
+// object into a KeystoneCatalog of the expanded services
+type KeystoneServiceTemplateReconciler struct {
+	client.Client
+	Kclient kubernetes.Interface
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservicetemplates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservicetemplates/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystoneservicetemplates/finalizers,verbs=update
+//+kubebuilder:rbac:groups=keystone.openstack.org,resources=keystonecatalogs,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile keystone service template requests
+func (r *KeystoneServiceTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling", "keystoneservicetemplate", req.NamespacedName)
+
+	// Fetch the KeystoneServiceTemplate instance
+	instance := &keystonev1.KeystoneServiceTemplate{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// The KeystoneCatalog is owned by it and gets garbage collected.
+			// Return and don't requeue
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, err
+	}
+
+	//
+	// initialize status
+	//
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = condition.Conditions{}
+		cl := condition.CreateList(
+			condition.UnknownCondition(keystonev1.KeystoneServiceTemplateServicesReadyCondition, condition.InitReason, keystonev1.KeystoneServiceTemplateServicesReadyInitMessage))
+		instance.Status.Conditions.Init(&cl)
+
+		// Register overall status immediately to have an early feedback e.g. in the cli
+		if err := updateStatus(ctx, r.Client, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	helper, err := helper.NewHelper(
+		instance,
+		r.Client,
+		r.Kclient,
+		r.Scheme,
+		r.Log,
+	)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Always patch the instance status when exiting this function so we can persist any changes.
+	defer func() {
+		// update the overall status condition if the services are ready
+		if instance.IsReady() {
+			instance.Status.Conditions.MarkTrue(condition.ReadyCondition, condition.ReadyMessage)
+		}
+
+		if err := helper.SetAfter(instance); err != nil {
+			util.LogErrorForObject(helper, err, "Set after and calc patch/diff", instance)
+		}
+
+		if changed := helper.GetChanges()["status"]; changed {
+			patch := client.MergeFrom(helper.GetBeforeObject())
+
+			if err := r.Status().Patch(ctx, instance, patch); err != nil && !k8s_errors.IsNotFound(err) {
+				util.LogErrorForObject(helper, err, "Update status", instance)
+			}
+		}
+	}()
+
+	// the KeystoneCatalog gets deleted with its owner
+	if !instance.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	return r.reconcileNormal(ctx, instance)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KeystoneServiceTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&keystonev1.KeystoneServiceTemplate{}).
+		Owns(&keystonev1.KeystoneCatalog{}).
+		Complete(r)
+}
+
+func (r *KeystoneServiceTemplateReconciler) reconcileNormal(
+	ctx context.Context,
+	instance *keystonev1.KeystoneServiceTemplate,
+) (ctrl.Result, error) {
+	r.Log.V(1).Info("Reconciling Service template", "instance", instance.Name)
+
+	services, err := expandServiceTemplate(instance.Spec)
+	if err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceTemplateServicesReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			keystonev1.KeystoneServiceTemplateServicesReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	//
+	// the KeystoneCatalog named after the template reconciles the services
+	// and deletes the ones of removed instances
+	//
+	catalog := &keystonev1.KeystoneCatalog{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.Name,
+			Namespace: instance.Namespace,
+		},
+	}
+	_, err = controllerutil.CreateOrPatch(ctx, r.Client, catalog, func() error {
+		if !catalog.CreationTimestamp.IsZero() && !metav1.IsControlledBy(catalog, instance) {
+			return fmt.Errorf("KeystoneCatalog %s is not managed by KeystoneServiceTemplate %s", catalog.Name, instance.Name)
+		}
+		catalog.Spec.Services = services
+
+		return controllerutil.SetControllerReference(instance, catalog, r.Scheme)
+	})
+	if err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceTemplateServicesReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			keystonev1.KeystoneServiceTemplateServicesReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	instance.Status.ServiceNames = []string{}
+	for _, svc := range services {
+		instance.Status.ServiceNames = append(instance.Status.ServiceNames, svc.ServiceName)
+	}
+	instance.Status.ReadyCount = catalog.Status.ReadyCount
+	// the catalog status only counts the services of the current spec once
+	// it observed it
+	if !catalog.IsReady() || catalog.Status.ObservedGeneration != catalog.Generation {
+		// the template gets reconciled again when the owned catalog changes
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			keystonev1.KeystoneServiceTemplateServicesReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			keystonev1.KeystoneServiceTemplateServicesReadyWaitingMessage,
+			instance.Status.ReadyCount,
+			len(services)))
+		return ctrl.Result{}, nil
+	}
+	instance.Status.Conditions.MarkTrue(
+		keystonev1.KeystoneServiceTemplateServicesReadyCondition,
+		keystonev1.KeystoneServiceTemplateServicesReadyMessage)
+	instance.Status.ObservedGeneration = instance.Generation
+
+	r.Log.V(1).Info("Reconciled Service template successfully", "instance", instance.Name)
+	return ctrl.Result{}, nil
+}
+
+// expandServiceTemplate - returns the services of the instances of spec,
+// with the templated fields of the Template rendered with their values.
+// Instances expanding to the same service name are rejected.
+func expandServiceTemplate(
+	spec keystonev1.KeystoneServiceTemplateSpec,
+) ([]keystonev1.KeystoneCatalogService, error) {
+	services := []keystonev1.KeystoneCatalogService{}
+	expandedBy := map[string]int{}
+	for i, instance := range spec.Instances {
+		svc := *spec.Template.DeepCopy()
+		data := map[string]interface{}{"Values": instance.Values}
+
+		for _, field := range []*string{
+			&svc.ServiceType,
+			&svc.ServiceName,
+			&svc.ServiceDescription,
+			&svc.ServiceUser,
+			&svc.Secret,
+			&svc.PasswordSelector,
+		} {
+			rendered, err := renderTemplate(*field, data)
+			if err != nil {
+				return nil, fmt.Errorf("instance %d: %w", i, err)
+			}
+			*field = rendered
+		}
+		for endpointType, url := range svc.Endpoints {
+			rendered, err := renderTemplate(url, data)
+			if err != nil {
+				return nil, fmt.Errorf("instance %d, %s endpoint: %w", i, endpointType, err)
+			}
+			svc.Endpoints[endpointType] = rendered
+		}
+
+		if j, ok := expandedBy[svc.ServiceName]; ok {
+			return nil, fmt.Errorf("instances %d and %d expand to the same service name %s", j, i, svc.ServiceName)
+		}
+		expandedBy[svc.ServiceName] = i
+		services = append(services, svc)
+	}
+
+	return services, nil
+}
+
+// renderTemplate - renders the Go template text with data, a value missing
+// from data is an error
+func renderTemplate(text string, data interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err := t.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var cellTemplate = keystonev1.KeystoneCatalogService{
+	KeystoneServiceSpec: keystonev1.KeystoneServiceSpec{
+		ServiceType:      "compute-{{ .Values.cell }}",
+		ServiceName:      "nova-{{ .Values.cell }}",
+		Enabled:          true,
+		ServiceUser:      "nova-{{ .Values.cell }}",
+		Secret:           "osp-secret",
+		PasswordSelector: "NovaPassword",
+	},
+	Endpoints: map[string]string{
+		"public": "https://nova-{{ .Values.cell }}.example.com",
+	},
+}
+
+var _ = Describe("expandServiceTemplate", func() {
+	It("renders the template with the values of each instance", func() {
+		services, err := expandServiceTemplate(keystonev1.KeystoneServiceTemplateSpec{
+			Template: cellTemplate,
+			Instances: []keystonev1.KeystoneServiceTemplateInstance{
+				{Values: map[string]string{"cell": "cell1"}},
+				{Values: map[string]string{"cell": "cell2"}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(HaveLen(2))
+		Expect(services[1].ServiceType).To(Equal("compute-cell2"))
+		Expect(services[1].ServiceName).To(Equal("nova-cell2"))
+		Expect(services[1].ServiceUser).To(Equal("nova-cell2"))
+		Expect(services[1].Secret).To(Equal("osp-secret"))
+		Expect(services[1].Endpoints).To(Equal(map[string]string{"public": "https://nova-cell2.example.com"}))
+		// the template itself is left as it is
+		Expect(cellTemplate.Endpoints["public"]).To(Equal("https://nova-{{ .Values.cell }}.example.com"))
+	})
+
+	It("rejects a missing value", func() {
+		_, err := expandServiceTemplate(keystonev1.KeystoneServiceTemplateSpec{
+			Template:  cellTemplate,
+			Instances: []keystonev1.KeystoneServiceTemplateInstance{{Values: map[string]string{"region": "regionOne"}}},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects instances expanding to the same service", func() {
+		_, err := expandServiceTemplate(keystonev1.KeystoneServiceTemplateSpec{
+			Template: cellTemplate,
+			Instances: []keystonev1.KeystoneServiceTemplateInstance{
+				{Values: map[string]string{"cell": "cell1"}},
+				{Values: map[string]string{"cell": "cell1"}},
+			},
+		})
+		Expect(err).To(MatchError(ContainSubstring("same service name nova-cell1")))
+	})
+})
+
+var _ = Describe("KeystoneServiceTemplate controller", func() {
+	var namespace string
+
+	BeforeEach(func() {
+		skipWithoutEnvtest()
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+			},
+		}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "osp-secret",
+				Namespace: namespace,
+			},
+			StringData: map[string]string{
+				"NovaPassword": "12345678",
+			},
+		})).To(Succeed())
+
+		createReadyKeystoneAPI(namespace)
+	})
+
+	It("registers the services expanded from the template", func() {
+		serviceTemplate := &keystonev1.KeystoneServiceTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cells",
+				Namespace: namespace,
+			},
+			Spec: keystonev1.KeystoneServiceTemplateSpec{
+				Template: cellTemplate,
+				Instances: []keystonev1.KeystoneServiceTemplateInstance{
+					{Values: map[string]string{"cell": "cell1"}},
+					{Values: map[string]string{"cell": "cell2"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, serviceTemplate)).To(Succeed())
+
+		key := types.NamespacedName{Name: "cells", Namespace: namespace}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, key, serviceTemplate); err != nil {
+				return false
+			}
+			return serviceTemplate.IsReady()
+		}, timeout, interval).Should(BeTrue())
+		Expect(serviceTemplate.Status.ReadyCount).To(Equal(2))
+		Expect(serviceTemplate.Status.ServiceNames).To(Equal([]string{"nova-cell1", "nova-cell2"}))
+
+		catalog := &keystonev1.KeystoneCatalog{}
+		Expect(k8sClient.Get(ctx, key, catalog)).To(Succeed())
+		Expect(metav1.IsControlledBy(catalog, serviceTemplate)).To(BeTrue())
+		Expect(identityClient.Endpoints(catalog.Status.ServiceIDs["nova-cell2"])).To(Equal(
+			map[string]string{"public": "https://nova-cell2.example.com"}))
+	})
+})
@@ -0,0 +1,118 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+)
+
+// reconcile lock scopes
+const (
+	// LockScopeService - a KeystoneService and its KeystoneEndpoints get
+	// reconciled one at a time
+	LockScopeService = "service"
+	// LockScopeCredential - all reconciles using the admin credentials of
+	// the same KeystoneAPI get serialized
+	LockScopeCredential = "credential"
+	// LockScopeNone - no locking
+	LockScopeNone = "none"
+)
+
+// ReconcileLock - serializes the keystone requests of reconciles of the same
+// target, reconciles of unrelated targets stay parallel. Shared by the
+// KeystoneService, KeystoneEndpoint and KeystoneRole reconcilers, a nil
+// ReconcileLock does not lock.
+type ReconcileLock struct {
+	scope string
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// keyedLock - the lock of a key, removed from the ReconcileLock once nobody
+// holds or waits for it
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewReconcileLock - returns a ReconcileLock for scope, one of the LockScope
+// constants
+func NewReconcileLock(scope string) (*ReconcileLock, error) {
+	switch scope {
+	case LockScopeService, LockScopeCredential, LockScopeNone:
+	default:
+		return nil, fmt.Errorf("unknown reconcile lock scope %s", scope)
+	}
+
+	return &ReconcileLock{
+		scope: scope,
+		locks: map[string]*keyedLock{},
+	}, nil
+}
+
+// Lock - locks the target of a reconcile and returns the func to unlock it.
+// keystoneAPI is the KeystoneAPI the admin credentials are read from,
+// service the namespace/name of the KeystoneService the reconcile belongs to,
+// empty if it does not belong to one.
+func (l *ReconcileLock) Lock(keystoneAPI *keystonev1.KeystoneAPI, service string) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	var key string
+	switch l.scope {
+	case LockScopeService:
+		if service == "" {
+			return func() {}
+		}
+		key = service
+	case LockScopeCredential:
+		key = keystoneAPI.Namespace + "/" + keystoneAPI.Name
+	default:
+		return func() {}
+	}
+
+	return l.lock(key)
+}
+
+// lock - locks key and returns the func to unlock it
+func (l *ReconcileLock) lock(key string) func() {
+	l.mu.Lock()
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyedLock{}
+		l.locks[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	kl.Lock()
+
+	return func() {
+		kl.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		kl.refs--
+		if kl.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ReconcileLock", func() {
+	keystoneAPI := &keystonev1.KeystoneAPI{
+		ObjectMeta: metav1.ObjectMeta{Name: "keystone", Namespace: "openstack"},
+	}
+
+	// locked - returns true if locking service blocks
+	locked := func(l *ReconcileLock, service string) bool {
+		acquired := make(chan func())
+		go func() {
+			acquired <- l.Lock(keystoneAPI, service)
+		}()
+		select {
+		case unlock := <-acquired:
+			unlock()
+			return false
+		case <-time.After(100 * time.Millisecond):
+			// release it again once the test unlocks
+			go func() { (<-acquired)() }()
+			return true
+		}
+	}
+
+	It("serializes the same service and keeps other services parallel", func() {
+		l, err := NewReconcileLock(LockScopeService)
+		Expect(err).NotTo(HaveOccurred())
+
+		unlock := l.Lock(keystoneAPI, "openstack/placement")
+		Expect(locked(l, "openstack/placement")).To(BeTrue())
+		Expect(locked(l, "openstack/nova")).To(BeFalse())
+		Expect(locked(l, "")).To(BeFalse())
+		unlock()
+
+		Eventually(func() int {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.locks)
+		}).Should(Equal(0))
+	})
+
+	It("serializes all reconciles of a KeystoneAPI with the credential scope", func() {
+		l, err := NewReconcileLock(LockScopeCredential)
+		Expect(err).NotTo(HaveOccurred())
+
+		unlock := l.Lock(keystoneAPI, "openstack/placement")
+		Expect(locked(l, "openstack/nova")).To(BeTrue())
+		Expect(locked(l, "")).To(BeTrue())
+		unlock()
+	})
+
+	It("does not lock without a ReconcileLock or with the none scope", func() {
+		var nilLock *ReconcileLock
+		unlock := nilLock.Lock(keystoneAPI, "openstack/placement")
+		Expect(locked(nilLock, "openstack/placement")).To(BeFalse())
+		unlock()
+
+		l, err := NewReconcileLock(LockScopeNone)
+		Expect(err).NotTo(HaveOccurred())
+		unlock = l.Lock(keystoneAPI, "openstack/placement")
+		Expect(locked(l, "openstack/placement")).To(BeFalse())
+		unlock()
+
+		_, err = NewReconcileLock("global")
+		Expect(err).To(HaveOccurred())
+	})
+})
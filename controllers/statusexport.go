@@ -0,0 +1,129 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ServiceStatusSummary - the reconcile state of a KeystoneService exported
+// to the status ConfigMap, for tooling without access to the CRDs
+type ServiceStatusSummary struct {
+	ServiceType          string   `json:"serviceType"`
+	ServiceName          string   `json:"serviceName"`
+	ServiceID            string   `json:"serviceID,omitempty"`
+	AdditionalServiceIDs []string `json:"additionalServiceIDs,omitempty"`
+	// EndpointIDs - the IDs of the endpoints in keystone by service ID
+	EndpointIDs map[string][]string      `json:"endpointIDs,omitempty"`
+	Ready       bool                     `json:"ready"`
+	Conditions  []ConditionStatusSummary `json:"conditions,omitempty"`
+}
+
+// ConditionStatusSummary - a condition of a ServiceStatusSummary
+type ConditionStatusSummary struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// getServiceStatusSummary - returns the summarized reconcile state of instance
+func getServiceStatusSummary(instance *keystonev1.KeystoneService) ServiceStatusSummary {
+	summary := ServiceStatusSummary{
+		ServiceType:          instance.Spec.ServiceType,
+		ServiceName:          instance.Spec.ServiceName,
+		ServiceID:            instance.Status.ServiceID,
+		AdditionalServiceIDs: instance.Status.AdditionalServiceIDs,
+		Ready:                instance.IsReady(),
+	}
+	for _, service := range instance.Status.ManagedCatalog {
+		if summary.EndpointIDs == nil {
+			summary.EndpointIDs = map[string][]string{}
+		}
+		endpointIDs := []string{}
+		for _, e := range service.Endpoints {
+			endpointIDs = append(endpointIDs, e.ID)
+		}
+		summary.EndpointIDs[service.ID] = endpointIDs
+	}
+	for _, c := range instance.Status.Conditions {
+		summary.Conditions = append(summary.Conditions, ConditionStatusSummary{
+			Type:    string(c.Type),
+			Status:  string(c.Status),
+			Reason:  string(c.Reason),
+			Message: c.Message,
+		})
+	}
+
+	return summary
+}
+
+// exportServiceStatus - writes the summarized reconcile state of the
+// KeystoneServices of namespace into the ConfigMap configMapName, a key per
+// KeystoneService with its ServiceStatusSummary as JSON. current is the just
+// reconciled instance, used instead of the listed one as the cache may not
+// have its status yet, nil if it got deleted. The ConfigMap is only updated
+// if the state changed.
+func exportServiceStatus(
+	ctx context.Context,
+	c client.Client,
+	configMapName string,
+	namespace string,
+	current *keystonev1.KeystoneService,
+) error {
+	services := &keystonev1.KeystoneServiceList{}
+	err := c.List(ctx, services, client.InNamespace(namespace))
+	if err != nil {
+		return err
+	}
+
+	instances := []*keystonev1.KeystoneService{}
+	for i := range services.Items {
+		if current == nil || services.Items[i].Name != current.Name {
+			instances = append(instances, &services.Items[i])
+		}
+	}
+	if current != nil {
+		instances = append(instances, current)
+	}
+
+	data := map[string]string{}
+	for _, instance := range instances {
+		summary, err := json.Marshal(getServiceStatusSummary(instance))
+		if err != nil {
+			return err
+		}
+		data[instance.Name] = string(summary)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: namespace},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, c, cm, func() error {
+		cm.Data = data
+		return nil
+	})
+
+	return err
+}
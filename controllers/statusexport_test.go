@@ -0,0 +1,79 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("exportServiceStatus", func() {
+	It("writes the state of the KeystoneServices of the namespace", func() {
+		scheme := runtime.NewScheme()
+		Expect(keystonev1.AddToScheme(scheme)).To(Succeed())
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+		// the cache has no status of placement yet
+		placement := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec:       keystonev1.KeystoneServiceSpec{ServiceType: "placement", ServiceName: "placement"},
+		}
+		nova := &keystonev1.KeystoneService{
+			ObjectMeta: metav1.ObjectMeta{Name: "nova", Namespace: "openstack"},
+			Spec:       keystonev1.KeystoneServiceSpec{ServiceType: "compute", ServiceName: "nova"},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(placement, nova).Build()
+
+		current := placement.DeepCopy()
+		current.Status.ServiceID = "1"
+		current.Status.ManagedCatalog = []keystonev1.ManagedCatalogService{{
+			ID:        "1",
+			Endpoints: []keystonev1.ManagedCatalogEndpoint{{ID: "e1"}, {ID: "e2"}},
+		}}
+		current.Status.Conditions = condition.Conditions{}
+		current.Status.Conditions.MarkTrue(keystonev1.KeystoneServiceOSServiceReadyCondition, "ready")
+		Expect(exportServiceStatus(ctx, c, "keystone-status", "openstack", current)).To(Succeed())
+
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "keystone-status", Namespace: "openstack"}, cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKey("nova"))
+		summary := ServiceStatusSummary{}
+		Expect(json.Unmarshal([]byte(cm.Data["placement"]), &summary)).To(Succeed())
+		Expect(summary.ServiceID).To(Equal("1"))
+		Expect(summary.EndpointIDs).To(Equal(map[string][]string{"1": {"e1", "e2"}}))
+		Expect(summary.Conditions).To(HaveLen(1))
+		Expect(summary.Conditions[0].Type).To(Equal(string(keystonev1.KeystoneServiceOSServiceReadyCondition)))
+		Expect(summary.Conditions[0].Status).To(Equal(string(corev1.ConditionTrue)))
+		Expect(summary.Conditions[0].Message).To(Equal("ready"))
+
+		By("deleting a KeystoneService")
+		Expect(c.Delete(ctx, nova)).To(Succeed())
+		Expect(exportServiceStatus(ctx, c, "keystone-status", "openstack", nil)).To(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Name: "keystone-status", Namespace: "openstack"}, cm)).To(Succeed())
+		Expect(cm.Data).NotTo(HaveKey("nova"))
+		Expect(cm.Data).To(HaveKey("placement"))
+	})
+})
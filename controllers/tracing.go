@@ -0,0 +1,63 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer - creates the spans of the reconcile path. It uses the global
+// TracerProvider, the spans are only exported if an OTLP endpoint is
+// configured, otherwise they are no-ops.
+var tracer = otel.Tracer("github.com/openstack-k8s-operators/keystone-operator/controllers")
+
+// span attributes
+const (
+	serviceTypeAttr       = attribute.Key("keystone.service.type")
+	serviceNameAttr       = attribute.Key("keystone.service.name")
+	regionAttr            = attribute.Key("keystone.region")
+	endpointInterfaceAttr = attribute.Key("keystone.endpoint.interface")
+	outcomeAttr           = attribute.Key("keystone.outcome")
+)
+
+// span outcomes
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+// startSpan - starts the span name as child of the span in ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan - records the outcome of the traced step and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(outcomeAttr.String(outcomeError))
+	} else {
+		span.SetAttributes(outcomeAttr.String(outcomeSuccess))
+	}
+	span.End()
+}
@@ -17,27 +17,47 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/go-logr/logr"
 	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/yaml"
+
 	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/helper"
 	mariadbv1 "github.com/openstack-k8s-operators/mariadb-operator/api/v1beta1"
 
 	"github.com/openstack-k8s-operators/keystone-operator/controllers"
+	"github.com/openstack-k8s-operators/keystone-operator/pkg/keystone"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,21 +75,159 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var requireHTTPSEndpoints string
+	var logLevel string
+	var logFormat string
+	var gracefulShutdownTimeout time.Duration
+	var resyncPeriod time.Duration
+	var flappingThreshold int
+	var flappingWindow time.Duration
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var credentialProvider string
+	var reconcileLockScope string
+	var keystoneAPIAuthCheck string
+	var vaultCredentials keystone.VaultCredentialProvider
+	var transportOptions keystone.TransportOptions
+	var maxRequests int
+	var statusConfigMap string
+	var clientOptions keystone.ClientOptions
+	var authBreakerThreshold int
+	var authBreakerOpenDuration time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	opts := zap.Options{
-		Development: true,
-	}
-	opts.BindFlags(flag.CommandLine)
+	flag.StringVar(&clientOptions.DefaultDomain, "default-domain", keystone.DefaultDomain,
+		"The keystone domain used for authentication and resources when a CR does not specify one.")
+	flag.StringVar(&clientOptions.Microversion, "identity-microversion", "",
+		"The identity microversion requested from keystone with the OpenStack-API-Version header, e.g. 3.14.")
+	flag.StringVar(&clientOptions.ServiceAccountTokenFile, "service-account-token-file", keystone.ServiceAccountTokenFile,
+		"The service account token exchanged for a keystone token with the KeystoneAPI authMode serviceAccount.")
+	flag.StringVar(&keystonev1.BootstrapHashAnnotation, "bootstrap-hash-annotation", keystonev1.BootstrapHashAnnotation,
+		"The KeystoneAPI annotation signaling the completed bootstrap if the bootstrap hash is not in its status, empty only checks the status.")
+	flag.BoolVar(&keystonev1.SkipBootstrapGate, "skip-bootstrap-gate", keystonev1.SkipBootstrapGate,
+		"Do not wait for the bootstrap of a ready KeystoneAPI before reconciling the CRs using it, for environments where the bootstrap is never signaled.")
+	flag.StringVar(&keystonev1.ReauthAnnotation, "reauth-annotation", keystonev1.ReauthAnnotation,
+		"The annotation which, set to true on a KeystoneService or KeystoneEndpoint, forces its next reconcile to authenticate against keystone, empty disables it.")
+	flag.StringVar(&keystonev1.Finalizer, "finalizer-name", keystonev1.Finalizer,
+		"The finalizer registered on the KeystoneServices, KeystoneEndpoints and KeystoneRoles to clean up keystone on delete, empty uses the default of each controller.")
+	flag.BoolVar(&keystonev1.DisableFinalizer, "disable-finalizer", keystonev1.DisableFinalizer,
+		"Do not register the finalizer, deleting a KeystoneService, KeystoneEndpoint or KeystoneRole leaves its resources in keystone.")
+	flag.StringVar(&keystonev1.SkipFinalizerAnnotation, "skip-finalizer-annotation", keystonev1.SkipFinalizerAnnotation,
+		"The annotation which, set to true on a KeystoneService, KeystoneEndpoint or KeystoneRole, disables the finalizer for it, empty disables it.")
+	flag.StringVar(&requireHTTPSEndpoints, "require-https-endpoints", "",
+		"Comma separated list of endpoint types, e.g. admin,public, the KeystoneEndpoint webhook requires an https URL for.")
+	flag.StringVar(&logLevel, "log-level", "info",
+		"The log verbosity, one of debug, info or error, or a number where a higher number logs more verbose messages.")
+	flag.StringVar(&logFormat, "log-format", "console",
+		"The log encoding, one of console or json.")
+	flag.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 30*time.Second,
+		"How long the manager waits on shutdown for in-flight reconciles to finish.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 0,
+		"How often reconciled KeystoneServices and KeystoneEndpoints are re-verified against keystone to correct drift, 0 disables it.")
+	flag.IntVar(&flappingThreshold, "endpoint-flapping-threshold", 5,
+		"The number of updates of an endpoint within the endpoint-flapping-window above which it is reported as flapping, 0 disables it.")
+	flag.DurationVar(&flappingWindow, "endpoint-flapping-window", 10*time.Minute,
+		"The window the updates of an endpoint are counted in to detect flapping.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The host:port of the OTLP gRPC collector the reconcile traces get exported to, empty disables tracing.")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false,
+		"Export the reconcile traces to the OTLP collector without TLS.")
+	flag.StringVar(&credentialProvider, "credential-provider", "secret",
+		"Where the admin and service user passwords are read from, secret for the Secrets of the CRs or vault.")
+	flag.StringVar(&vaultCredentials.Address, "vault-address", "",
+		"The address of Vault with the credential-provider vault.")
+	flag.StringVar(&vaultCredentials.Path, "vault-path", "secret/data/keystone-operator",
+		"The KV version 2 API path below which the credentials are stored as <namespace>/<secret name> with the credential-provider vault.")
+	flag.StringVar(&vaultCredentials.TokenFile, "vault-token-file", "/var/run/secrets/vault/token",
+		"The file with the Vault token with the credential-provider vault.")
+	flag.DurationVar(&vaultCredentials.Timeout, "vault-timeout", keystone.VaultTimeout,
+		"The timeout of a request to Vault with the credential-provider vault.")
+	flag.StringVar(&reconcileLockScope, "reconcile-lock-scope", controllers.LockScopeService,
+		"Which reconciles get serialized, service for a KeystoneService and its KeystoneEndpoints, credential for all using the same KeystoneAPI, or none.")
+	flag.StringVar(&keystoneAPIAuthCheck, "keystoneapi-auth-check", "",
+		"Whether the KeystoneAPI webhook authenticates with the admin credentials of a created or changed KeystoneAPI with authURLs, reject or warn, empty disables it.")
+	flag.IntVar(&transportOptions.MaxIdleConns, "http-max-idle-conns", 100,
+		"The idle connections to keystone kept open across all hosts, 0 means no limit.")
+	flag.IntVar(&transportOptions.MaxIdleConnsPerHost, "http-max-idle-conns-per-host", 10,
+		"The idle connections kept open per keystone host, reused by the next reconciles.")
+	flag.IntVar(&transportOptions.MaxConnsPerHost, "http-max-conns-per-host", 0,
+		"The connections per keystone host, further requests wait for a free one, 0 means no limit.")
+	flag.DurationVar(&transportOptions.IdleConnTimeout, "http-idle-conn-timeout", 90*time.Second,
+		"The time after which an idle connection to keystone gets closed.")
+	flag.IntVar(&maxRequests, "http-max-requests", 0,
+		"The keystone requests in flight at a time across all reconciles, further requests wait for a free slot, 0 means no limit.")
+	flag.DurationVar(&clientOptions.ClockSkewThreshold, "clock-skew-threshold", keystone.DefaultClockSkewThreshold,
+		"The clock skew to keystone above which the KeystoneClockInSync condition turns false, 0 disables the check.")
+	flag.StringVar(&statusConfigMap, "status-configmap", "",
+		"The name of a ConfigMap in each namespace with KeystoneServices to export their service IDs, endpoint IDs and conditions to, empty disables the export.")
+	flag.IntVar(&authBreakerThreshold, "auth-breaker-threshold", 5,
+		"The consecutive failures to reach the keystone of a KeystoneAPI, connection failures or 5xx responses, after which the reconcilers stop authenticating against it, 0 disables it.")
+	flag.DurationVar(&authBreakerOpenDuration, "auth-breaker-open-duration", time.Minute,
+		"How long the reconcilers stop authenticating against an unavailable keystone before a single reconcile probes it again.")
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var encoder zap.Opts
+	switch logFormat {
+	case "console":
+		encoder = zap.ConsoleEncoder()
+	case "json":
+		encoder = zap.JSONEncoder()
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --log-format %q, must be console or json\n", logFormat)
+		os.Exit(1)
+	}
+
+	// the zap flags of controller-runtime are not bound, --log-level and
+	// --log-format configure the logger. The development mode only adds the
+	// stack traces of warnings to the human readable console format.
+	ctrl.SetLogger(zap.New(zap.UseDevMode(logFormat == "console"), zap.Level(level), encoder))
+
+	clientOptions.Transports = keystone.NewTransports(transportOptions, maxRequests)
+
+	switch credentialProvider {
+	case "secret":
+	case "vault":
+		vaultCredentials.Transport = clientOptions.Transports.Default()
+		clientOptions.Credentials = vaultCredentials
+	default:
+		setupLog.Error(fmt.Errorf("unknown credential provider %s", credentialProvider), "invalid flag")
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+
+	if otlpEndpoint != "" {
+		shutdownTracing, err := setupTracing(ctx, otlpEndpoint, otlpInsecure)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(shutdownCtx); err != nil {
+				setupLog.Error(err, "unable to flush the traces")
+			}
+		}()
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -78,6 +236,8 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "6012128b.openstack.org",
+		// in-flight reconciles finish their keystone changes on shutdown
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -95,35 +255,137 @@ func main() {
 		os.Exit(1)
 	}
 
+	// shared by the service catalog reconcilers to not overload a recovering keystone
+	authBreaker := keystone.NewCircuitBreaker(authBreakerThreshold, authBreakerOpenDuration)
+	// shared by the service catalog reconcilers to serialize the reconciles of the same target
+	reconcileLock, err := controllers.NewReconcileLock(reconcileLockScope)
+	if err != nil {
+		setupLog.Error(err, "invalid flag")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.KeystoneAPIReconciler{
-		Client:  mgr.GetClient(),
-		Scheme:  mgr.GetScheme(),
-		Kclient: kclient,
-		Log:     ctrl.Log.WithName("controllers").WithName("KeystoneAPI"),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Kclient:       kclient,
+		Log:           ctrl.Log.WithName("controllers").WithName("KeystoneAPI"),
+		ClientOptions: clientOptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KeystoneAPI")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.KeystoneServiceReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Kclient:         kclient,
+		Log:             ctrl.Log.WithName("controllers").WithName("KeystoneService"),
+		AuthBreaker:     authBreaker,
+		ReconcileLock:   reconcileLock,
+		ResyncPeriod:    resyncPeriod,
+		Recorder:        mgr.GetEventRecorderFor("keystoneservice-controller"),
+		StatusConfigMap: statusConfigMap,
+		ClientOptions:   clientOptions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneService")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.KeystoneEndpointReconciler{
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		Kclient:           kclient,
+		Log:               ctrl.Log.WithName("controllers").WithName("KeystoneEndpoint"),
+		AuthBreaker:       authBreaker,
+		ReconcileLock:     reconcileLock,
+		ResyncPeriod:      resyncPeriod,
+		FlappingThreshold: flappingThreshold,
+		FlappingWindow:    flappingWindow,
+		Recorder:          mgr.GetEventRecorderFor("keystoneendpoint-controller"),
+		ClientOptions:     clientOptions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneEndpoint")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.KeystoneCatalogReconciler{
 		Client:  mgr.GetClient(),
 		Scheme:  mgr.GetScheme(),
 		Kclient: kclient,
-		Log:     ctrl.Log.WithName("controllers").WithName("KeystoneService"),
+		Log:     ctrl.Log.WithName("controllers").WithName("KeystoneCatalog"),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "KeystoneService")
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneCatalog")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.KeystoneEndpointReconciler{
+	if err = (&controllers.KeystoneRoleReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Kclient:       kclient,
+		Log:           ctrl.Log.WithName("controllers").WithName("KeystoneRole"),
+		AuthBreaker:   authBreaker,
+		ReconcileLock: reconcileLock,
+		ClientOptions: clientOptions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneRole")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.KeystoneServiceTemplateReconciler{
 		Client:  mgr.GetClient(),
 		Scheme:  mgr.GetScheme(),
 		Kclient: kclient,
-		Log:     ctrl.Log.WithName("controllers").WithName("KeystoneEndpoint"),
+		Log:     ctrl.Log.WithName("controllers").WithName("KeystoneServiceTemplate"),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "KeystoneEndpoint")
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneServiceTemplate")
 		os.Exit(1)
 	}
+
+	// webhooks require the serving certificates, see config/default [WEBHOOK]
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		webhookOpts := keystonev1.KeystoneEndpointWebhookOptions{}
+		if requireHTTPSEndpoints != "" {
+			webhookOpts.RequireHTTPS = strings.Split(requireHTTPSEndpoints, ",")
+		}
+		keystonev1.SetupKeystoneEndpointWebhookOptions(webhookOpts)
+
+		switch keystoneAPIAuthCheck {
+		case "", keystonev1.AuthCheckReject, keystonev1.AuthCheckWarn:
+		default:
+			setupLog.Error(fmt.Errorf("unknown KeystoneAPI auth check %s", keystoneAPIAuthCheck), "invalid flag")
+			os.Exit(1)
+		}
+		keystonev1.SetupKeystoneAPIWebhookOptions(keystonev1.KeystoneAPIWebhookOptions{
+			AuthCheck: keystoneAPIAuthCheck,
+			VerifyAuth: func(ctx context.Context, instance *keystonev1.KeystoneAPI) error {
+				h, err := helper.NewHelper(instance, mgr.GetClient(), kclient, mgr.GetScheme(), setupLog)
+				if err != nil {
+					return err
+				}
+				_, ctrlResult, err := keystone.GetAdminClient(ctx, h, instance, clientOptions)
+				if err != nil {
+					return err
+				}
+				if (ctrlResult != ctrl.Result{}) {
+					return fmt.Errorf("credentials not found")
+				}
+				return nil
+			},
+		})
+
+		if err = (&keystonev1.KeystoneAPI{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KeystoneAPI")
+			os.Exit(1)
+		}
+		if err = (&keystonev1.KeystoneEndpoint{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KeystoneEndpoint")
+			os.Exit(1)
+		}
+		if err = (&keystonev1.KeystoneService{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KeystoneService")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -136,8 +398,255 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// setupTracing - exports the spans of the reconcilers to the OTLP collector
+// at endpoint. The returned func flushes the pending spans on shutdown.
+func setupTracing(ctx context.Context, endpoint string, insecure bool) (func(context.Context) error, error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "keystone-operator"),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// parseLogLevel - maps the --log-level flag to a zap level. The reconcilers
+// log the individual reconcile steps with V(1), debug or 1 shows them.
+func parseLogLevel(level string) (zapcore.Level, error) {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	v, err := strconv.Atoi(level)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid --log-level %q, must be debug, info, error or a non negative number", level)
+	}
+
+	// logr V(n) is logged at zap level -n
+	return zapcore.Level(-v), nil
+}
+
+// runImport - the import subcommand, emits a KeystoneService and
+// KeystoneEndpoint for every service registered in the keystone of a
+// KeystoneAPI, to adopt the catalog of an existing cloud
+func runImport(args []string) int {
+	var namespace string
+	var keystoneAPIName string
+	var create bool
+	var clientOptions keystone.ClientOptions
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&namespace, "namespace", "openstack", "The namespace of the KeystoneAPI and the imported resources.")
+	fs.StringVar(&keystoneAPIName, "keystone-api", "keystone", "The name of the KeystoneAPI to import the services of.")
+	fs.BoolVar(&create, "create", false,
+		"Create the resources with their status pre-populated instead of writing the manifests to stdout. "+
+			"The service user settings of the KeystoneServices have to be set afterwards.")
+	fs.StringVar(&clientOptions.DefaultDomain, "default-domain", keystone.DefaultDomain,
+		"The keystone domain used for authentication when the KeystoneAPI does not specify one.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("import")
+	ctx := context.Background()
+
+	c, ksClient, ok := newCommandClients(ctx, log, namespace, keystoneAPIName, clientOptions)
+	if !ok {
+		return 1
+	}
+
+	ksServices, ksEndpoints, err := keystone.ImportCatalog(log, ksClient, namespace)
+	if err != nil {
+		log.Error(err, "unable to import the catalog")
+		return 1
+	}
+
+	objs := []client.Object{}
+	for i := range ksServices {
+		objs = append(objs, &ksServices[i])
+	}
+	for i := range ksEndpoints {
+		objs = append(objs, &ksEndpoints[i])
+	}
+
+	for _, obj := range objs {
+		if !create {
+			out, err := yaml.Marshal(obj)
+			if err != nil {
+				log.Error(err, "unable to marshal manifest")
+				return 1
+			}
+			fmt.Printf("---\n%s", out)
+			continue
+		}
+
+		// the status gets dropped on create
+		status := obj.DeepCopyObject().(client.Object)
+		if err := c.Create(ctx, obj); err != nil {
+			log.Error(err, "unable to create", "name", obj.GetName())
+			return 1
+		}
+		status.SetResourceVersion(obj.GetResourceVersion())
+		if err := c.Status().Update(ctx, status); err != nil {
+			log.Error(err, "unable to update status", "name", obj.GetName())
+			return 1
+		}
+		log.Info("created", "name", obj.GetName())
+	}
+
+	return 0
+}
+
+// newCommandClients - returns the kubernetes client and the admin keystone
+// client with opts of the KeystoneAPI keystoneAPIName in namespace for the
+// subcommands, false if they could not be created
+func newCommandClients(
+	ctx context.Context,
+	log logr.Logger,
+	namespace string,
+	keystoneAPIName string,
+	opts keystone.ClientOptions,
+) (client.Client, *keystone.Client, bool) {
+	cfg := ctrl.GetConfigOrDie()
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		log.Error(err, "unable to create client")
+		return nil, nil, false
+	}
+	kclient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		log.Error(err, "unable to create kubernetes client")
+		return nil, nil, false
+	}
+
+	keystoneAPI := &keystonev1.KeystoneAPI{}
+	err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: keystoneAPIName}, keystoneAPI)
+	if err != nil {
+		log.Error(err, "unable to get KeystoneAPI")
+		return nil, nil, false
+	}
+	h, err := helper.NewHelper(keystoneAPI, c, kclient, scheme, log)
+	if err != nil {
+		log.Error(err, "unable to create helper")
+		return nil, nil, false
+	}
+	ksClient, ctrlResult, err := keystone.GetAdminClient(ctx, h, keystoneAPI, opts)
+	if err != nil {
+		log.Error(err, "unable to create admin client")
+		return nil, nil, false
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		log.Info("KeystoneAPI is not ready to be used, retry later")
+		return nil, nil, false
+	}
+
+	return c, ksClient, true
+}
+
+// runDiff - the diff subcommand, reports the changes the operator would make
+// in keystone for all KeystoneServices and KeystoneEndpoints registering with
+// a KeystoneAPI, without changing keystone
+func runDiff(args []string) int {
+	var namespace string
+	var keystoneAPIName string
+	var configMapName string
+	var clientOptions keystone.ClientOptions
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.StringVar(&namespace, "namespace", "openstack", "The namespace of the KeystoneAPI.")
+	fs.StringVar(&keystoneAPIName, "keystone-api", "keystone", "The name of the KeystoneAPI to diff the catalog of.")
+	fs.StringVar(&configMapName, "configmap", "",
+		"The name of a ConfigMap in the namespace of the KeystoneAPI to write the report to, in addition to stdout.")
+	fs.StringVar(&clientOptions.DefaultDomain, "default-domain", keystone.DefaultDomain,
+		"The keystone domain used for authentication when the KeystoneAPI does not specify one.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("diff")
+	ctx := context.Background()
+
+	c, ksClient, ok := newCommandClients(ctx, log, namespace, keystoneAPIName, clientOptions)
+	if !ok {
+		return 1
+	}
+
+	// the KeystoneServices and KeystoneEndpoints of all namespaces can
+	// register with the KeystoneAPI
+	serviceList := &keystonev1.KeystoneServiceList{}
+	if err := c.List(ctx, serviceList); err != nil {
+		log.Error(err, "unable to list KeystoneServices")
+		return 1
+	}
+	ksServices := []keystonev1.KeystoneService{}
+	for _, s := range serviceList.Items {
+		if s.GetKeystoneAPINamespace() == namespace {
+			ksServices = append(ksServices, s)
+		}
+	}
+	endpointList := &keystonev1.KeystoneEndpointList{}
+	if err := c.List(ctx, endpointList); err != nil {
+		log.Error(err, "unable to list KeystoneEndpoints")
+		return 1
+	}
+	ksEndpoints := []keystonev1.KeystoneEndpoint{}
+	for _, e := range endpointList.Items {
+		if e.GetKeystoneAPINamespace() == namespace {
+			ksEndpoints = append(ksEndpoints, e)
+		}
+	}
+
+	changes, err := keystone.DiffCatalog(log, ksClient, ksServices, ksEndpoints)
+	if err != nil {
+		log.Error(err, "unable to diff the catalog")
+		return 1
+	}
+	report := ""
+	for _, change := range changes {
+		report += change.String() + "\n"
+	}
+	fmt.Print(report)
+	log.Info("Diffed the catalog", "services", len(ksServices), "endpoints", len(ksEndpoints), "changes", len(changes))
+
+	if configMapName == "" {
+		return 0
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: namespace},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, c, cm, func() error {
+		cm.Data = map[string]string{"report": report}
+		return nil
+	})
+	if err != nil {
+		log.Error(err, "unable to write the report", "configmap", configMapName)
+		return 1
+	}
+
+	return 0
+}
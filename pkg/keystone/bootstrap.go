@@ -53,7 +53,7 @@ func BootstrapJob(
 	envVars["OS_BOOTSTRAP_PROJECT_NAME"] = env.SetValue(instance.Spec.AdminProject)
 	envVars["OS_BOOTSTRAP_ROLE_NAME"] = env.SetValue(instance.Spec.AdminRole)
 	envVars["OS_BOOTSTRAP_SERVICE_NAME"] = env.SetValue(ServiceName)
-	envVars["OS_BOOTSTRAP_REGION_ID"] = env.SetValue(instance.Spec.Region)
+	envVars["OS_BOOTSTRAP_REGION_ID"] = env.SetValue(instance.GetRegionID())
 
 	if _, ok := endpoints["admin"]; ok {
 		envVars["OS_BOOTSTRAP_ADMIN_URL"] = env.SetValue(endpoints["admin"])
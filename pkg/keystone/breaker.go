@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreaker - shared between the reconcilers to stop authenticating
+// against an unavailable keystone after consecutive failures. The failures
+// are counted per key, e.g. per KeystoneAPI, so an unavailable keystone does
+// not block the reconciles using another one. Once open, a single probe is let
+// through every openDuration, the breaker closes again when it succeeds. A
+// nil CircuitBreaker never opens.
+type CircuitBreaker struct {
+	mu           sync.Mutex
+	threshold    int
+	openDuration time.Duration
+	states       map[string]*breakerState
+	now          func() time.Time
+}
+
+// breakerState - the failures of a key of the CircuitBreaker
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// NewCircuitBreaker - returns a CircuitBreaker which opens for openDuration
+// after threshold consecutive failures of a key. A threshold of 0 returns nil,
+// a CircuitBreaker which never opens.
+func NewCircuitBreaker(threshold int, openDuration time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &CircuitBreaker{
+		threshold:    threshold,
+		openDuration: openDuration,
+		states:       map[string]*breakerState{},
+		now:          time.Now,
+	}
+}
+
+// Allow - returns true if keystone may be contacted for key. If not, the
+// duration until the next probe is returned.
+func (b *CircuitBreaker) Allow(key string) (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[key]
+	if !ok || state.failures < b.threshold {
+		return true, 0
+	}
+
+	// open, let a single probe through once openDuration passed
+	now := b.now()
+	if now.Before(state.openUntil) {
+		return false, state.openUntil.Sub(now)
+	}
+	state.openUntil = now.Add(b.openDuration)
+
+	return true, 0
+}
+
+// Success - records a successful authentication for key and closes its
+// breaker
+func (b *CircuitBreaker) Success(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.states, key)
+}
+
+// Failure - records a failed authentication for key, opens its breaker when
+// the threshold is reached
+func (b *CircuitBreaker) Failure(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[key]
+	if !ok {
+		state = &breakerState{}
+		b.states[key] = state
+	}
+	state.failures++
+	if state.failures == b.threshold {
+		state.openUntil = b.now().Add(b.openDuration)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"testing"
+	"time"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	// closed until the threshold is reached
+	b.Failure("openstack/keystone")
+	allowed, _ := b.Allow("openstack/keystone")
+	th.AssertEquals(t, true, allowed)
+	b.Failure("openstack/keystone")
+	allowed, wait := b.Allow("openstack/keystone")
+	th.AssertEquals(t, false, allowed)
+	th.AssertEquals(t, time.Minute, wait)
+
+	// a single probe after openDuration
+	now = now.Add(time.Minute)
+	allowed, _ = b.Allow("openstack/keystone")
+	th.AssertEquals(t, true, allowed)
+	allowed, _ = b.Allow("openstack/keystone")
+	th.AssertEquals(t, false, allowed)
+
+	// a failed probe keeps it open
+	b.Failure("openstack/keystone")
+	now = now.Add(30 * time.Second)
+	allowed, wait = b.Allow("openstack/keystone")
+	th.AssertEquals(t, false, allowed)
+	th.AssertEquals(t, 30*time.Second, wait)
+
+	// a successful probe closes it
+	now = now.Add(30 * time.Second)
+	allowed, _ = b.Allow("openstack/keystone")
+	th.AssertEquals(t, true, allowed)
+	b.Success("openstack/keystone")
+	allowed, _ = b.Allow("openstack/keystone")
+	th.AssertEquals(t, true, allowed)
+}
+
+func TestCircuitBreakerKeys(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+
+	// an unavailable keystone does not block another one
+	b.Failure("openstack/keystone")
+	allowed, _ := b.Allow("openstack/keystone")
+	th.AssertEquals(t, false, allowed)
+	allowed, _ = b.Allow("edge/keystone")
+	th.AssertEquals(t, true, allowed)
+
+	// a success of another one does not close it
+	b.Success("edge/keystone")
+	allowed, _ = b.Allow("openstack/keystone")
+	th.AssertEquals(t, false, allowed)
+}
+
+func TestCircuitBreakerNil(t *testing.T) {
+	var b *CircuitBreaker
+	b.Failure("openstack/keystone")
+	allowed, _ := b.Allow("openstack/keystone")
+	th.AssertEquals(t, true, allowed)
+
+	// a threshold of 0 disables it
+	th.AssertEquals(t, true, NewCircuitBreaker(0, time.Minute) == nil)
+}
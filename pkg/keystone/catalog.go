@@ -0,0 +1,76 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/package keystone
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+)
+
+// GetCatalogEndpoints - returns the URLs of the endpoints of the service in
+// the client region ID with the endpoint type as index, as they are listed in
+// the catalog of the token of the client. Keystone builds the catalog on
+// every request, so it reflects what clients authenticating now get.
+func (c *Client) GetCatalogEndpoints(
+	log logr.Logger,
+	serviceID string,
+) (map[string]string, error) {
+	var r struct {
+		Catalog []struct {
+			ID        string `json:"id"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				RegionID  string `json:"region_id"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	}
+	_, err := c.osclient.Get(c.osclient.ServiceURL("auth", "catalog"), &r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := map[string]string{}
+	for _, service := range r.Catalog {
+		if service.ID != serviceID {
+			continue
+		}
+		for _, e := range service.Endpoints {
+			if e.RegionID == c.regionID {
+				urls[e.Interface] = e.URL
+			}
+		}
+	}
+
+	return urls, nil
+}
+
+// GetMissingCatalogEndpoints - returns the endpoints of declared which are
+// not in the catalog with their URL, formatted as <endpoint type> <url>
+func GetMissingCatalogEndpoints(
+	catalog map[string]string,
+	declared map[string]string,
+) []string {
+	missing := []string{}
+	for endpointType, url := range declared {
+		if catalog[endpointType] != url {
+			missing = append(missing, fmt.Sprintf("%s %s", endpointType, url))
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
@@ -0,0 +1,84 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+const catalogOutput = `
+{
+    "catalog": [
+        {
+            "id": "1234",
+            "type": "placement",
+            "name": "placement",
+            "endpoints": [
+                {"id": "1", "interface": "public", "region_id": "RegionOne", "url": "https://placement.example.com"},
+                {"id": "2", "interface": "public", "region_id": "RegionTwo", "url": "https://placement.two.example.com"}
+            ]
+        },
+        {
+            "id": "5678",
+            "type": "compute",
+            "name": "nova",
+            "endpoints": [
+                {"id": "3", "interface": "internal", "region_id": "RegionOne", "url": "http://nova.internal:8774"}
+            ]
+        }
+    ]
+}
+`
+
+func TestGetCatalogEndpoints(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/auth/catalog", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestHeader(t, r, "X-Auth-Token", fake.TokenID)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, catalogOutput)
+	})
+
+	c := &Client{osclient: fake.ServiceClient(), regionID: "RegionOne"}
+	urls, err := c.GetCatalogEndpoints(logr.Discard(), "1234")
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, map[string]string{"public": "https://placement.example.com"}, urls)
+}
+
+func TestGetMissingCatalogEndpoints(t *testing.T) {
+	missing := GetMissingCatalogEndpoints(
+		map[string]string{
+			"public":   "https://placement.example.com",
+			"internal": "http://placement.old:8778",
+		},
+		map[string]string{
+			"public":   "https://placement.example.com",
+			"internal": "http://placement.internal:8778",
+			"admin":    "http://placement.admin:8778",
+		})
+	th.AssertDeepEquals(t, []string{
+		"admin http://placement.admin:8778",
+		"internal http://placement.internal:8778",
+	}, missing)
+}
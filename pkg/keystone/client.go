@@ -0,0 +1,639 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/endpoint"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// AuthOpts - options used to authenticate against keystone
+type AuthOpts struct {
+	AuthURL    string
+	Username   string
+	Password   string
+	TenantName string
+	// TenantID - if set, takes precedence over TenantName
+	TenantID   string
+	DomainName string
+	// UserDomainName - domain of the Username, defaults to DomainName
+	UserDomainName string
+	// ProjectDomainName - domain of the TenantName, defaults to DomainName
+	ProjectDomainName string
+	// Region - region name used to select the identity endpoint from the catalog
+	Region string
+	// RegionID - region ID endpoints get registered in and looked up by,
+	// defaults to Region
+	RegionID string
+	// TokenID - if set, the token is used instead of the user credentials
+	TokenID string
+	// ServiceAccountToken - if set, exchanged for an unscoped token using the
+	// IdentityProvider and Protocol, which then gets scoped to the tenant
+	ServiceAccountToken string
+	IdentityProvider    string
+	Protocol            string
+	// ApplicationCredentialID - if set, the application credential and its
+	// ApplicationCredentialSecret are used instead of the user credentials
+	ApplicationCredentialID     string
+	ApplicationCredentialSecret string
+	// OIDCTokenEndpoint - if set, an access token for the OIDCClientID gets
+	// requested with the user credentials, which then gets exchanged like the
+	// ServiceAccountToken
+	OIDCTokenEndpoint string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	// IdentityEndpoint - if set, the identity v3 endpoint used for the
+	// requests instead of the one of the catalog
+	IdentityEndpoint string
+	// Microversion - if set, sent as identity microversion in the
+	// OpenStack-API-Version header
+	Microversion string
+	// RequestID - if set, sent with every request in the X-OpenStack-Request-ID
+	// header
+	RequestID *RequestID
+	// Transport - the transport the requests get sent with, defaults to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// ClientOptions - operator level options of the admin clients, set by main
+// on the reconcilers
+type ClientOptions struct {
+	// DefaultDomain - domain used when a CR does not specify one, defaults
+	// to DefaultDomain
+	DefaultDomain string
+	// Microversion - identity microversion requested with the
+	// OpenStack-API-Version header, none if empty
+	Microversion string
+	// ServiceAccountTokenFile - service account token of the operator
+	// exchanged for a keystone token with AuthMode serviceAccount, defaults
+	// to ServiceAccountTokenFile
+	ServiceAccountTokenFile string
+	// Credentials - where the passwords and tokens get read from, defaults
+	// to the SecretCredentialProvider
+	Credentials CredentialProvider
+	// Transports - the HTTP transports shared by the clients, defaults to
+	// http.DefaultTransport
+	Transports *Transports
+	// ClockSkewThreshold - clock skew to keystone above which it gets
+	// reported, 0 disables the report
+	ClockSkewThreshold time.Duration
+}
+
+// GetCredentials - returns the Credentials of the options, or the
+// SecretCredentialProvider if not set
+func (o ClientOptions) GetCredentials() CredentialProvider {
+	if o.Credentials == nil {
+		return SecretCredentialProvider{}
+	}
+
+	return o.Credentials
+}
+
+// Client - keystone identity v3 client used to manage the service catalog
+// and the service users
+type Client struct {
+	osclient  *gophercloud.ServiceClient
+	region    string
+	regionID  string
+	authURL   string
+	clockSkew time.Duration
+}
+
+// NewClient - authenticates against keystone and returns a new Client
+func NewClient(
+	log logr.Logger,
+	cfg AuthOpts,
+) (*Client, error) {
+	if cfg.OIDCTokenEndpoint != "" {
+		accessToken, err := getOIDCAccessToken(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServiceAccountToken = accessToken
+	}
+
+	opts := getAuthOptions(cfg)
+
+	provider, err := openstack.NewClient(opts.IdentityEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	rt := cfg.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	provider.HTTPClient = http.Client{
+		Transport: rt,
+	}
+	if cfg.RequestID != nil {
+		provider.HTTPClient.Transport = &requestIDTransport{
+			rt:        rt,
+			requestID: cfg.RequestID,
+		}
+	}
+	if cfg.ServiceAccountToken != "" {
+		opts.TokenID, err = exchangeServiceAccountToken(provider, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	authStart := time.Now()
+	err = openstack.Authenticate(provider, opts)
+	if err != nil {
+		return nil, err
+	}
+	// a token passed through was issued earlier, its issued_at tells nothing
+	// about the clock of keystone
+	var clockSkew time.Duration
+	if cfg.TokenID == "" {
+		clockSkew = getClockSkew(provider.GetAuthResult(), authStart, time.Now())
+	}
+
+	osclient, err := newIdentityV3(provider, cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.V(1).Info(fmt.Sprintf("Using identity endpoint %s", osclient.Endpoint))
+
+	osclient.Microversion = cfg.Microversion
+
+	region := cfg.Region
+	if region == "" {
+		// without region the identity endpoint got picked from any region of
+		// the catalog, which is only deterministic if there is a single one
+		region, err = resolveRegion(osclient)
+		if err != nil {
+			return nil, err
+		}
+		log.Info(fmt.Sprintf("No region configured, using the only region %q of keystone", region))
+	}
+
+	regionID := cfg.RegionID
+	if regionID == "" {
+		regionID = region
+	}
+
+	return &Client{
+		osclient:  osclient,
+		region:    region,
+		regionID:  regionID,
+		authURL:   cfg.AuthURL,
+		clockSkew: clockSkew,
+	}, nil
+}
+
+// getAuthOptions - returns the gophercloud AuthOptions for cfg. The user gets
+// authenticated in the UserDomainName, the TenantName is looked up in the
+// ProjectDomainName, both default to the DomainName.
+func getAuthOptions(cfg AuthOpts) gophercloud.AuthOptions {
+	userDomainName := cfg.UserDomainName
+	if userDomainName == "" {
+		userDomainName = cfg.DomainName
+	}
+	projectDomainName := cfg.ProjectDomainName
+	if projectDomainName == "" {
+		projectDomainName = cfg.DomainName
+	}
+
+	opts := gophercloud.AuthOptions{
+		IdentityEndpoint: cfg.AuthURL,
+	}
+	if cfg.TokenID != "" {
+		// the token is already scoped
+		opts.TokenID = cfg.TokenID
+	} else if cfg.ApplicationCredentialID != "" {
+		// application credentials are bound to their project
+		opts.ApplicationCredentialID = cfg.ApplicationCredentialID
+		opts.ApplicationCredentialSecret = cfg.ApplicationCredentialSecret
+	} else if cfg.ServiceAccountToken != "" {
+		// the token gets exchanged and scoped below
+		opts.Scope = &gophercloud.AuthScope{
+			ProjectID: cfg.TenantID,
+		}
+		if cfg.TenantID == "" {
+			opts.Scope.ProjectName = cfg.TenantName
+			opts.Scope.DomainName = projectDomainName
+		}
+	} else {
+		opts.Username = cfg.Username
+		opts.Password = cfg.Password
+		opts.DomainName = userDomainName
+		// scoping by ID avoids ambiguity when the project name exists in multiple domains
+		if cfg.TenantID != "" {
+			opts.Scope = &gophercloud.AuthScope{
+				ProjectID: cfg.TenantID,
+			}
+		} else {
+			opts.Scope = &gophercloud.AuthScope{
+				ProjectName: cfg.TenantName,
+				DomainName:  projectDomainName,
+			}
+		}
+	}
+
+	return opts
+}
+
+// newIdentityV3 - returns the identity v3 service client for the
+// IdentityEndpoint, or for the identity endpoint of the catalog in the region
+func newIdentityV3(
+	provider *gophercloud.ProviderClient,
+	cfg AuthOpts,
+) (*gophercloud.ServiceClient, error) {
+	if cfg.IdentityEndpoint != "" {
+		// e.g. keystone hosted below a path prefix, which the catalog of a
+		// proxied deployment does not reflect
+		endpoint := gophercloud.NormalizeURL(cfg.IdentityEndpoint)
+		return &gophercloud.ServiceClient{
+			ProviderClient: provider,
+			Endpoint:       endpoint,
+			ResourceBase:   endpoint,
+			Type:           "identity",
+		}, nil
+	}
+
+	err := validateIdentityEndpoint(provider, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	return openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{
+		Region: cfg.Region,
+	})
+}
+
+// exchangeServiceAccountToken - authenticates with the service account token
+// as bearer token against the federation protocol of the identity provider
+// and returns the unscoped keystone token
+func exchangeServiceAccountToken(
+	provider *gophercloud.ProviderClient,
+	cfg AuthOpts,
+) (string, error) {
+	url := fmt.Sprintf("%sv3/OS-FEDERATION/identity_providers/%s/protocols/%s/auth",
+		provider.IdentityBase, cfg.IdentityProvider, cfg.Protocol)
+
+	resp, err := provider.Request(http.MethodPost, url, &gophercloud.RequestOpts{
+		MoreHeaders: map[string]string{
+			"Authorization": "Bearer " + cfg.ServiceAccountToken,
+		},
+		OkCodes: []int{201},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", fmt.Errorf("no token returned by identity provider %s", cfg.IdentityProvider)
+	}
+
+	return token, nil
+}
+
+// getOIDCAccessToken - requests an access token for the OIDC client from the
+// token endpoint of the identity provider using the password grant
+func getOIDCAccessToken(cfg AuthOpts) (string, error) {
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {cfg.Username},
+		"password":   {cfg.Password},
+		"scope":      {"openid"},
+	}
+	req, err := http.NewRequest(http.MethodPost, cfg.OIDCTokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(cfg.OIDCClientID, cfg.OIDCClientSecret)
+
+	// a nil Transport uses http.DefaultTransport
+	resp, err := (&http.Client{Transport: cfg.Transport}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC token endpoint %s returned %s", cfg.OIDCTokenEndpoint, resp.Status)
+	}
+
+	var r struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", err
+	}
+	if r.AccessToken == "" {
+		return "", fmt.Errorf("no access token returned by OIDC token endpoint %s", cfg.OIDCTokenEndpoint)
+	}
+
+	return r.AccessToken, nil
+}
+
+// GetRegion - returns the region the client was created for
+func (c *Client) GetRegion() string {
+	return c.region
+}
+
+// GetRegionID - returns the ID of the region endpoints get registered in
+func (c *Client) GetRegionID() string {
+	return c.regionID
+}
+
+// GetAuthURL - returns the identity endpoint the client authenticated against
+func (c *Client) GetAuthURL() string {
+	return c.authURL
+}
+
+// GetClockSkew - returns how far the clock of keystone was ahead, or behind if
+// negative, of the local clock when the client authenticated
+func (c *Client) GetClockSkew() time.Duration {
+	return c.clockSkew
+}
+
+// GetIdentityEndpoint - returns the identity endpoint the client sends its
+// requests to
+func (c *Client) GetIdentityEndpoint() string {
+	return c.osclient.Endpoint
+}
+
+// GetAPIVersion - returns the identity API version reported by keystone
+func (c *Client) GetAPIVersion(
+	log logr.Logger,
+) (string, error) {
+	var r struct {
+		Version struct {
+			ID string `json:"id"`
+		} `json:"version"`
+	}
+
+	_, err := c.osclient.Get(c.osclient.Endpoint, &r, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Version.ID, nil
+}
+
+// GetDomainName - returns domainName, or the DefaultDomain of the options if
+// it is empty
+func (o ClientOptions) GetDomainName(domainName string) string {
+	if domainName != "" {
+		return domainName
+	}
+	if o.DefaultDomain != "" {
+		return o.DefaultDomain
+	}
+
+	return DefaultDomain
+}
+
+// GetAdminClient - get an admin Client for the keystoneAPI instance with the
+// operator level opts. The client is not cached, every call authenticates
+// with the scope currently in the spec (AdminProject, AdminProjectID,
+// AdminDomain, AuthMode), so a scope change takes effect with the next
+// reconcile.
+func GetAdminClient(
+	ctx context.Context,
+	h *helper.Helper,
+	keystoneAPI *keystonev1beta1.KeystoneAPI,
+	opts ClientOptions,
+) (*Client, ctrl.Result, error) {
+	// the credentials get read from the namespace of the keystoneAPI, which
+	// may differ from the one of the reconciled CR
+	if keystoneAPI.Namespace != h.GetBeforeObject().GetNamespace() {
+		var err error
+		h, err = helper.NewHelper(keystoneAPI, h.GetClient(), h.GetKClient(), h.GetScheme(), h.GetLogger())
+		if err != nil {
+			return nil, ctrl.Result{}, err
+		}
+	}
+
+	authOpts, ctrlResult, err := getAdminAuthOpts(ctx, h, keystoneAPI, opts)
+	if err != nil {
+		return nil, ctrl.Result{}, err
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		return nil, ctrlResult, nil
+	}
+	authOpts.IdentityEndpoint = keystoneAPI.Spec.IdentityEndpoint
+
+	// mutual TLS with the client certificate of the ClientTLSSecret
+	authOpts.Transport, ctrlResult, err = getClientCertTransport(ctx, h, keystoneAPI, opts.Transports)
+	if err != nil {
+		return nil, ctrl.Result{}, err
+	}
+	if (ctrlResult != ctrl.Result{}) {
+		return nil, ctrlResult, nil
+	}
+	if authOpts.Transport == nil {
+		authOpts.Transport = opts.Transports.Default()
+	}
+
+	if len(keystoneAPI.Spec.AuthURLs) == 0 {
+		// get public endpoint as authurl from keystone instance
+		authOpts.AuthURL, err = keystoneAPI.GetEndpoint(endpoint.EndpointPublic)
+		if err != nil {
+			return nil, ctrl.Result{}, err
+		}
+
+		c, err := NewClient(h.GetLogger(), authOpts)
+		if err != nil {
+			return nil, ctrl.Result{}, err
+		}
+
+		return c, ctrl.Result{}, nil
+	}
+
+	c, err := newClientFailover(h.GetLogger(), authOpts, keystoneAPI.Spec.AuthURLs, NewClient)
+	if err != nil {
+		return nil, ctrl.Result{}, err
+	}
+
+	return c, ctrl.Result{}, nil
+}
+
+// newClientFailover - returns a Client of newClient authenticated against the
+// first of authURLs keystone is available at. Only if keystone is unavailable
+// at an AuthURL the next one is tried, other errors, e.g. rejected
+// credentials, are returned right away. If keystone is unavailable at all of
+// them, ErrAuthURLsUnavailable is returned with the last error.
+func newClientFailover(
+	log logr.Logger,
+	authOpts AuthOpts,
+	authURLs []string,
+	newClient func(logr.Logger, AuthOpts) (*Client, error),
+) (*Client, error) {
+	var lastErr error
+	for _, authURL := range authURLs {
+		authOpts.AuthURL = authURL
+		c, err := newClient(log, authOpts)
+		if err == nil {
+			return c, nil
+		}
+		if !IsUnavailable(err) {
+			return nil, err
+		}
+		log.Info(fmt.Sprintf("Keystone unavailable at %s: %s", authURL, err.Error()))
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("%w, last error at %s: %s", ErrAuthURLsUnavailable, authOpts.AuthURL, lastErr.Error())
+}
+
+// getAdminAuthOpts - returns the AuthOpts without AuthURL for the AuthMode
+// of the keystoneAPI instance. The RequestID of ctx, if any, is used.
+func getAdminAuthOpts(
+	ctx context.Context,
+	h *helper.Helper,
+	keystoneAPI *keystonev1beta1.KeystoneAPI,
+	opts ClientOptions,
+) (AuthOpts, ctrl.Result, error) {
+	if keystoneAPI.Spec.AuthMode == keystonev1beta1.AuthModeToken {
+		tokenSecret := keystoneAPI.Spec.AuthTokenSecret
+		if tokenSecret == "" {
+			tokenSecret = keystoneAPI.Spec.Secret
+		}
+
+		// get the pre-scoped token from AuthTokenSecret
+		// using PasswordSelectors.AdminToken
+		token, ctrlResult, err := opts.GetCredentials().GetCredential(
+			ctx,
+			h,
+			tokenSecret,
+			keystoneAPI.Spec.PasswordSelectors.AdminToken)
+		if err != nil || (ctrlResult != ctrl.Result{}) {
+			return AuthOpts{}, ctrlResult, err
+		}
+
+		return AuthOpts{
+			TokenID:      token,
+			Region:       keystoneAPI.GetRegion(),
+			RegionID:     keystoneAPI.GetRegionID(),
+			Microversion: opts.Microversion,
+			RequestID:    RequestIDFromContext(ctx),
+		}, ctrl.Result{}, nil
+	}
+
+	if keystoneAPI.Spec.AuthMode == keystonev1beta1.AuthModeApplicationCredential {
+		// get the application credential secret from Spec.Secret
+		// using PasswordSelectors.ApplicationCredentialSecret
+		credentialSecret, ctrlResult, err := opts.GetCredentials().GetCredential(
+			ctx,
+			h,
+			keystoneAPI.Spec.Secret,
+			keystoneAPI.Spec.PasswordSelectors.ApplicationCredentialSecret)
+		if err != nil || (ctrlResult != ctrl.Result{}) {
+			return AuthOpts{}, ctrlResult, err
+		}
+
+		return AuthOpts{
+			ApplicationCredentialID:     keystoneAPI.Spec.ApplicationCredentialID,
+			ApplicationCredentialSecret: credentialSecret,
+			Region:                      keystoneAPI.GetRegion(),
+			RegionID:                    keystoneAPI.GetRegionID(),
+			Microversion:                opts.Microversion,
+			RequestID:                   RequestIDFromContext(ctx),
+		}, ctrl.Result{}, nil
+	}
+
+	if keystoneAPI.Spec.AuthMode == keystonev1beta1.AuthModeServiceAccount {
+		// projected service account tokens get rotated, read it every time
+		tokenFile := opts.ServiceAccountTokenFile
+		if tokenFile == "" {
+			tokenFile = ServiceAccountTokenFile
+		}
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return AuthOpts{}, ctrl.Result{}, err
+		}
+
+		return AuthOpts{
+			ServiceAccountToken: strings.TrimSpace(string(token)),
+			IdentityProvider:    keystoneAPI.Spec.FederationIdentityProvider,
+			Protocol:            keystoneAPI.Spec.FederationProtocol,
+			TenantName:          keystoneAPI.Spec.AdminProject,
+			TenantID:            keystoneAPI.Spec.AdminProjectID,
+			DomainName:          opts.GetDomainName(keystoneAPI.Spec.AdminDomain),
+			ProjectDomainName:   opts.GetDomainName(keystoneAPI.GetAdminProjectDomain()),
+			Region:              keystoneAPI.GetRegion(),
+			RegionID:            keystoneAPI.GetRegionID(),
+			Microversion:        opts.Microversion,
+			RequestID:           RequestIDFromContext(ctx),
+		}, ctrl.Result{}, nil
+	}
+
+	// get the password of the admin user from Spec.Secret
+	// using PasswordSelectors.Admin
+	authPassword, ctrlResult, err := opts.GetCredentials().GetCredential(
+		ctx,
+		h,
+		keystoneAPI.Spec.Secret,
+		keystoneAPI.Spec.PasswordSelectors.Admin)
+	if err != nil || (ctrlResult != ctrl.Result{}) {
+		return AuthOpts{}, ctrlResult, err
+	}
+
+	authOpts := AuthOpts{
+		Username:          keystoneAPI.Spec.AdminUser,
+		Password:          authPassword,
+		TenantName:        keystoneAPI.Spec.AdminProject,
+		TenantID:          keystoneAPI.Spec.AdminProjectID,
+		DomainName:        opts.GetDomainName(keystoneAPI.Spec.AdminDomain),
+		UserDomainName:    opts.GetDomainName(keystoneAPI.GetAdminUserDomain()),
+		ProjectDomainName: opts.GetDomainName(keystoneAPI.GetAdminProjectDomain()),
+		Region:            keystoneAPI.GetRegion(),
+		RegionID:          keystoneAPI.GetRegionID(),
+		Microversion:      opts.Microversion,
+		RequestID:         RequestIDFromContext(ctx),
+	}
+
+	if keystoneAPI.Spec.AuthMode == keystonev1beta1.AuthModeOIDCPassword {
+		// get the secret of the OIDC client from Spec.Secret
+		// using PasswordSelectors.OIDCClientSecret
+		clientSecret, ctrlResult, err := opts.GetCredentials().GetCredential(
+			ctx,
+			h,
+			keystoneAPI.Spec.Secret,
+			keystoneAPI.Spec.PasswordSelectors.OIDCClientSecret)
+		if err != nil || (ctrlResult != ctrl.Result{}) {
+			return AuthOpts{}, ctrlResult, err
+		}
+
+		authOpts.OIDCTokenEndpoint = keystoneAPI.Spec.OIDCTokenEndpoint
+		authOpts.OIDCClientID = keystoneAPI.Spec.OIDCClientID
+		authOpts.OIDCClientSecret = clientSecret
+		authOpts.IdentityProvider = keystoneAPI.Spec.FederationIdentityProvider
+		authOpts.Protocol = keystoneAPI.Spec.FederationProtocol
+	}
+
+	return authOpts, ctrl.Result{}, nil
+}
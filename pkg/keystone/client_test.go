@@ -0,0 +1,192 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestExchangeServiceAccountToken(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/v3/OS-FEDERATION/identity_providers/kubernetes/protocols/openid/auth", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		th.TestHeader(t, r, "Authorization", "Bearer sa-token")
+
+		w.Header().Add("X-Subject-Token", "unscoped-token")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	provider := &gophercloud.ProviderClient{IdentityBase: th.Endpoint()}
+	token, err := exchangeServiceAccountToken(provider, AuthOpts{
+		ServiceAccountToken: "sa-token",
+		IdentityProvider:    "kubernetes",
+		Protocol:            "openid",
+	})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "unscoped-token", token)
+}
+
+func TestGetOIDCAccessToken(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		clientID, clientSecret, ok := r.BasicAuth()
+		th.AssertEquals(t, true, ok)
+		th.AssertEquals(t, "keystone", clientID)
+		th.AssertEquals(t, "client-secret", clientSecret)
+		th.TestFormValues(t, r, map[string]string{
+			"grant_type": "password",
+			"username":   "admin",
+			"password":   "12345678",
+			"scope":      "openid",
+		})
+
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "oidc-token", "token_type": "Bearer"}`)
+	})
+
+	token, err := getOIDCAccessToken(AuthOpts{
+		Username:          "admin",
+		Password:          "12345678",
+		OIDCTokenEndpoint: th.Endpoint() + "token",
+		OIDCClientID:      "keystone",
+		OIDCClientSecret:  "client-secret",
+	})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "oidc-token", token)
+}
+
+func TestNewIdentityV3Override(t *testing.T) {
+	// the catalog of the provider is not consulted with an IdentityEndpoint
+	provider := &gophercloud.ProviderClient{}
+	osclient, err := newIdentityV3(provider, AuthOpts{
+		IdentityEndpoint: "https://cloud.example.com/identity/v3",
+	})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "https://cloud.example.com/identity/v3/", osclient.Endpoint)
+	th.AssertEquals(t, "https://cloud.example.com/identity/v3/services", osclient.ServiceURL("services"))
+}
+
+func TestGetAuthOptionsDomains(t *testing.T) {
+	// both domains default to the DomainName
+	opts := getAuthOptions(AuthOpts{
+		Username:   "admin",
+		TenantName: "admin",
+		DomainName: "Default",
+	})
+	th.AssertEquals(t, "Default", opts.DomainName)
+	th.AssertEquals(t, "admin", opts.Scope.ProjectName)
+	th.AssertEquals(t, "Default", opts.Scope.DomainName)
+
+	opts = getAuthOptions(AuthOpts{
+		Username:          "admin",
+		TenantName:        "cloud-admin",
+		DomainName:        "Default",
+		UserDomainName:    "ldap",
+		ProjectDomainName: "admins",
+	})
+	th.AssertEquals(t, "ldap", opts.DomainName)
+	th.AssertEquals(t, "cloud-admin", opts.Scope.ProjectName)
+	th.AssertEquals(t, "admins", opts.Scope.DomainName)
+
+	// a project ID needs no domain
+	opts = getAuthOptions(AuthOpts{
+		Username:          "admin",
+		TenantID:          "1234",
+		UserDomainName:    "ldap",
+		ProjectDomainName: "admins",
+	})
+	th.AssertEquals(t, "1234", opts.Scope.ProjectID)
+	th.AssertEquals(t, "", opts.Scope.DomainName)
+
+	opts = getAuthOptions(AuthOpts{
+		ServiceAccountToken: "token",
+		TenantName:          "cloud-admin",
+		DomainName:          "Default",
+		ProjectDomainName:   "admins",
+	})
+	th.AssertEquals(t, "admins", opts.Scope.DomainName)
+}
+
+func TestNewClientFailover(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "http://a:5000/v3/auth/tokens", Err: &net.OpError{Op: "dial", Net: "tcp"}}
+	err503 := gophercloud.ErrDefault503{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusServiceUnavailable}}
+	err401 := gophercloud.ErrDefault401{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusUnauthorized}}
+
+	// newClient fails with the error of the AuthURL, records the tried ones
+	newClient := func(errs map[string]error, tried *[]string) func(logr.Logger, AuthOpts) (*Client, error) {
+		return func(log logr.Logger, cfg AuthOpts) (*Client, error) {
+			*tried = append(*tried, cfg.AuthURL)
+			if err := errs[cfg.AuthURL]; err != nil {
+				return nil, err
+			}
+			return &Client{authURL: cfg.AuthURL}, nil
+		}
+	}
+	authURLs := []string{"http://a:5000", "http://b:5000", "http://c:5000"}
+
+	// fails over in order while keystone is unavailable
+	tried := []string{}
+	c, err := newClientFailover(logr.Discard(), AuthOpts{}, authURLs, newClient(map[string]error{
+		"http://a:5000": refused,
+		"http://b:5000": err503,
+	}, &tried))
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "http://c:5000", c.GetAuthURL())
+	th.AssertDeepEquals(t, authURLs, tried)
+
+	// rejected credentials get returned right away
+	tried = []string{}
+	_, err = newClientFailover(logr.Discard(), AuthOpts{}, authURLs, newClient(map[string]error{
+		"http://a:5000": refused,
+		"http://b:5000": err401,
+	}, &tried))
+	th.AssertEquals(t, true, errors.As(err, &gophercloud.ErrDefault401{}))
+	th.AssertDeepEquals(t, authURLs[:2], tried)
+
+	// all unavailable returns the last error
+	tried = []string{}
+	_, err = newClientFailover(logr.Discard(), AuthOpts{}, authURLs, newClient(map[string]error{
+		"http://a:5000": refused,
+		"http://b:5000": refused,
+		"http://c:5000": err503,
+	}, &tried))
+	th.AssertEquals(t, true, errors.Is(err, ErrAuthURLsUnavailable))
+	th.AssertEquals(t, true, strings.Contains(err.Error(), "http://c:5000"))
+	th.AssertEquals(t, true, strings.Contains(err.Error(), err503.Error()))
+	th.AssertDeepEquals(t, authURLs, tried)
+}
+
+func TestClientOptionsGetDomainName(t *testing.T) {
+	th.AssertEquals(t, "Default", ClientOptions{}.GetDomainName(""))
+	th.AssertEquals(t, "service", ClientOptions{}.GetDomainName("service"))
+	th.AssertEquals(t, "openstack", ClientOptions{DefaultDomain: "openstack"}.GetDomainName(""))
+	th.AssertEquals(t, "service", ClientOptions{DefaultDomain: "openstack"}.GetDomainName("service"))
+}
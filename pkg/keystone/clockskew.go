@@ -0,0 +1,68 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// DefaultClockSkewThreshold - default skew between the clocks of the
+// operator and keystone above which it gets reported
+const DefaultClockSkewThreshold = 30 * time.Second
+
+// ClockSkewed - returns true if skew exceeds threshold in either direction, a
+// threshold of 0 disables the check
+func ClockSkewed(skew time.Duration, threshold time.Duration) bool {
+	if skew < 0 {
+		skew = -skew
+	}
+
+	return threshold > 0 && skew > threshold
+}
+
+// getClockSkew - returns how far the issued_at of the token of the auth result
+// is ahead, or behind if negative, of the local time window from start to end
+// of the authentication. 0 if it is within the window, which absorbs the
+// request latency, or if the token has no issued_at.
+func getClockSkew(result gophercloud.AuthResult, start time.Time, end time.Time) time.Duration {
+	r, ok := result.(interface{ ExtractInto(interface{}) error })
+	if !ok {
+		return 0
+	}
+
+	var body struct {
+		Token struct {
+			IssuedAt time.Time `json:"issued_at"`
+		} `json:"token"`
+	}
+	if err := r.ExtractInto(&body); err != nil {
+		return 0
+	}
+
+	issuedAt := body.Token.IssuedAt
+	switch {
+	case issuedAt.IsZero():
+		return 0
+	case issuedAt.Before(start):
+		return issuedAt.Sub(start)
+	case issuedAt.After(end):
+		return issuedAt.Sub(end)
+	}
+
+	return 0
+}
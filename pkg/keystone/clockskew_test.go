@@ -0,0 +1,59 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+// tokenResult - auth result with a token issued at issuedAt
+type tokenResult struct {
+	gophercloud.Result
+}
+
+func (r tokenResult) ExtractTokenID() (string, error) {
+	return "token", nil
+}
+
+func newTokenResult(issuedAt string) tokenResult {
+	return tokenResult{gophercloud.Result{Body: map[string]interface{}{
+		"token": map[string]interface{}{
+			"issued_at":  issuedAt,
+			"expires_at": "2026-10-16T13:00:00.000000Z",
+		},
+	}}}
+}
+
+func TestGetClockSkew(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Second)
+
+	th.AssertEquals(t, time.Duration(0), getClockSkew(newTokenResult("2026-10-16T12:00:00.500000Z"), start, end))
+	th.AssertEquals(t, 2*time.Minute, getClockSkew(newTokenResult("2026-10-16T12:02:01.000000Z"), start, end))
+	th.AssertEquals(t, -time.Minute, getClockSkew(newTokenResult("2026-10-16T11:59:00.000000Z"), start, end))
+	th.AssertEquals(t, time.Duration(0), getClockSkew(tokenResult{gophercloud.Result{Body: map[string]interface{}{}}}, start, end))
+}
+
+func TestClockSkewed(t *testing.T) {
+	th.AssertEquals(t, false, ClockSkewed(10*time.Second, DefaultClockSkewThreshold))
+	th.AssertEquals(t, true, ClockSkewed(time.Minute, DefaultClockSkewThreshold))
+	th.AssertEquals(t, true, ClockSkewed(-time.Minute, DefaultClockSkewThreshold))
+	th.AssertEquals(t, false, ClockSkewed(time.Minute, 0))
+}
@@ -33,3 +33,11 @@ const (
 	// KollaConfig -
 	KollaConfig = "/var/lib/config-data/merged/keystone-api-config.json"
 )
+
+// DefaultDomain - domain used when a CR does not specify one and the
+// ClientOptions set no other
+const DefaultDomain = "Default"
+
+// ServiceAccountTokenFile - default service account token of the operator
+// exchanged for a keystone token with AuthMode serviceAccount
+const ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
@@ -0,0 +1,148 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/secret"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// CredentialProvider - resolves the passwords and tokens the reconcilers
+// authenticate with and set for the service users, by the Secret name in the
+// spec of the CR and the key in it. A non empty ctrl.Result is returned while
+// the credential does not exist yet.
+type CredentialProvider interface {
+	GetCredential(ctx context.Context, h *helper.Helper, secretName string, key string) (string, ctrl.Result, error)
+}
+
+// SecretCredentialProvider - reads the credentials from the Kubernetes Secret
+// in the namespace of the CR
+type SecretCredentialProvider struct{}
+
+// GetCredential - returns the value of key in the Secret secretName
+func (SecretCredentialProvider) GetCredential(
+	ctx context.Context,
+	h *helper.Helper,
+	secretName string,
+	key string,
+) (string, ctrl.Result, error) {
+	return secret.GetDataFromSecret(ctx, h, secretName, 10, key)
+}
+
+// VaultCredentialProvider - reads the credentials from the KV version 2
+// secrets engine of Vault. The Secret name of a CR maps to the Vault secret
+// <Path>/<namespace>/<secret name>, the key to a key of its data.
+type VaultCredentialProvider struct {
+	// Address - address of Vault, e.g. https://vault.example.com:8200
+	Address string
+	// Path - API path of the secrets below the mount, e.g.
+	// secret/data/keystone-operator
+	Path string
+	// TokenFile - file with the Vault token, read on every request as e.g.
+	// the Vault agent rotates it
+	TokenFile string
+	// Transport - transport the requests get sent with, defaults to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+	// Timeout - timeout of a request to Vault, defaults to VaultTimeout
+	Timeout time.Duration
+}
+
+// VaultTimeout - default timeout of a request to Vault
+const VaultTimeout = 10 * time.Second
+
+// GetCredential - returns the value of key in the Vault secret of secretName
+func (p VaultCredentialProvider) GetCredential(
+	ctx context.Context,
+	h *helper.Helper,
+	secretName string,
+	key string,
+) (string, ctrl.Result, error) {
+	value, found, err := p.read(ctx, h.GetBeforeObject().GetNamespace(), secretName, key)
+	if err != nil {
+		return "", ctrl.Result{}, err
+	}
+	if !found {
+		h.GetLogger().Info(fmt.Sprintf("Vault secret %s/%s not found, retrying", p.Path, secretName))
+		return "", ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
+	return value, ctrl.Result{}, nil
+}
+
+// read - returns the value of key in the Vault secret of secretName in
+// namespace, and false if the secret does not exist
+func (p VaultCredentialProvider) read(
+	ctx context.Context,
+	namespace string,
+	secretName string,
+	key string,
+) (string, bool, error) {
+	token, err := os.ReadFile(p.TokenFile)
+	if err != nil {
+		return "", false, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s",
+		strings.TrimSuffix(p.Address, "/"), strings.Trim(p.Path, "/"), namespace, secretName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("X-Vault-Token", strings.TrimSpace(string(token)))
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = VaultTimeout
+	}
+	// a nil Transport uses http.DefaultTransport
+	resp, err := (&http.Client{Transport: p.Transport, Timeout: timeout}).Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("reading Vault secret %s failed: %s", url, resp.Status)
+	}
+
+	var r struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", false, err
+	}
+	value, ok := r.Data.Data[key]
+	if !ok {
+		return "", false, fmt.Errorf("key %s not found in Vault secret %s", key, url)
+	}
+
+	return value, true, nil
+}
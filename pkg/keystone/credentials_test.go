@@ -0,0 +1,98 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestVaultCredentialProviderRead(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/v1/secret/data/keystone-operator/openstack/osp-secret", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestHeader(t, r, "X-Vault-Token", "vault-token")
+
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": {"data": {"AdminPassword": "12345678"}, "metadata": {"version": 1}}}`)
+	})
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	th.AssertNoErr(t, os.WriteFile(tokenFile, []byte("vault-token\n"), 0600))
+
+	p := VaultCredentialProvider{
+		Address:   th.Endpoint(),
+		Path:      "secret/data/keystone-operator",
+		TokenFile: tokenFile,
+	}
+	value, found, err := p.read(context.TODO(), "openstack", "osp-secret", "AdminPassword")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, found)
+	th.AssertEquals(t, "12345678", value)
+
+	_, _, err = p.read(context.TODO(), "openstack", "osp-secret", "PlacementPassword")
+	th.AssertEquals(t, true, err != nil)
+
+	_, found, err = p.read(context.TODO(), "openstack", "other-secret", "AdminPassword")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, found)
+}
+
+func TestVaultCredentialProviderReadFailed(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/v1/secret/data/keystone-operator/openstack/osp-secret", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+	})
+	unblock := make(chan struct{})
+	defer close(unblock)
+	th.Mux.HandleFunc("/v1/secret/data/keystone-operator/openstack/slow-secret", func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	})
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	th.AssertNoErr(t, os.WriteFile(tokenFile, []byte("vault-token\n"), 0600))
+
+	p := VaultCredentialProvider{
+		Address:   th.Endpoint(),
+		Path:      "secret/data/keystone-operator",
+		TokenFile: tokenFile,
+		Timeout:   100 * time.Millisecond,
+	}
+
+	// a denied request is an error, not a missing secret
+	_, found, err := p.read(context.TODO(), "openstack", "osp-secret", "AdminPassword")
+	th.AssertEquals(t, false, found)
+	th.AssertEquals(t, true, err != nil)
+	th.AssertEquals(t, true, strings.Contains(err.Error(), "403"))
+
+	// a hanging Vault times out
+	_, _, err = p.read(context.TODO(), "openstack", "slow-secret", "AdminPassword")
+	th.AssertEquals(t, true, err != nil)
+	th.AssertEquals(t, true, IsUnavailable(err))
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/services"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	openstack "github.com/openstack-k8s-operators/lib-common/modules/openstack"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// catalog change actions
+const (
+	// CatalogChangeCreate - the service or endpoint would get registered
+	CatalogChangeCreate = "create"
+	// CatalogChangeUpdate - the service or endpoint would get updated
+	CatalogChangeUpdate = "update"
+	// CatalogChangeDelete - the service or endpoint would get deleted
+	CatalogChangeDelete = "delete"
+)
+
+// CatalogChange - a change the operator would make in keystone to reconcile
+// the catalog to the KeystoneServices and KeystoneEndpoints
+type CatalogChange struct {
+	// Object - namespace/name of the KeystoneService or KeystoneEndpoint
+	Object string
+	// Action - one of the CatalogChange actions
+	Action string
+	// Resource - the keystone service or endpoint, e.g. service placement/placement
+	Resource string
+	// Detail - the differing attributes of an update
+	Detail string
+}
+
+// String - returns the change as a line of the report
+func (c CatalogChange) String() string {
+	s := fmt.Sprintf("%s: %s %s", c.Object, c.Action, c.Resource)
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+
+	return s
+}
+
+// DiffCatalog - returns the changes the operator would make in keystone to
+// reconcile the catalog to ksServices and ksEndpoints, without changing
+// anything. The endpoints are compared in the region of the client, the
+// changes are sorted by object.
+func DiffCatalog(
+	log logr.Logger,
+	c IdentityClient,
+	ksServices []keystonev1beta1.KeystoneService,
+	ksEndpoints []keystonev1beta1.KeystoneEndpoint,
+) ([]CatalogChange, error) {
+	changes := []CatalogChange{}
+	for _, instance := range ksServices {
+		serviceChanges, err := diffServices(log, c, instance)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, serviceChanges...)
+	}
+
+	// the endpoints of a service can be declared by several KeystoneEndpoints,
+	// only the interfaces none of them declares get deleted
+	declaredByService := map[string]sets.String{}
+	for _, instance := range ksEndpoints {
+		if instance.Status.ServiceID == "" {
+			continue
+		}
+		if declaredByService[instance.Status.ServiceID] == nil {
+			declaredByService[instance.Status.ServiceID] = sets.NewString()
+		}
+		for endpointType := range instance.GetEndpoints() {
+			declaredByService[instance.Status.ServiceID].Insert(endpointType)
+		}
+	}
+	pruned := sets.NewString()
+	for _, instance := range ksEndpoints {
+		endpointChanges, err := diffEndpoints(log, c, instance, declaredByService[instance.Status.ServiceID], pruned)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, endpointChanges...)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Object < changes[j].Object
+	})
+
+	return changes, nil
+}
+
+// diffServices - returns the changes of the service and the additional
+// services of instance
+func diffServices(
+	log logr.Logger,
+	c IdentityClient,
+	instance keystonev1beta1.KeystoneService,
+) ([]CatalogChange, error) {
+	// the services another tool registers are left as they are
+	if !instance.IsServiceManaged() {
+		return nil, nil
+	}
+
+	object := instance.Namespace + "/" + instance.Name
+	nameLookup := instance.GetNameLookup()
+
+	changes := []CatalogChange{}
+	for i, d := range instance.GetServiceDefinitions() {
+		resource := fmt.Sprintf("service %s/%s", d.ServiceType, d.ServiceName)
+		service, err := getService(log, c, nameLookup, d.ServiceType, d.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		// a renamed service is found by the ID in the status
+		if serviceID := instance.GetServiceID(d.ServiceName); service == nil && i == 0 && serviceID != "" {
+			service, err = c.GetServiceByID(log, serviceID)
+			if err != nil {
+				return nil, err
+			}
+			if service != nil && service.Type != d.ServiceType {
+				service = nil
+			}
+		}
+		if service == nil {
+			changes = append(changes, CatalogChange{Object: object, Action: CatalogChangeCreate, Resource: resource})
+			continue
+		}
+
+		detail := diffService(service, d.ServiceName, d.ServiceDescription, instance.IsEnabled(), instance.Spec.Tags)
+		if detail != "" {
+			changes = append(changes, CatalogChange{Object: object, Action: CatalogChangeUpdate, Resource: resource, Detail: detail})
+		}
+	}
+
+	// the additional services removed from the spec get deleted
+	for i, serviceID := range instance.Status.AdditionalServiceIDs {
+		if i < len(instance.Spec.AdditionalServices) {
+			continue
+		}
+		changes = append(changes, CatalogChange{Object: object, Action: CatalogChangeDelete, Resource: "service " + serviceID})
+	}
+
+	return changes, nil
+}
+
+// diffService - returns the attributes of service differing from the ones
+// declared, as attribute: actual -> declared, empty if there are none
+func diffService(
+	service *services.Service,
+	name string,
+	description string,
+	enabled bool,
+	tags []string,
+) string {
+	diffs := []string{}
+	if actual, _ := service.Extra["name"].(string); actual != name {
+		diffs = append(diffs, fmt.Sprintf("name: %s -> %s", actual, name))
+	}
+	if actual, _ := service.Extra["description"].(string); actual != description {
+		diffs = append(diffs, fmt.Sprintf("description: %s -> %s", actual, description))
+	}
+	if service.Enabled != enabled {
+		diffs = append(diffs, fmt.Sprintf("enabled: %t -> %t", service.Enabled, enabled))
+	}
+	if actual := GetServiceTags(service); !sets.NewString(actual...).Equal(sets.NewString(tags...)) {
+		diffs = append(diffs, fmt.Sprintf("tags: %v -> %v", actual, tags))
+	}
+
+	return strings.Join(diffs, ", ")
+}
+
+// diffEndpoints - returns the changes of the endpoints of instance. declared
+// are the interfaces the KeystoneEndpoints of the service declare, the IDs of
+// the endpoints to delete are added to pruned to report them once.
+func diffEndpoints(
+	log logr.Logger,
+	c IdentityClient,
+	instance keystonev1beta1.KeystoneEndpoint,
+	declared sets.String,
+	pruned sets.String,
+) ([]CatalogChange, error) {
+	object := instance.Namespace + "/" + instance.Name
+	endpoints := instance.GetEndpoints()
+	endpointTypes := sets.StringKeySet(endpoints).List()
+
+	changes := []CatalogChange{}
+	// the service is not registered yet, all endpoints get created
+	if instance.Status.ServiceID == "" {
+		for _, endpointType := range endpointTypes {
+			changes = append(changes, CatalogChange{
+				Object:   object,
+				Action:   CatalogChangeCreate,
+				Resource: fmt.Sprintf("endpoint %s %s", instance.GetCatalogServiceName(), endpointType),
+				Detail:   endpoints[endpointType],
+			})
+		}
+		return changes, nil
+	}
+
+	allEndpoints, err := c.GetServiceEndpoints(log, instance.Status.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, endpointType := range endpointTypes {
+		resource := fmt.Sprintf("endpoint %s %s", instance.GetCatalogServiceName(), endpointType)
+		availability, err := openstack.GetAvailability(endpointType)
+		if err != nil {
+			return nil, err
+		}
+
+		registered := FilterEndpoints(allEndpoints, c.GetRegionID(), availability)
+		switch len(registered) {
+		case 0:
+			changes = append(changes, CatalogChange{Object: object, Action: CatalogChangeCreate, Resource: resource, Detail: endpoints[endpointType]})
+		case 1:
+			if registered[0].URL != endpoints[endpointType] {
+				changes = append(changes, CatalogChange{
+					Object:   object,
+					Action:   CatalogChangeUpdate,
+					Resource: resource,
+					Detail:   fmt.Sprintf("url: %s -> %s", registered[0].URL, endpoints[endpointType]),
+				})
+			}
+		default:
+			log.Info(fmt.Sprintf("Multiple %s endpoints registered for service %s, skipping them", endpointType, instance.GetCatalogServiceName()))
+		}
+	}
+
+	// the undeclared endpoints of the region get deleted, with the report
+	// prune policy they are only listed in the status
+	if instance.Spec.PrunePolicy == keystonev1beta1.PrunePolicyReport {
+		return changes, nil
+	}
+	for _, e := range allEndpoints {
+		if e.Region != c.GetRegionID() || declared.Has(string(e.Availability)) || pruned.Has(e.ID) {
+			continue
+		}
+		pruned.Insert(e.ID)
+		changes = append(changes, CatalogChange{
+			Object:   object,
+			Action:   CatalogChangeDelete,
+			Resource: fmt.Sprintf("endpoint %s %s", instance.GetCatalogServiceName(), e.Availability),
+			Detail:   e.URL,
+		})
+	}
+
+	return changes, nil
+}
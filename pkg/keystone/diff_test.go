@@ -0,0 +1,94 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffCatalog(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// placement is registered disabled, nova is not registered
+	th.Mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("name") == "placement" {
+			fmt.Fprintf(w, serviceListOutput, fmt.Sprintf(placementService, false))
+			return
+		}
+		fmt.Fprintf(w, serviceListOutput, "")
+	})
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "1234", r.URL.Query().Get("service_id"))
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, placementEndpoints)
+	})
+
+	ksServices := []keystonev1beta1.KeystoneService{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+			Spec:       placementSpec,
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "nova", Namespace: "openstack"},
+			Spec: keystonev1beta1.KeystoneServiceSpec{
+				ServiceType: "compute",
+				ServiceName: "nova",
+				Enabled:     true,
+			},
+		},
+	}
+	ksEndpoints := []keystonev1beta1.KeystoneEndpoint{{
+		ObjectMeta: metav1.ObjectMeta{Name: "placement", Namespace: "openstack"},
+		Spec: keystonev1beta1.KeystoneEndpointSpec{
+			ServiceName: "placement",
+			Endpoints: map[string]string{
+				"public": "https://placement.example.com",
+				"admin":  "http://placement.admin:8778",
+			},
+		},
+		Status: keystonev1beta1.KeystoneEndpointStatus{ServiceID: "1234"},
+	}}
+
+	c := &Client{osclient: fake.ServiceClient(), regionID: "RegionOne"}
+	changes, err := DiffCatalog(logr.Discard(), c, ksServices, ksEndpoints)
+	th.AssertNoErr(t, err)
+
+	report := []string{}
+	for _, change := range changes {
+		report = append(report, change.String())
+	}
+	th.AssertDeepEquals(t, []string{
+		"openstack/nova: create service compute/nova",
+		"openstack/placement: update service placement/placement (enabled: false -> true)",
+		"openstack/placement: create endpoint placement admin (http://placement.admin:8778)",
+		"openstack/placement: delete endpoint placement internal (http://placement.internal:8778)",
+	}, report)
+}
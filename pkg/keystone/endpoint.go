@@ -0,0 +1,424 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/endpoints"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/extensions/projectendpoints"
+)
+
+// Endpoint - keystone endpoint
+type Endpoint struct {
+	Name         string
+	ServiceID    string
+	Availability gophercloud.Availability
+	URL          string
+}
+
+// GetEndpoints - returns the endpoints registered in the client region ID for
+// the service and availability. The filters are applied by keystone, which
+// does not paginate its list APIs, so this is a single request.
+func (c *Client) GetEndpoints(
+	log logr.Logger,
+	serviceID string,
+	availability gophercloud.Availability,
+) ([]endpoints.Endpoint, error) {
+	listOpts := endpoints.ListOpts{
+		ServiceID:    serviceID,
+		Availability: availability,
+		RegionID:     c.regionID,
+	}
+
+	allPages, err := endpoints.List(c.osclient, listOpts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoints.ExtractEndpoints(allPages)
+}
+
+// GetServiceEndpoints - returns the endpoints registered for the service in
+// all regions
+func (c *Client) GetServiceEndpoints(
+	log logr.Logger,
+	serviceID string,
+) ([]endpoints.Endpoint, error) {
+	listOpts := endpoints.ListOpts{
+		ServiceID: serviceID,
+	}
+
+	allPages, err := endpoints.List(c.osclient, listOpts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoints.ExtractEndpoints(allPages)
+}
+
+// GetUndeclaredEndpoints - returns the endpoints which are not in region or
+// whose endpoint type is not in declared, formatted as
+// <region>/<endpoint type> <url> (<id>)
+func GetUndeclaredEndpoints(
+	allEndpoints []endpoints.Endpoint,
+	region string,
+	declared map[string]string,
+) []string {
+	undeclared := []string{}
+	for _, e := range allEndpoints {
+		if _, ok := declared[string(e.Availability)]; ok && e.Region == region {
+			continue
+		}
+		undeclared = append(undeclared, fmt.Sprintf("%s/%s %s (%s)", e.Region, e.Availability, e.URL, e.ID))
+	}
+	sort.Strings(undeclared)
+
+	return undeclared
+}
+
+// FilterEndpoints - returns the endpoints of allEndpoints in region with the
+// availability, to diff a single list of the endpoints of a service in memory
+func FilterEndpoints(
+	allEndpoints []endpoints.Endpoint,
+	region string,
+	availability gophercloud.Availability,
+) []endpoints.Endpoint {
+	filtered := []endpoints.Endpoint{}
+	for _, e := range allEndpoints {
+		if e.Region == region && e.Availability == availability {
+			filtered = append(filtered, e)
+		}
+	}
+
+	return filtered
+}
+
+// GetEndpoint - returns the endpoint with endpointID
+func (c *Client) GetEndpoint(
+	log logr.Logger,
+	endpointID string,
+) (*endpoints.Endpoint, error) {
+	var r struct {
+		Endpoint endpoints.Endpoint `json:"endpoint"`
+	}
+
+	_, err := c.osclient.Get(c.osclient.ServiceURL("endpoints", endpointID), &r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r.Endpoint, nil
+}
+
+// CreateEndpoint - creates an endpoint in the client region and returns its ID
+func (c *Client) CreateEndpoint(
+	log logr.Logger,
+	e Endpoint,
+) (string, error) {
+	createOpts := endpoints.CreateOpts{
+		Availability: e.Availability,
+		Name:         e.Name,
+		Region:       c.regionID,
+		URL:          e.URL,
+		ServiceID:    e.ServiceID,
+	}
+
+	endpoint, err := endpoints.Create(c.osclient, createOpts).Extract()
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("Endpoint %s %s created with ID %s", e.Name, e.Availability, endpoint.ID))
+
+	return endpoint.ID, nil
+}
+
+// IsConflict - returns true if keystone rejected the request with a 409,
+// e.g. because the endpoint to create already exists
+func IsConflict(err error) bool {
+	var err409 gophercloud.ErrDefault409
+	if errors.As(err, &err409) {
+		return true
+	}
+
+	var errCode gophercloud.ErrUnexpectedResponseCode
+	return errors.As(err, &errCode) && errCode.Actual == http.StatusConflict
+}
+
+// AdoptConflictingEndpoint - returns the ID of the endpoint e registered in
+// the client region after its create failed with the conflict createErr,
+// e.g. because a concurrent reconcile registered it or the status with its
+// ID got lost. The endpoint is looked up again and gets updated if its name
+// or URL differ. If there is none, or more than one, createErr is returned.
+func AdoptConflictingEndpoint(
+	log logr.Logger,
+	c IdentityClient,
+	e Endpoint,
+	createErr error,
+) (string, error) {
+	allEndpoints, err := c.GetServiceEndpoints(log, e.ServiceID)
+	if err != nil {
+		return "", err
+	}
+	registered := FilterEndpoints(allEndpoints, c.GetRegionID(), e.Availability)
+	if len(registered) != 1 {
+		return "", createErr
+	}
+
+	endpoint := registered[0]
+	log.Info(fmt.Sprintf("Endpoint %s %s already exists with ID %s, adopting it", e.Name, e.Availability, endpoint.ID))
+	update, changed := GetEndpointUpdate(endpoint, e)
+	if !changed {
+		return endpoint.ID, nil
+	}
+
+	return c.UpdateEndpoint(log, update, endpoint.ID)
+}
+
+// GetEndpointUpdate - returns the fields owned by the operator, name and URL,
+// of e which differ from the registered endpoint current, and if there are any
+func GetEndpointUpdate(
+	current endpoints.Endpoint,
+	e Endpoint,
+) (Endpoint, bool) {
+	update := Endpoint{
+		Availability: e.Availability,
+	}
+	if current.Name != e.Name {
+		update.Name = e.Name
+	}
+	if !EndpointURLsEqual(current.URL, e.URL) {
+		update.URL = e.URL
+	}
+
+	return update, update.Name != "" || update.URL != ""
+}
+
+// defaultPorts - the ports of the URL schemes which are implied if a URL has
+// no port
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// EndpointURLsEqual - returns true if the endpoint URLs a and b are the same,
+// ignoring a default port, e.g. https://host:443/v3 equals https://host/v3.
+// URLs which do not parse are compared as they are.
+func EndpointURLsEqual(a string, b string) bool {
+	return a == b || canonicalEndpointURL(a) == canonicalEndpointURL(b)
+}
+
+// canonicalEndpointURL - returns rawURL without the default port of its
+// scheme
+func canonicalEndpointURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+		// Hostname strips the brackets of an IPv6 address
+		host := u.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		u.Host = host
+	}
+
+	return u.String()
+}
+
+// UpdateEndpoint - updates the endpoint with endpointID and returns its ID.
+// Only the non empty name and URL of e get sent, all other attributes of the
+// endpoint, also those set by other tools, are left as they are.
+func (c *Client) UpdateEndpoint(
+	log logr.Logger,
+	e Endpoint,
+	endpointID string,
+) (string, error) {
+	updateOpts := endpoints.UpdateOpts{
+		Name: e.Name,
+		URL:  e.URL,
+	}
+
+	endpoint, err := endpoints.Update(c.osclient, endpointID, updateOpts).Extract()
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("Endpoint %s with ID %s updated", e.Availability, endpoint.ID))
+
+	return endpoint.ID, nil
+}
+
+// DeleteEndpoint - deletes all endpoints of the service with the availability
+// of e in the client region, it is ok if there are none
+func (c *Client) DeleteEndpoint(
+	log logr.Logger,
+	e Endpoint,
+) error {
+	allEndpoints, err := c.GetEndpoints(log, e.ServiceID, e.Availability)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range allEndpoints {
+		err = endpoints.Delete(c.osclient, endpoint.ID).ExtractErr()
+		if err != nil {
+			if _, ok := err.(gophercloud.ErrDefault404); !ok {
+				return err
+			}
+		}
+		log.Info(fmt.Sprintf("Endpoint %s %s with ID %s deleted", e.Name, e.Availability, endpoint.ID))
+	}
+
+	return nil
+}
+
+// DeleteEndpointByID - deletes the endpoint with endpointID, it is ok if it
+// got deleted already
+func (c *Client) DeleteEndpointByID(
+	log logr.Logger,
+	endpointID string,
+) error {
+	err := endpoints.Delete(c.osclient, endpointID).ExtractErr()
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			log.Info(fmt.Sprintf("Endpoint with ID %s already deleted", endpointID))
+			return nil
+		}
+		return err
+	}
+	log.Info(fmt.Sprintf("Endpoint with ID %s deleted", endpointID))
+
+	return nil
+}
+
+// SetServiceEndpointsEnabled - enables or disables all endpoints of the
+// service in all regions. Only the endpoints whose enabled state differs get
+// updated. The gophercloud endpoint types do not carry the enabled attribute,
+// so the requests are done directly.
+func (c *Client) SetServiceEndpointsEnabled(
+	log logr.Logger,
+	serviceID string,
+	enabled bool,
+) error {
+	endpointsEnabled, err := c.GetServiceEndpointsEnabled(log, serviceID)
+	if err != nil {
+		return err
+	}
+
+	for endpointID, endpointEnabled := range endpointsEnabled {
+		if endpointEnabled == enabled {
+			continue
+		}
+
+		err = c.SetEndpointEnabled(log, endpointID, enabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetServiceEndpointsEnabled - returns the enabled state of the endpoints of
+// the service in all regions with the endpoint ID as index
+func (c *Client) GetServiceEndpointsEnabled(
+	log logr.Logger,
+	serviceID string,
+) (map[string]bool, error) {
+	var list struct {
+		Endpoints []struct {
+			ID      string `json:"id"`
+			Enabled bool   `json:"enabled"`
+		} `json:"endpoints"`
+	}
+
+	url := c.osclient.ServiceURL("endpoints") + "?service_id=" + serviceID
+	_, err := c.osclient.Get(url, &list, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	endpointsEnabled := make(map[string]bool, len(list.Endpoints))
+	for _, e := range list.Endpoints {
+		endpointsEnabled[e.ID] = e.Enabled
+	}
+
+	return endpointsEnabled, nil
+}
+
+// SetEndpointEnabled - enables or disables the endpoint with endpointID
+func (c *Client) SetEndpointEnabled(
+	log logr.Logger,
+	endpointID string,
+	enabled bool,
+) error {
+	body := map[string]interface{}{
+		"endpoint": map[string]interface{}{
+			"enabled": enabled,
+		},
+	}
+	_, err := c.osclient.Patch(c.osclient.ServiceURL("endpoints", endpointID), body, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Endpoint with ID %s set enabled %t", endpointID, enabled))
+
+	return nil
+}
+
+// AddEndpointToProject - associates the endpoint with the project using the
+// endpoint filter extension, it is ok if the association already exists
+func (c *Client) AddEndpointToProject(
+	log logr.Logger,
+	projectID string,
+	endpointID string,
+) error {
+	err := projectendpoints.Create(c.osclient, projectID, endpointID).ExtractErr()
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Endpoint with ID %s associated with project %s", endpointID, projectID))
+
+	return nil
+}
+
+// RemoveEndpointFromProject - removes the association of the endpoint with
+// the project, it is ok if it does not exist
+func (c *Client) RemoveEndpointFromProject(
+	log logr.Logger,
+	projectID string,
+	endpointID string,
+) error {
+	err := projectendpoints.Delete(c.osclient, projectID, endpointID).ExtractErr()
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			return err
+		}
+	}
+	log.Info(fmt.Sprintf("Endpoint with ID %s removed from project %s", endpointID, projectID))
+
+	return nil
+}
@@ -0,0 +1,265 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/endpoints"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+const placementEndpoint = `
+{
+    "id": "5678",
+    "interface": "public",
+    "name": "placement",
+    "region": "RegionOne",
+    "region_id": "RegionOne",
+    "service_id": "1234",
+    "url": "https://placement.example.com"
+}
+`
+
+func TestGetEndpoint(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/endpoints/5678", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestHeader(t, r, "X-Auth-Token", fake.TokenID)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"endpoint": %s}`, placementEndpoint)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	endpoint, err := c.GetEndpoint(logr.Discard(), "5678")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "5678", endpoint.ID)
+	th.AssertEquals(t, "1234", endpoint.ServiceID)
+	th.AssertEquals(t, gophercloud.AvailabilityPublic, endpoint.Availability)
+	th.AssertEquals(t, "https://placement.example.com", endpoint.URL)
+}
+
+func TestDeleteEndpointByID(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	deleted := false
+	th.Mux.HandleFunc("/endpoints/5678", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "DELETE")
+		if deleted {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	th.AssertNoErr(t, c.DeleteEndpointByID(logr.Discard(), "5678"))
+	th.AssertEquals(t, true, deleted)
+
+	// deleted out-of-band
+	th.AssertNoErr(t, c.DeleteEndpointByID(logr.Discard(), "5678"))
+}
+
+func TestUpdateEndpointOnlyOwnedFields(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/endpoints/5678", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		// interface, region, service_id and custom attributes are not sent
+		th.TestJSONRequest(t, r, `{"endpoint": {"url": "https://placement.example.org"}}`)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"endpoint": %s}`, placementEndpoint)
+	})
+
+	current := endpoints.Endpoint{
+		ID:           "5678",
+		Name:         "placement",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.com",
+	}
+	update, changed := GetEndpointUpdate(current, Endpoint{
+		Name:         "placement",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.org",
+	})
+	th.AssertEquals(t, true, changed)
+
+	c := &Client{osclient: fake.ServiceClient(), region: "RegionOne"}
+	_, err := c.UpdateEndpoint(logr.Discard(), update, current.ID)
+	th.AssertNoErr(t, err)
+
+	_, changed = GetEndpointUpdate(current, Endpoint{
+		Name:         "placement",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.com",
+	})
+	th.AssertEquals(t, false, changed)
+}
+
+func TestEndpointURLsEqual(t *testing.T) {
+	th.AssertEquals(t, true, EndpointURLsEqual("https://placement.example.com:443/v3", "https://placement.example.com/v3"))
+	th.AssertEquals(t, true, EndpointURLsEqual("http://placement.example.com/v3", "http://placement.example.com:80/v3"))
+	th.AssertEquals(t, true, EndpointURLsEqual("https://[fd00::1]:443", "https://[fd00::1]"))
+	th.AssertEquals(t, false, EndpointURLsEqual("http://placement.example.com:443/v3", "http://placement.example.com/v3"))
+	th.AssertEquals(t, false, EndpointURLsEqual("https://placement.example.com:8778", "https://placement.example.com"))
+
+	current := endpoints.Endpoint{
+		Name:         "placement",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.com:443",
+	}
+	_, changed := GetEndpointUpdate(current, Endpoint{
+		Name:         "placement",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.com",
+	})
+	th.AssertEquals(t, false, changed)
+}
+
+func TestGetUndeclaredEndpoints(t *testing.T) {
+	allEndpoints := []endpoints.Endpoint{
+		{ID: "1", Availability: gophercloud.AvailabilityPublic, Region: "RegionOne", URL: "https://placement.example.com"},
+		{ID: "2", Availability: gophercloud.AvailabilityAdmin, Region: "RegionOne", URL: "http://placement.admin:8778"},
+		{ID: "3", Availability: gophercloud.AvailabilityPublic, Region: "RegionTwo", URL: "https://placement.two.example.com"},
+	}
+
+	undeclared := GetUndeclaredEndpoints(allEndpoints, "RegionOne", map[string]string{"public": "https://placement.example.com"})
+	th.AssertDeepEquals(t, []string{
+		"RegionOne/admin http://placement.admin:8778 (2)",
+		"RegionTwo/public https://placement.two.example.com (3)",
+	}, undeclared)
+}
+
+func TestFilterEndpoints(t *testing.T) {
+	allEndpoints := []endpoints.Endpoint{
+		{ID: "1", Availability: gophercloud.AvailabilityPublic, Region: "RegionOne"},
+		{ID: "2", Availability: gophercloud.AvailabilityAdmin, Region: "RegionOne"},
+		{ID: "3", Availability: gophercloud.AvailabilityPublic, Region: "RegionTwo"},
+	}
+
+	th.AssertDeepEquals(t, []endpoints.Endpoint{allEndpoints[0]},
+		FilterEndpoints(allEndpoints, "RegionOne", gophercloud.AvailabilityPublic))
+	th.AssertDeepEquals(t, []endpoints.Endpoint{},
+		FilterEndpoints(allEndpoints, "RegionTwo", gophercloud.AvailabilityInternal))
+}
+
+func TestSetServiceEndpointsEnabled(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestFormValues(t, r, map[string]string{"service_id": "1234"})
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"endpoints": [{"id": "5678", "enabled": true}, {"id": "9012", "enabled": false}]}`)
+	})
+	// only the enabled endpoint gets updated
+	th.Mux.HandleFunc("/endpoints/5678", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"endpoint": {"enabled": false}}`)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"endpoint": %s}`, placementEndpoint)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	th.AssertNoErr(t, c.SetServiceEndpointsEnabled(logr.Discard(), "1234", false))
+}
+
+func TestGetServiceEndpointsEnabled(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestFormValues(t, r, map[string]string{"service_id": "1234"})
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"endpoints": [{"id": "5678", "enabled": true}, {"id": "9012", "enabled": false}]}`)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	endpointsEnabled, err := c.GetServiceEndpointsEnabled(logr.Discard(), "1234")
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, map[string]bool{"5678": true, "9012": false}, endpointsEnabled)
+}
+
+func TestAdoptConflictingEndpoint(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// the public endpoint got registered by a concurrent reconcile
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "1234", r.URL.Query().Get("service_id"))
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, placementEndpoints)
+	})
+	updated := false
+	th.Mux.HandleFunc("/endpoints/e1", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"endpoint": {"name": "placement", "url": "https://placement.example.org"}}`)
+		updated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"endpoint": {"id": "e1"}}`)
+	})
+
+	c := &Client{osclient: fake.ServiceClient(), regionID: "RegionOne"}
+	e := Endpoint{
+		Name:         "placement",
+		ServiceID:    "1234",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.org",
+	}
+	_, err := c.CreateEndpoint(logr.Discard(), e)
+	th.AssertEquals(t, true, IsConflict(err))
+
+	endpointID, err := AdoptConflictingEndpoint(logr.Discard(), c, e, err)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "e1", endpointID)
+	th.AssertEquals(t, true, updated)
+
+	// there is no registered admin endpoint, the conflict is returned
+	e.Availability = gophercloud.AvailabilityAdmin
+	_, err = c.CreateEndpoint(logr.Discard(), e)
+	_, err = AdoptConflictingEndpoint(logr.Discard(), c, e, err)
+	th.AssertEquals(t, true, IsConflict(err))
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// EndpointGroup - an endpoint group of the endpoint filter extension, the
+// endpoints matching all Filters, e.g. service_id, interface and region_id,
+// belong to it
+type EndpointGroup struct {
+	ID          string            `json:"id,omitempty"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Filters     map[string]string `json:"filters"`
+}
+
+// GetEndpointGroup - returns the endpoint group with name, nil if there is
+// none. The endpoint group API is not provided by gophercloud, so the requests
+// of the endpoint groups are done directly.
+func (c *Client) GetEndpointGroup(
+	log logr.Logger,
+	name string,
+) (*EndpointGroup, error) {
+	var r struct {
+		EndpointGroups []EndpointGroup `json:"endpoint_groups"`
+	}
+	_, err := c.osclient.Get(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups")+"?name="+url.QueryEscape(name), &r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the name filter is not supported by all keystone versions
+	var group *EndpointGroup
+	for i := range r.EndpointGroups {
+		if r.EndpointGroups[i].Name != name {
+			continue
+		}
+		if group != nil {
+			return nil, fmt.Errorf("multiple endpoint groups named %s", name)
+		}
+		group = &r.EndpointGroups[i]
+	}
+
+	return group, nil
+}
+
+// CreateEndpointGroup - creates the endpoint group and returns its ID
+func (c *Client) CreateEndpointGroup(
+	log logr.Logger,
+	g EndpointGroup,
+) (string, error) {
+	var r struct {
+		EndpointGroup EndpointGroup `json:"endpoint_group"`
+	}
+	g.ID = ""
+	_, err := c.osclient.Post(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups"),
+		map[string]interface{}{"endpoint_group": g}, &r, &gophercloud.RequestOpts{
+			OkCodes: []int{201},
+		})
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("Endpoint group %s created with ID %s", g.Name, r.EndpointGroup.ID))
+
+	return r.EndpointGroup.ID, nil
+}
+
+// UpdateEndpointGroup - updates the description and filters of the endpoint
+// group with groupID
+func (c *Client) UpdateEndpointGroup(
+	log logr.Logger,
+	g EndpointGroup,
+	groupID string,
+) error {
+	g.ID = ""
+	_, err := c.osclient.Patch(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups", groupID),
+		map[string]interface{}{"endpoint_group": g}, nil, &gophercloud.RequestOpts{
+			OkCodes: []int{200},
+		})
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Endpoint group %s with ID %s updated", g.Name, groupID))
+
+	return nil
+}
+
+// DeleteEndpointGroup - deletes the endpoint group with groupID, it is ok if
+// it does not exist
+func (c *Client) DeleteEndpointGroup(
+	log logr.Logger,
+	groupID string,
+) error {
+	_, err := c.osclient.Delete(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups", groupID), nil)
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			return err
+		}
+	}
+	log.Info(fmt.Sprintf("Endpoint group with ID %s deleted", groupID))
+
+	return nil
+}
+
+// GetEndpointGroupProjectIDs - returns the IDs of the projects the endpoint
+// group with groupID is associated with
+func (c *Client) GetEndpointGroupProjectIDs(
+	log logr.Logger,
+	groupID string,
+) ([]string, error) {
+	var r struct {
+		Projects []struct {
+			ID string `json:"id"`
+		} `json:"projects"`
+	}
+	_, err := c.osclient.Get(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups", groupID, "projects"), &r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDs := []string{}
+	for _, p := range r.Projects {
+		projectIDs = append(projectIDs, p.ID)
+	}
+
+	return projectIDs, nil
+}
+
+// AddEndpointGroupToProject - associates the endpoint group with the project,
+// it is ok if the association already exists
+func (c *Client) AddEndpointGroupToProject(
+	log logr.Logger,
+	groupID string,
+	projectID string,
+) error {
+	_, err := c.osclient.Put(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups", groupID, "projects", projectID), nil, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{204},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Endpoint group with ID %s associated with project %s", groupID, projectID))
+
+	return nil
+}
+
+// RemoveEndpointGroupFromProject - removes the association of the endpoint
+// group with the project, it is ok if it does not exist
+func (c *Client) RemoveEndpointGroupFromProject(
+	log logr.Logger,
+	groupID string,
+	projectID string,
+) error {
+	_, err := c.osclient.Delete(c.osclient.ServiceURL("OS-EP-FILTER", "endpoint_groups", groupID, "projects", projectID), nil)
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			return err
+		}
+	}
+	log.Info(fmt.Sprintf("Endpoint group with ID %s removed from project %s", groupID, projectID))
+
+	return nil
+}
+
+// ReconcileEndpointGroup - creates the endpoint group g, or updates its
+// description and filters, and associates it with the projects with
+// projectIDs. Of the other associations only the ones in managedProjectIDs,
+// which got created by a previous reconcile, are removed. Returns the ID of
+// the endpoint group.
+func ReconcileEndpointGroup(
+	log logr.Logger,
+	os IdentityClient,
+	g EndpointGroup,
+	projectIDs []string,
+	managedProjectIDs []string,
+) (string, error) {
+	group, err := os.GetEndpointGroup(log, g.Name)
+	if err != nil {
+		return "", err
+	}
+
+	groupID := ""
+	current := sets.NewString()
+	if group == nil {
+		groupID, err = os.CreateEndpointGroup(log, g)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		groupID = group.ID
+		if group.Description != g.Description || !reflect.DeepEqual(group.Filters, g.Filters) {
+			err = os.UpdateEndpointGroup(log, g, groupID)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		associated, err := os.GetEndpointGroupProjectIDs(log, groupID)
+		if err != nil {
+			return "", err
+		}
+		current.Insert(associated...)
+	}
+
+	desired := sets.NewString(projectIDs...)
+	for _, projectID := range desired.Difference(current).List() {
+		err = os.AddEndpointGroupToProject(log, groupID, projectID)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, projectID := range managedProjectIDs {
+		if desired.Has(projectID) || !current.Has(projectID) {
+			continue
+		}
+		err = os.RemoveEndpointGroupFromProject(log, groupID, projectID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return groupID, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+func TestReconcileEndpointGroup(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/OS-EP-FILTER/endpoint_groups", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "placement-public", r.URL.Query().Get("name"))
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"endpoint_groups": [{"id": "g1", "name": "placement-public", "description": "", "filters": {"service_id": "1234"}}]}`)
+	})
+
+	// the interface filter is missing
+	updated := false
+	th.Mux.HandleFunc("/OS-EP-FILTER/endpoint_groups/g1", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"endpoint_group": {"name": "placement-public", "description": "", "filters": {"service_id": "1234", "interface": "public"}}}`)
+		updated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"endpoint_group": {"id": "g1"}}`)
+	})
+
+	// p1 got associated out-of-band, p2 by a previous reconcile
+	th.Mux.HandleFunc("/OS-EP-FILTER/endpoint_groups/g1/projects", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"projects": [{"id": "p1"}, {"id": "p2"}]}`)
+	})
+	requests := []string{}
+	for _, projectID := range []string{"p1", "p2", "p3"} {
+		projectID := projectID
+		th.Mux.HandleFunc("/OS-EP-FILTER/endpoint_groups/g1/projects/"+projectID, func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+projectID)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	c := &Client{osclient: fake.ServiceClient()}
+	groupID, err := ReconcileEndpointGroup(
+		logr.Discard(),
+		c,
+		EndpointGroup{Name: "placement-public", Filters: map[string]string{"service_id": "1234", "interface": "public"}},
+		[]string{"p3"},
+		[]string{"p2"},
+	)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "g1", groupID)
+	th.AssertEquals(t, true, updated)
+	th.AssertDeepEquals(t, []string{"PUT p3", "DELETE p2"}, requests)
+}
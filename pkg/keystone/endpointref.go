@@ -0,0 +1,141 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	routev1 "github.com/openshift/api/route/v1"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ErrEndpointURLRefNotReady - the referenced Route or Service does not provide
+// an URL yet
+var ErrEndpointURLRefNotReady = errors.New("endpoint URL reference not ready")
+
+// ErrURLVariableNotFound - an endpoint URL references a variable which is
+// not defined
+var ErrURLVariableNotFound = errors.New("endpoint URL variable not found")
+
+// urlVariableRef - a $(KEY) reference in an endpoint URL
+var urlVariableRef = regexp.MustCompile(`\$\(([A-Za-z_][A-Za-z0-9_.-]*)\)`)
+
+// HasURLVariables - returns true if endpointURL references a variable
+func HasURLVariables(endpointURL string) bool {
+	return urlVariableRef.MatchString(endpointURL)
+}
+
+// ExpandURLVariables - substitutes the $(KEY) references in endpointURL with
+// the value of KEY in variables, or else with the value lookup returns for it
+func ExpandURLVariables(
+	endpointURL string,
+	variables map[string]string,
+	lookup func(string) (string, bool),
+) (string, error) {
+	var err error
+	expanded := urlVariableRef.ReplaceAllStringFunc(endpointURL, func(ref string) string {
+		key := urlVariableRef.FindStringSubmatch(ref)[1]
+		if value, ok := variables[key]; ok {
+			return value
+		}
+		if value, ok := lookup(key); ok {
+			return value
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: %s", ErrURLVariableNotFound, key)
+		}
+		return ref
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return expanded, nil
+}
+
+// GetRouteURL - returns the URL of an endpoint referencing route, the host of
+// the route or the first host it got admitted with. The route is not ready
+// before a router admitted it.
+func GetRouteURL(route *routev1.Route, ref keystonev1.EndpointURLRef) (string, error) {
+	host := ""
+	for _, ingress := range route.Status.Ingress {
+		if isRouteIngressAdmitted(ingress) {
+			host = ingress.Host
+			break
+		}
+	}
+	if host == "" {
+		return "", fmt.Errorf("%w: route %s is not admitted", ErrEndpointURLRefNotReady, route.Name)
+	}
+	if route.Spec.Host != "" {
+		host = route.Spec.Host
+	}
+
+	scheme := ref.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if route.Spec.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	return endpointURL(scheme, host, ref.Path), nil
+}
+
+// isRouteIngressAdmitted - returns true if the router of ingress admitted the
+// route
+func isRouteIngressAdmitted(ingress routev1.RouteIngress) bool {
+	for _, condition := range ingress.Conditions {
+		if condition.Type == routev1.RouteAdmitted {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// GetServiceURL - returns the cluster internal URL of an endpoint referencing
+// service, using ref.Port or the first port of the service
+func GetServiceURL(service *corev1.Service, ref keystonev1.EndpointURLRef) (string, error) {
+	port := ref.Port
+	if port == 0 {
+		if len(service.Spec.Ports) == 0 {
+			return "", fmt.Errorf("%w: service %s has no ports", ErrEndpointURLRefNotReady, service.Name)
+		}
+		port = service.Spec.Ports[0].Port
+	}
+
+	scheme := ref.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	return endpointURL(scheme, fmt.Sprintf("%s.%s.svc:%d", service.Name, service.Namespace, port), ref.Path), nil
+}
+
+func endpointURL(scheme string, host string, path string) string {
+	u := url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   path,
+	}
+
+	return u.String()
+}
@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+	routev1 "github.com/openshift/api/route/v1"
+	keystonev1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetRouteURL(t *testing.T) {
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: "nova-public", Namespace: "openstack"},
+	}
+	ref := keystonev1.EndpointURLRef{Kind: keystonev1.EndpointURLRefKindRoute, Name: "nova-public", Path: "/v2.1"}
+
+	_, err := GetRouteURL(route, ref)
+	th.AssertEquals(t, true, errors.Is(err, ErrEndpointURLRefNotReady))
+
+	// the route is not admitted yet
+	route.Status.Ingress = []routev1.RouteIngress{{Host: "nova-public.apps.example.com"}}
+	_, err = GetRouteURL(route, ref)
+	th.AssertEquals(t, true, errors.Is(err, ErrEndpointURLRefNotReady))
+
+	// the first router rejected the route, the second admitted it
+	route.Status.Ingress = []routev1.RouteIngress{
+		{
+			Host:       "nova-public.apps.other.com",
+			Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: corev1.ConditionFalse}},
+		},
+		{
+			Host:       "nova-public.apps.example.com",
+			Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue}},
+		},
+	}
+	u, err := GetRouteURL(route, ref)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "http://nova-public.apps.example.com/v2.1", u)
+
+	route.Spec.Host = "nova.example.com"
+	route.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge}
+	u, err = GetRouteURL(route, ref)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "https://nova.example.com/v2.1", u)
+}
+
+func TestGetServiceURL(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "nova-internal", Namespace: "openstack"},
+	}
+	ref := keystonev1.EndpointURLRef{Kind: keystonev1.EndpointURLRefKindService, Name: "nova-internal"}
+
+	_, err := GetServiceURL(service, ref)
+	th.AssertEquals(t, true, errors.Is(err, ErrEndpointURLRefNotReady))
+
+	service.Spec.Ports = []corev1.ServicePort{{Port: 8774}, {Port: 8775}}
+	u, err := GetServiceURL(service, ref)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "http://nova-internal.openstack.svc:8774", u)
+
+	ref.Port = 8775
+	ref.Scheme = "https"
+	ref.Path = "v2.1"
+	u, err = GetServiceURL(service, ref)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "https://nova-internal.openstack.svc:8775/v2.1", u)
+}
+
+func TestExpandURLVariables(t *testing.T) {
+	variables := map[string]string{"KEYSTONE_PUBLIC_HOST": "keystone.example.com"}
+	env := func(key string) (string, bool) {
+		if key == "PORT" {
+			return "5000", true
+		}
+		return "", false
+	}
+
+	th.AssertEquals(t, true, HasURLVariables("https://$(KEYSTONE_PUBLIC_HOST):$(PORT)"))
+	th.AssertEquals(t, false, HasURLVariables("https://keystone.example.com"))
+
+	u, err := ExpandURLVariables("https://$(KEYSTONE_PUBLIC_HOST):$(PORT)/v3", variables, env)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "https://keystone.example.com:5000/v3", u)
+
+	_, err = ExpandURLVariables("https://$(KEYSTONE_INTERNAL_HOST)", variables, env)
+	th.AssertEquals(t, true, errors.Is(err, ErrURLVariableNotFound))
+}
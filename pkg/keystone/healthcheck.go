@@ -0,0 +1,69 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HealthCheckTimeout - timeout of a single endpoint health check request
+var HealthCheckTimeout = 5 * time.Second
+
+// HealthCheckURL - returns endpointURL with path appended, separated by a
+// single slash
+func HealthCheckURL(endpointURL string, path string) string {
+	if path == "" {
+		return endpointURL
+	}
+
+	return strings.TrimSuffix(endpointURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// CheckEndpointHealth - requests endpointURL with path appended using rt, or
+// http.DefaultTransport if nil, and returns an error if the request fails or
+// the response status differs from expectedStatus. The response body is not
+// read.
+func CheckEndpointHealth(
+	ctx context.Context,
+	rt http.RoundTripper,
+	endpointURL string,
+	path string,
+	expectedStatus int,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, HealthCheckURL(endpointURL, path), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("%s returned status %d, expected %d", req.URL, resp.StatusCode, expectedStatus)
+	}
+
+	return nil
+}
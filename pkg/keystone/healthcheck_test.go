@@ -0,0 +1,48 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestHealthCheckURL(t *testing.T) {
+	th.AssertEquals(t, "http://nova:8774/v2.1", HealthCheckURL("http://nova:8774/v2.1", ""))
+	th.AssertEquals(t, "http://nova:8774/healthcheck", HealthCheckURL("http://nova:8774/", "/healthcheck"))
+	th.AssertEquals(t, "http://nova:8774/v2.1/healthcheck", HealthCheckURL("http://nova:8774/v2.1", "healthcheck"))
+}
+
+func TestCheckEndpointHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthcheck" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	th.AssertNoErr(t, CheckEndpointHealth(context.TODO(), nil, server.URL, "/healthcheck", http.StatusOK))
+	th.AssertNoErr(t, CheckEndpointHealth(context.TODO(), nil, server.URL, "", http.StatusNotFound))
+
+	err := CheckEndpointHealth(context.TODO(), nil, server.URL, "", http.StatusOK)
+	th.AssertEquals(t, true, err != nil)
+}
@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/endpoints"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/regions"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/services"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// IdentityClient - the keystone operations used by the service, endpoint and
+// role reconcilers. Client implements it on top of gophercloud, tests can provide
+// a fake instead.
+type IdentityClient interface {
+	GetRegion() string
+	GetRegionID() string
+	GetAuthURL() string
+	GetIdentityEndpoint() string
+	GetClockSkew() time.Duration
+	GetAPIVersion(log logr.Logger) (string, error)
+
+	GetService(log logr.Logger, serviceType string, serviceName string) (*services.Service, error)
+	GetServiceByID(log logr.Logger, serviceID string) (*services.Service, error)
+	GetServicesByType(log logr.Logger, serviceType string) ([]services.Service, error)
+	CreateService(log logr.Logger, s Service) (string, error)
+	UpdateService(log logr.Logger, s Service, serviceID string) error
+	DeleteService(log logr.Logger, serviceID string) error
+	GetDomainID(log logr.Logger, domainName string) (string, error)
+
+	GetEndpoints(log logr.Logger, serviceID string, availability gophercloud.Availability) ([]endpoints.Endpoint, error)
+	GetServiceEndpoints(log logr.Logger, serviceID string) ([]endpoints.Endpoint, error)
+	GetEndpoint(log logr.Logger, endpointID string) (*endpoints.Endpoint, error)
+	GetCatalogEndpoints(log logr.Logger, serviceID string) (map[string]string, error)
+	CreateEndpoint(log logr.Logger, e Endpoint) (string, error)
+	UpdateEndpoint(log logr.Logger, e Endpoint, endpointID string) (string, error)
+	SetServiceEndpointsEnabled(log logr.Logger, serviceID string, enabled bool) error
+	GetServiceEndpointsEnabled(log logr.Logger, serviceID string) (map[string]bool, error)
+	SetEndpointEnabled(log logr.Logger, endpointID string, enabled bool) error
+	DeleteEndpoint(log logr.Logger, e Endpoint) error
+	DeleteEndpointByID(log logr.Logger, endpointID string) error
+	AddEndpointToProject(log logr.Logger, projectID string, endpointID string) error
+	RemoveEndpointFromProject(log logr.Logger, projectID string, endpointID string) error
+	GetEndpointGroup(log logr.Logger, name string) (*EndpointGroup, error)
+	CreateEndpointGroup(log logr.Logger, g EndpointGroup) (string, error)
+	UpdateEndpointGroup(log logr.Logger, g EndpointGroup, groupID string) error
+	DeleteEndpointGroup(log logr.Logger, groupID string) error
+	GetEndpointGroupProjectIDs(log logr.Logger, groupID string) ([]string, error)
+	AddEndpointGroupToProject(log logr.Logger, groupID string, projectID string) error
+	RemoveEndpointGroupFromProject(log logr.Logger, groupID string, projectID string) error
+
+	FindRegion(log logr.Logger, regionID string) (*regions.Region, error)
+	CreateRegion(log logr.Logger, r Region) error
+	UpdateRegion(log logr.Logger, r Region) error
+
+	CreateProject(log logr.Logger, p Project) (string, error)
+	CreateRole(log logr.Logger, roleName string) (string, error)
+	GetImpliedRoleIDs(log logr.Logger, priorRoleID string) ([]string, error)
+	CreateImpliedRole(log logr.Logger, priorRoleID string, impliedRoleID string) error
+	DeleteImpliedRole(log logr.Logger, priorRoleID string, impliedRoleID string) error
+	CreateUser(log logr.Logger, u User) (string, error)
+	AssignUserRole(log logr.Logger, roleName string, userID string, projectID string) error
+	DeleteUser(log logr.Logger, userName string) error
+}
+
+var _ IdentityClient = &Client{}
+
+// IdentityClientFactory - returns an admin IdentityClient for the keystoneAPI
+// instance. A non empty ctrl.Result is returned if the client can not yet be
+// created, e.g. because the admin password secret does not exist.
+type IdentityClientFactory func(
+	ctx context.Context,
+	h *helper.Helper,
+	keystoneAPI *keystonev1beta1.KeystoneAPI,
+) (IdentityClient, ctrl.Result, error)
+
+// NewAdminIdentityClientFactory - returns an IdentityClientFactory returning
+// the gophercloud based admin Client created with opts
+func NewAdminIdentityClientFactory(opts ClientOptions) IdentityClientFactory {
+	return func(
+		ctx context.Context,
+		h *helper.Helper,
+		keystoneAPI *keystonev1beta1.KeystoneAPI,
+	) (IdentityClient, ctrl.Result, error) {
+		c, ctrlResult, err := GetAdminClient(ctx, h, keystoneAPI, opts)
+		// don't return a nil *Client wrapped in a non nil interface
+		if c == nil {
+			return nil, ctrlResult, err
+		}
+
+		return c, ctrlResult, err
+	}
+}
@@ -0,0 +1,128 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/services"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var invalidNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// ListServices - returns all services registered in keystone
+func (c *Client) ListServices(
+	log logr.Logger,
+) ([]services.Service, error) {
+	allPages, err := services.List(c.osclient, services.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return services.ExtractServices(allPages)
+}
+
+// ImportCatalog - returns a KeystoneService and KeystoneEndpoint in namespace
+// for every service registered in keystone, to adopt an existing catalog. The
+// identity service is skipped as it is managed by the KeystoneAPI. The
+// endpoints of the client region get adopted using AdoptEndpointIDs.
+// ServiceUser, Secret and PasswordSelector of the KeystoneServices are not
+// known to keystone and have to be filled in before applying them.
+func ImportCatalog(
+	log logr.Logger,
+	c *Client,
+	namespace string,
+) ([]keystonev1beta1.KeystoneService, []keystonev1beta1.KeystoneEndpoint, error) {
+	allServices, err := c.ListServices(log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ksServices := []keystonev1beta1.KeystoneService{}
+	ksEndpoints := []keystonev1beta1.KeystoneEndpoint{}
+	for _, s := range allServices {
+		if s.Type == "identity" {
+			continue
+		}
+
+		serviceName, _ := s.Extra["name"].(string)
+		description, _ := s.Extra["description"].(string)
+		name := importName(serviceName, s.Type)
+		ksServices = append(ksServices, keystonev1beta1.KeystoneService{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: keystonev1beta1.GroupVersion.String(),
+				Kind:       "KeystoneService",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: keystonev1beta1.KeystoneServiceSpec{
+				ServiceType:        s.Type,
+				ServiceName:        serviceName,
+				ServiceDescription: description,
+				Enabled:            s.Enabled,
+			},
+			Status: keystonev1beta1.KeystoneServiceStatus{
+				ServiceID: s.ID,
+			},
+		})
+
+		allEndpoints, err := c.GetEndpoints(log, s.ID, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(allEndpoints) == 0 {
+			continue
+		}
+
+		ksEndpoint := keystonev1beta1.KeystoneEndpoint{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: keystonev1beta1.GroupVersion.String(),
+				Kind:       "KeystoneEndpoint",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: keystonev1beta1.KeystoneEndpointSpec{
+				ServiceName:      name,
+				Endpoints:        map[string]string{},
+				AdoptEndpointIDs: map[string]string{},
+			},
+		}
+		for _, e := range allEndpoints {
+			ksEndpoint.Spec.Endpoints[string(e.Availability)] = e.URL
+			ksEndpoint.Spec.AdoptEndpointIDs[string(e.Availability)] = e.ID
+		}
+		ksEndpoints = append(ksEndpoints, ksEndpoint)
+	}
+
+	return ksServices, ksEndpoints, nil
+}
+
+// importName - returns a valid object name for the imported service
+func importName(name string, serviceType string) string {
+	if name == "" {
+		name = serviceType
+	}
+
+	return strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+const identityService = `
+{
+    "id": "1111",
+    "type": "identity",
+    "enabled": true,
+    "name": "keystone"
+}
+`
+
+const placementEndpoints = `
+{
+    "links": {
+        "next": null,
+        "previous": null
+    },
+    "endpoints": [
+        {
+            "id": "e1",
+            "interface": "public",
+            "region": "RegionOne",
+            "service_id": "1234",
+            "url": "https://placement.example.com"
+        },
+        {
+            "id": "e2",
+            "interface": "internal",
+            "region": "RegionOne",
+            "service_id": "1234",
+            "url": "http://placement.internal:8778"
+        }
+    ]
+}
+`
+
+func TestImportCatalog(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, serviceListOutput, identityService+","+fmt.Sprintf(placementService, true))
+	})
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "1234", r.URL.Query().Get("service_id"))
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, placementEndpoints)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	ksServices, ksEndpoints, err := ImportCatalog(logr.Discard(), c, "openstack")
+	th.AssertNoErr(t, err)
+
+	th.AssertEquals(t, 1, len(ksServices))
+	th.AssertEquals(t, "placement", ksServices[0].Name)
+	th.AssertEquals(t, "openstack", ksServices[0].Namespace)
+	th.AssertDeepEquals(t, placementSpec, ksServices[0].Spec)
+	th.AssertEquals(t, "1234", ksServices[0].Status.ServiceID)
+
+	th.AssertEquals(t, 1, len(ksEndpoints))
+	th.AssertEquals(t, "placement", ksEndpoints[0].Spec.ServiceName)
+	th.AssertDeepEquals(t, map[string]string{
+		"public":   "https://placement.example.com",
+		"internal": "http://placement.internal:8778",
+	}, ksEndpoints[0].Spec.Endpoints)
+	th.AssertDeepEquals(t, map[string]string{
+		"public":   "e1",
+		"internal": "e2",
+	}, ksEndpoints[0].Spec.AdoptEndpointIDs)
+}
+
+func TestImportName(t *testing.T) {
+	th.AssertEquals(t, "swift-proxy", importName("Swift_Proxy", "object-store"))
+	th.AssertEquals(t, "object-store", importName("", "object-store"))
+}
@@ -0,0 +1,281 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/regions"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+)
+
+// Region - keystone region
+type Region struct {
+	ID             string
+	Description    string
+	ParentRegionID string
+}
+
+// ErrAmbiguousRegion - no region is configured and keystone has more than one
+var ErrAmbiguousRegion = errors.New("no region configured and keystone has multiple regions")
+
+// resolveRegion - returns the ID of the only region of keystone, empty if
+// there is none. Returns ErrAmbiguousRegion if there are multiple regions.
+func resolveRegion(
+	osclient *gophercloud.ServiceClient,
+) (string, error) {
+	allPages, err := regions.List(osclient, regions.ListOpts{}).AllPages()
+	if err != nil {
+		return "", err
+	}
+	allRegions, err := regions.ExtractRegions(allPages)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(allRegions) {
+	case 0:
+		return "", nil
+	case 1:
+		return allRegions[0].ID, nil
+	}
+
+	regionIDs := make([]string, 0, len(allRegions))
+	for _, r := range allRegions {
+		regionIDs = append(regionIDs, r.ID)
+	}
+	sort.Strings(regionIDs)
+
+	return "", fmt.Errorf("%w: %s", ErrAmbiguousRegion, strings.Join(regionIDs, ", "))
+}
+
+// ErrAmbiguousIdentityEndpoint - more than one public identity endpoint of the
+// catalog matches the region
+var ErrAmbiguousIdentityEndpoint = errors.New("multiple identity endpoints match the region")
+
+// getIdentityEndpoints - returns the URLs of the public identity endpoints of
+// catalog in region, in all regions if region is empty. These are the
+// endpoints gophercloud picks the identity endpoint from.
+func getIdentityEndpoints(
+	catalog *tokens.ServiceCatalog,
+	region string,
+) []string {
+	urls := []string{}
+	for _, entry := range catalog.Entries {
+		if entry.Type != "identity" {
+			continue
+		}
+		for _, e := range entry.Endpoints {
+			if e.Interface != string(gophercloud.AvailabilityPublic) {
+				continue
+			}
+			if region == "" || e.Region == region || e.RegionID == region {
+				urls = append(urls, e.URL)
+			}
+		}
+	}
+	sort.Strings(urls)
+
+	return urls
+}
+
+// validateIdentityEndpoint - returns ErrAmbiguousIdentityEndpoint if more than
+// one identity endpoint of the catalog the provider authenticated with matches
+// region, gophercloud would silently use the first one
+func validateIdentityEndpoint(
+	provider *gophercloud.ProviderClient,
+	region string,
+) error {
+	result, ok := provider.GetAuthResult().(tokens.CreateResult)
+	if !ok {
+		return nil
+	}
+	catalog, err := result.ExtractServiceCatalog()
+	if err != nil {
+		return err
+	}
+
+	urls := getIdentityEndpoints(catalog, region)
+	if len(urls) > 1 {
+		return fmt.Errorf("%w %q, set the region of the KeystoneAPI: %s", ErrAmbiguousIdentityEndpoint, region, strings.Join(urls, ", "))
+	}
+
+	return nil
+}
+
+// FindRegion - returns the region with regionID, nil if it does not exist
+func (c *Client) FindRegion(
+	log logr.Logger,
+	regionID string,
+) (*regions.Region, error) {
+	region, err := regions.Get(c.osclient, regionID).Extract()
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return region, nil
+}
+
+// CreateRegion - creates the region
+func (c *Client) CreateRegion(
+	log logr.Logger,
+	r Region,
+) error {
+	_, err := regions.Create(c.osclient, regions.CreateOpts{
+		ID:             r.ID,
+		Description:    r.Description,
+		ParentRegionID: r.ParentRegionID,
+	}).Extract()
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Region %s created with parent region %s", r.ID, r.ParentRegionID))
+
+	return nil
+}
+
+// UpdateRegion - updates the parent region of the region
+func (c *Client) UpdateRegion(
+	log logr.Logger,
+	r Region,
+) error {
+	_, err := regions.Update(c.osclient, r.ID, regions.UpdateOpts{
+		ParentRegionID: r.ParentRegionID,
+	}).Extract()
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Region %s updated with parent region %s", r.ID, r.ParentRegionID))
+
+	return nil
+}
+
+// ReconcileRegion - makes sure the region exists as a child of its parent
+// region. Returns false if the parent region does not exist yet, in which
+// case nothing gets changed.
+func ReconcileRegion(
+	log logr.Logger,
+	c IdentityClient,
+	r Region,
+) (bool, error) {
+	parent, err := c.FindRegion(log, r.ParentRegionID)
+	if err != nil {
+		return false, err
+	}
+	if parent == nil {
+		log.Info(fmt.Sprintf("Parent region %s of region %s does not exist", r.ParentRegionID, r.ID))
+		return false, nil
+	}
+
+	region, err := c.FindRegion(log, r.ID)
+	if err != nil {
+		return false, err
+	}
+	if region == nil {
+		return true, c.CreateRegion(log, r)
+	}
+	if region.ParentRegionID != r.ParentRegionID {
+		return true, c.UpdateRegion(log, r)
+	}
+
+	return true, nil
+}
+
+// ReconcileRegionHierarchy - makes sure the region r exists as a child of the
+// last of parentRegionIDs, each of which is a child of the one before it. The
+// first one is the root of the hierarchy, its own parent is left as it is.
+// Missing parent regions get created if create is true, otherwise the ID of
+// the first missing one is returned and nothing gets changed below it.
+func ReconcileRegionHierarchy(
+	log logr.Logger,
+	c IdentityClient,
+	r Region,
+	parentRegionIDs []string,
+	create bool,
+) (string, error) {
+	parentRegionID := ""
+	for i, regionID := range parentRegionIDs {
+		region, err := c.FindRegion(log, regionID)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case region == nil && !create:
+			log.Info(fmt.Sprintf("Parent region %s of region %s does not exist", regionID, r.ID))
+			return regionID, nil
+		case region == nil:
+			err = c.CreateRegion(log, Region{ID: regionID, ParentRegionID: parentRegionID})
+		case i > 0 && region.ParentRegionID != parentRegionID:
+			err = c.UpdateRegion(log, Region{ID: regionID, ParentRegionID: parentRegionID})
+		}
+		if err != nil {
+			return "", err
+		}
+		parentRegionID = regionID
+	}
+
+	r.ParentRegionID = parentRegionID
+	ok, err := ReconcileRegion(log, c, r)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		// the parent got deleted in between
+		return parentRegionID, nil
+	}
+
+	return "", nil
+}
+
+// CreateEndpointCreatingRegion - creates the endpoint in the client region.
+// If keystone rejects it because the region does not exist, the region gets
+// created and the endpoint create retried.
+func CreateEndpointCreatingRegion(
+	log logr.Logger,
+	c IdentityClient,
+	e Endpoint,
+) (string, error) {
+	endpointID, err := c.CreateEndpoint(log, e)
+	var err400 gophercloud.ErrDefault400
+	if err == nil || !errors.As(err, &err400) {
+		return endpointID, err
+	}
+
+	region, findErr := c.FindRegion(log, c.GetRegionID())
+	if findErr != nil || region != nil {
+		// not caused by a missing region
+		return "", err
+	}
+
+	err = c.CreateRegion(log, Region{
+		ID:          c.GetRegionID(),
+		Description: c.GetRegion(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return c.CreateEndpoint(log, e)
+}
@@ -0,0 +1,237 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+const regionOutput = `
+{
+    "region": {
+        "id": "%s",
+        "description": "",
+        "parent_region_id": "%s"
+    }
+}
+`
+
+func TestReconcileRegionCreate(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/regions/parent", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, regionOutput, "parent", "")
+	})
+	th.Mux.HandleFunc("/regions/child", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	created := false
+	th.Mux.HandleFunc("/regions", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		th.TestJSONRequest(t, r, `{"region": {"id": "child", "parent_region_id": "parent"}}`)
+		created = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, regionOutput, "child", "parent")
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	ok, err := ReconcileRegion(logr.Discard(), c, Region{ID: "child", ParentRegionID: "parent"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, ok)
+	th.AssertEquals(t, true, created)
+}
+
+func TestReconcileRegionParentMissing(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/regions/parent", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	ok, err := ReconcileRegion(logr.Discard(), c, Region{ID: "child", ParentRegionID: "parent"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, ok)
+}
+
+func TestReconcileRegionHierarchy(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// the root exists with a parent of its own, the intermediate region is missing
+	th.Mux.HandleFunc("/regions/europe", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, regionOutput, "europe", "world")
+	})
+	intermediate := false
+	th.Mux.HandleFunc("/regions/germany", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		if !intermediate {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, regionOutput, "germany", "europe")
+	})
+	th.Mux.HandleFunc("/regions/berlin", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	created := []string{}
+	th.Mux.HandleFunc("/regions", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		if !intermediate {
+			th.TestJSONRequest(t, r, `{"region": {"id": "germany", "parent_region_id": "europe"}}`)
+			intermediate = true
+			created = append(created, "germany")
+		} else {
+			th.TestJSONRequest(t, r, `{"region": {"id": "berlin", "parent_region_id": "germany"}}`)
+			created = append(created, "berlin")
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, regionOutput, created[len(created)-1], "")
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	missing, err := ReconcileRegionHierarchy(logr.Discard(), c, Region{ID: "berlin"}, []string{"europe", "germany"}, false)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "germany", missing)
+	th.AssertEquals(t, 0, len(created))
+
+	missing, err = ReconcileRegionHierarchy(logr.Discard(), c, Region{ID: "berlin"}, []string{"europe", "germany"}, true)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "", missing)
+	th.AssertDeepEquals(t, []string{"germany", "berlin"}, created)
+}
+
+func TestCreateEndpointCreatingRegion(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	creates := 0
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		creates++
+		if creates == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"endpoint": %s}`, placementEndpoint)
+	})
+	th.Mux.HandleFunc("/regions/RegionOne", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	regionCreated := false
+	th.Mux.HandleFunc("/regions", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		th.TestJSONRequest(t, r, `{"region": {"id": "RegionOne", "description": "regionOne"}}`)
+		regionCreated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, regionOutput, "RegionOne", "")
+	})
+
+	c := &Client{osclient: fake.ServiceClient(), region: "regionOne", regionID: "RegionOne"}
+	endpointID, err := CreateEndpointCreatingRegion(logr.Discard(), c, Endpoint{
+		Name:         "placement",
+		ServiceID:    "1234",
+		Availability: gophercloud.AvailabilityPublic,
+		URL:          "https://placement.example.com",
+	})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "5678", endpointID)
+	th.AssertEquals(t, 2, creates)
+	th.AssertEquals(t, true, regionCreated)
+}
+
+func TestResolveRegion(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	regionList := `{"regions": [{"id": "regionOne"}]}`
+	th.Mux.HandleFunc("/regions", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, regionList)
+	})
+
+	region, err := resolveRegion(fake.ServiceClient())
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "regionOne", region)
+
+	regionList = `{"regions": [{"id": "regionTwo"}, {"id": "regionOne"}]}`
+	_, err = resolveRegion(fake.ServiceClient())
+	th.AssertEquals(t, true, errors.Is(err, ErrAmbiguousRegion))
+	th.AssertEquals(t, "no region configured and keystone has multiple regions: regionOne, regionTwo", err.Error())
+}
+
+func TestGetIdentityEndpoints(t *testing.T) {
+	catalog := &tokens.ServiceCatalog{
+		Entries: []tokens.CatalogEntry{
+			{
+				Type: "identity",
+				Endpoints: []tokens.Endpoint{
+					{Interface: "public", Region: "regionOne", RegionID: "regionOne", URL: "https://keystone-one:5000"},
+					{Interface: "internal", Region: "regionOne", RegionID: "regionOne", URL: "http://keystone-one.internal:5000"},
+					{Interface: "public", Region: "regionTwo", RegionID: "regionTwo", URL: "https://keystone-two:5000"},
+				},
+			},
+			{
+				Type: "placement",
+				Endpoints: []tokens.Endpoint{
+					{Interface: "public", Region: "regionOne", RegionID: "regionOne", URL: "https://placement:8778"},
+				},
+			},
+		},
+	}
+
+	th.AssertDeepEquals(t, []string{"https://keystone-one:5000"}, getIdentityEndpoints(catalog, "regionOne"))
+	th.AssertDeepEquals(t, []string{"https://keystone-one:5000", "https://keystone-two:5000"}, getIdentityEndpoints(catalog, ""))
+	th.AssertDeepEquals(t, []string{}, getIdentityEndpoints(catalog, "regionThree"))
+}
@@ -0,0 +1,95 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// RequestIDHeader - header keystone logs as global request ID
+const RequestIDHeader = "X-OpenStack-Request-ID"
+
+// RequestID - request ID sent with every keystone request of a reconcile, to
+// correlate the operator actions with the keystone logs
+type RequestID struct {
+	ID string
+
+	mu      sync.Mutex
+	changed bool
+}
+
+// NewRequestID - returns a new RequestID in the req-<uuid> format keystone
+// accepts as global request ID
+func NewRequestID() *RequestID {
+	return &RequestID{
+		ID: "req-" + string(uuid.NewUUID()),
+	}
+}
+
+// Changed - returns true if a create, update or delete request succeeded
+// using the RequestID
+func (r *RequestID) Changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.changed
+}
+
+type requestIDKey struct{}
+
+// WithRequestID - returns a copy of ctx carrying r
+func WithRequestID(ctx context.Context, r *RequestID) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, r)
+}
+
+// RequestIDFromContext - returns the RequestID of ctx, nil if there is none
+func RequestIDFromContext(ctx context.Context) *RequestID {
+	r, _ := ctx.Value(requestIDKey{}).(*RequestID)
+	return r
+}
+
+// requestIDTransport - sets the request ID header on all requests and records
+// successful create, update and delete requests
+type requestIDTransport struct {
+	rt        http.RoundTripper
+	requestID *RequestID
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, t.requestID.ID)
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	// authentication is a POST, but does not change anything
+	if req.Method != http.MethodGet && req.Method != http.MethodHead &&
+		!strings.HasSuffix(req.URL.Path, "/auth/tokens") &&
+		resp.StatusCode < http.StatusBadRequest {
+		t.requestID.mu.Lock()
+		t.requestID.changed = true
+		t.requestID.mu.Unlock()
+	}
+
+	return resp, nil
+}
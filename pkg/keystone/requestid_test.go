@@ -0,0 +1,69 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestRequestIDTransport(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	requestID := NewRequestID()
+	th.AssertEquals(t, true, strings.HasPrefix(requestID.ID, "req-"))
+
+	th.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		th.TestHeader(t, r, RequestIDHeader, requestID.ID)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	client := &http.Client{
+		Transport: &requestIDTransport{rt: http.DefaultTransport, requestID: requestID},
+	}
+	do := func(method string, path string) {
+		req, err := http.NewRequest(method, th.Endpoint()+path, nil)
+		th.AssertNoErr(t, err)
+		resp, err := client.Do(req)
+		th.AssertNoErr(t, err)
+		resp.Body.Close()
+	}
+
+	do("POST", "v3/auth/tokens")
+	do("GET", "services")
+	do("PATCH", "missing")
+	th.AssertEquals(t, false, requestID.Changed())
+
+	do("POST", "services")
+	th.AssertEquals(t, true, requestID.Changed())
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	th.AssertEquals(t, true, RequestIDFromContext(context.Background()) == nil)
+
+	requestID := NewRequestID()
+	ctx := WithRequestID(context.Background(), requestID)
+	th.AssertEquals(t, requestID, RequestIDFromContext(ctx))
+}
@@ -0,0 +1,133 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+)
+
+// GetImpliedRoleIDs - returns the IDs of the roles the role with priorRoleID
+// implies. The role inference API is not provided by gophercloud, so the
+// request is done directly.
+func (c *Client) GetImpliedRoleIDs(
+	log logr.Logger,
+	priorRoleID string,
+) ([]string, error) {
+	var r struct {
+		RoleInference struct {
+			Implies []struct {
+				ID string `json:"id"`
+			} `json:"implies"`
+		} `json:"role_inference"`
+	}
+	_, err := c.osclient.Get(c.osclient.ServiceURL("roles", priorRoleID, "implies"), &r, nil)
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	impliedRoleIDs := []string{}
+	for _, implied := range r.RoleInference.Implies {
+		impliedRoleIDs = append(impliedRoleIDs, implied.ID)
+	}
+
+	return impliedRoleIDs, nil
+}
+
+// CreateImpliedRole - creates the inference rule that the role with
+// priorRoleID implies the role with impliedRoleID, it is ok if it exists
+func (c *Client) CreateImpliedRole(
+	log logr.Logger,
+	priorRoleID string,
+	impliedRoleID string,
+) error {
+	_, err := c.osclient.Put(c.osclient.ServiceURL("roles", priorRoleID, "implies", impliedRoleID), nil, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{201},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Role with ID %s implies role with ID %s", priorRoleID, impliedRoleID))
+
+	return nil
+}
+
+// DeleteImpliedRole - deletes the inference rule that the role with
+// priorRoleID implies the role with impliedRoleID, it is ok if it does not
+// exist
+func (c *Client) DeleteImpliedRole(
+	log logr.Logger,
+	priorRoleID string,
+	impliedRoleID string,
+) error {
+	_, err := c.osclient.Delete(c.osclient.ServiceURL("roles", priorRoleID, "implies", impliedRoleID), nil)
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			return err
+		}
+	}
+	log.Info(fmt.Sprintf("Role with ID %s no longer implies role with ID %s", priorRoleID, impliedRoleID))
+
+	return nil
+}
+
+// ReconcileImpliedRoles - makes the role with priorRoleID imply the roles
+// with impliedRoleIDs. Of the other inference rules of the role only the
+// ones in managedRoleIDs, which got created by a previous reconcile, are
+// deleted, rules created out-of-band are kept.
+func ReconcileImpliedRoles(
+	log logr.Logger,
+	os IdentityClient,
+	priorRoleID string,
+	impliedRoleIDs []string,
+	managedRoleIDs []string,
+) error {
+	current, err := os.GetImpliedRoleIDs(log, priorRoleID)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, id := range current {
+		existing[id] = true
+	}
+
+	desired := map[string]bool{}
+	for _, id := range impliedRoleIDs {
+		desired[id] = true
+		if existing[id] {
+			continue
+		}
+		if err := os.CreateImpliedRole(log, priorRoleID, id); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range managedRoleIDs {
+		if desired[id] || !existing[id] {
+			continue
+		}
+		if err := os.DeleteImpliedRole(log, priorRoleID, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/go-logr/logr"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+const roleInferenceOutput = `
+{
+    "role_inference": {
+        "prior_role": {"id": "admin", "name": "admin"},
+        "implies": [
+            {"id": "member", "name": "member"},
+            {"id": "legacy", "name": "legacy"},
+            {"id": "manual", "name": "manual"}
+        ]
+    }
+}
+`
+
+func TestReconcileImpliedRoles(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/roles/admin/implies", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestHeader(t, r, "X-Auth-Token", fake.TokenID)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, roleInferenceOutput)
+	})
+	changes := []string{}
+	th.Mux.HandleFunc("/roles/admin/implies/", func(w http.ResponseWriter, r *http.Request) {
+		changes = append(changes, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "PUT":
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	// reader gets created, legacy was managed and is removed, the manual
+	// rule is kept
+	c := &Client{osclient: fake.ServiceClient()}
+	err := ReconcileImpliedRoles(logr.Discard(), c, "admin",
+		[]string{"member", "reader"}, []string{"member", "legacy"})
+	th.AssertNoErr(t, err)
+	sort.Strings(changes)
+	th.AssertDeepEquals(t, []string{
+		"DELETE /roles/admin/implies/legacy",
+		"PUT /roles/admin/implies/reader",
+	}, changes)
+}
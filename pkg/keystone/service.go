@@ -0,0 +1,629 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/domains"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/services"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ErrServiceConflict - a service with the type and name is already registered
+// in keystone and the ConflictPolicy is Fail
+var ErrServiceConflict = errors.New("service already registered")
+
+// ErrDomainScopedServiceUnsupported - keystone does not keep the domain of a
+// service
+var ErrDomainScopedServiceUnsupported = errors.New("domain scoped services not supported")
+
+// ErrServiceNotConfirmed - a created service can not be read back from
+// keystone yet
+var ErrServiceNotConfirmed = errors.New("created service not visible")
+
+// ErrServiceNotRegistered - a service the operator does not manage is not
+// registered in keystone
+var ErrServiceNotRegistered = errors.New("service not registered")
+
+var (
+	// ServiceConfirmInterval - the interval a created service which is not
+	// visible yet gets looked up again in
+	ServiceConfirmInterval = 5 * time.Second
+	// ServiceConfirmGracePeriod - how long a created service may not be
+	// visible before it is considered deleted and gets created again
+	ServiceConfirmGracePeriod = 2 * time.Minute
+)
+
+// Service - keystone service
+type Service struct {
+	Name        string
+	Type        string
+	Description string
+	Enabled     bool
+	// Tags - if not nil, the tags attribute of the service gets set to it
+	Tags []string
+	// DomainID - if set, the service gets associated with the domain
+	DomainID string
+	// LocalizedDescriptions - descriptions by locale, set as the
+	// description_<locale> attributes of the service
+	LocalizedDescriptions map[string]string
+	// ID - optional ID to create the service with, keystone versions which
+	// assign the IDs themselves ignore it
+	ID string
+}
+
+// localizedDescriptionPrefix - prefix of the attributes of a service with its
+// localized descriptions
+const localizedDescriptionPrefix = "description_"
+
+// setLocalizedDescriptions - sets the localized descriptions of s in extra.
+// Keystone merges the attributes of an update into the stored ones, the
+// description_<locale> attributes not in s are kept.
+func setLocalizedDescriptions(extra map[string]interface{}, s Service) {
+	for locale, description := range s.LocalizedDescriptions {
+		extra[localizedDescriptionPrefix+locale] = description
+	}
+}
+
+// localizedDescriptionsChanged - returns true if one of the localized
+// descriptions differs from the attribute of service
+func localizedDescriptionsChanged(service *services.Service, descriptions map[string]string) bool {
+	for locale, description := range descriptions {
+		if service.Extra[localizedDescriptionPrefix+locale] != description {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetService - returns the service with the given type and name,
+// nil if there is no such service registered
+func (c *Client) GetService(
+	log logr.Logger,
+	serviceType string,
+	serviceName string,
+) (*services.Service, error) {
+	listOpts := services.ListOpts{
+		ServiceType: serviceType,
+		Name:        serviceName,
+	}
+
+	allPages, err := services.List(c.osclient, listOpts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+	allServices, err := services.ExtractServices(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(allServices) == 0 {
+		return nil, nil
+	} else if len(allServices) > 1 {
+		return nil, fmt.Errorf("multiple services registered for type %s and name %s", serviceType, serviceName)
+	}
+
+	return &allServices[0], nil
+}
+
+// GetServicesByType - returns all services of serviceType
+func (c *Client) GetServicesByType(
+	log logr.Logger,
+	serviceType string,
+) ([]services.Service, error) {
+	listOpts := services.ListOpts{
+		ServiceType: serviceType,
+	}
+
+	allPages, err := services.List(c.osclient, listOpts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return services.ExtractServices(allPages)
+}
+
+// getService - returns the service with the type and name, nil if there is no
+// such service registered. With the NameLookupClient behavior the services of
+// the type get listed and the name is matched here, for keystone versions which
+// do not filter the services by name. With the NameLookupType behavior the
+// service of the type in the region is returned, whatever its name.
+func getService(
+	log logr.Logger,
+	c IdentityClient,
+	nameLookup string,
+	serviceType string,
+	serviceName string,
+) (*services.Service, error) {
+	switch nameLookup {
+	case keystonev1beta1.NameLookupClient:
+	case keystonev1beta1.NameLookupType:
+		return getServiceOfType(log, c, serviceType)
+	default:
+		return c.GetService(log, serviceType, serviceName)
+	}
+
+	allServices, err := c.GetServicesByType(log, serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var service *services.Service
+	for i := range allServices {
+		if allServices[i].Extra["name"] != serviceName {
+			continue
+		}
+		if service != nil {
+			return nil, fmt.Errorf("multiple services registered for type %s and name %s", serviceType, serviceName)
+		}
+		service = &allServices[i]
+	}
+
+	return service, nil
+}
+
+// getServiceOfType - returns the service of serviceType in the region of the
+// client, nil if there is none. A single service of the type is used even
+// without endpoints in the region, of multiple ones the service with
+// endpoints in the region.
+func getServiceOfType(
+	log logr.Logger,
+	c IdentityClient,
+	serviceType string,
+) (*services.Service, error) {
+	allServices, err := c.GetServicesByType(log, serviceType)
+	if err != nil {
+		return nil, err
+	}
+	if len(allServices) == 1 {
+		return &allServices[0], nil
+	}
+
+	var service *services.Service
+	for i := range allServices {
+		regionEndpoints, err := c.GetEndpoints(log, allServices[i].ID, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(regionEndpoints) == 0 {
+			continue
+		}
+		if service != nil {
+			return nil, fmt.Errorf("multiple services registered for type %s in region %s", serviceType, c.GetRegionID())
+		}
+		service = &allServices[i]
+	}
+
+	return service, nil
+}
+
+// GetServiceByID - returns the service with serviceID, nil if it does not exist
+func (c *Client) GetServiceByID(
+	log logr.Logger,
+	serviceID string,
+) (*services.Service, error) {
+	service, err := services.Get(c.osclient, serviceID).Extract()
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// CreateService - creates a service and returns its ID
+func (c *Client) CreateService(
+	log logr.Logger,
+	s Service,
+) (string, error) {
+	createOpts := services.CreateOpts{
+		Type:    s.Type,
+		Enabled: &s.Enabled,
+		Extra: map[string]interface{}{
+			"name":        s.Name,
+			"description": s.Description,
+		},
+	}
+	if s.Tags != nil {
+		createOpts.Extra["tags"] = s.Tags
+	}
+	if s.DomainID != "" {
+		createOpts.Extra["domain_id"] = s.DomainID
+	}
+	if s.ID != "" {
+		createOpts.Extra["id"] = s.ID
+	}
+	setLocalizedDescriptions(createOpts.Extra, s)
+
+	service, err := services.Create(c.osclient, createOpts).Extract()
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("Service %s created with ID %s", s.Name, service.ID))
+	if s.ID != "" && service.ID != s.ID {
+		log.Info(fmt.Sprintf("Service %s got ID %s assigned instead of %s", s.Name, service.ID, s.ID))
+	}
+
+	return service.ID, nil
+}
+
+// UpdateService - updates the service with serviceID
+func (c *Client) UpdateService(
+	log logr.Logger,
+	s Service,
+	serviceID string,
+) error {
+	updateOpts := services.UpdateOpts{
+		Type:    s.Type,
+		Enabled: &s.Enabled,
+		Extra: map[string]interface{}{
+			"name":        s.Name,
+			"description": s.Description,
+		},
+	}
+	if s.Tags != nil {
+		updateOpts.Extra["tags"] = s.Tags
+	}
+	if s.DomainID != "" {
+		updateOpts.Extra["domain_id"] = s.DomainID
+	}
+	setLocalizedDescriptions(updateOpts.Extra, s)
+
+	_, err := services.Update(c.osclient, serviceID, updateOpts).Extract()
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Service %s with ID %s updated", s.Name, serviceID))
+
+	return nil
+}
+
+// DeleteService - deletes the service with serviceID, it is ok if it does not exist
+func (c *Client) DeleteService(
+	log logr.Logger,
+	serviceID string,
+) error {
+	err := services.Delete(c.osclient, serviceID).ExtractErr()
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			return err
+		}
+	}
+	log.Info(fmt.Sprintf("Service with ID %s deleted", serviceID))
+
+	return nil
+}
+
+// GetDomainID - returns the ID of the domain with domainName
+func (c *Client) GetDomainID(
+	log logr.Logger,
+	domainName string,
+) (string, error) {
+	allPages, err := domains.List(c.osclient, domains.ListOpts{Name: domainName}).AllPages()
+	if err != nil {
+		return "", err
+	}
+	allDomains, err := domains.ExtractDomains(allPages)
+	if err != nil {
+		return "", err
+	}
+	if len(allDomains) == 0 {
+		return "", fmt.Errorf("domain %s not found", domainName)
+	}
+
+	return allDomains[0].ID, nil
+}
+
+// ReconcileService - creates the service described by spec if there is none
+// registered for its type and name, or updates it if Enabled or the
+// description changed. A service renamed in the spec is found by the ID in the
+// status and updated. If the service got deleted out-of-band in between, it
+// gets recreated.
+//
+// A service registered by someone else for the type and name is handled by
+// spec.ConflictPolicy: Adopt takes it over, Fail returns ErrServiceConflict and
+// Rename registers a separate service named <name>-<renameSuffix>. With the
+// NameLookupType behavior the service of the type in the region is taken over
+// whatever its name, and renamed to the name of the spec.
+//
+// A service which gets created is registered with spec.ServiceID, if set.
+//
+// With status.DomainID set the service gets associated with the domain, if
+// keystone does not keep the domain ErrDomainScopedServiceUnsupported is
+// returned.
+//
+// Returns the ID of the service in keystone and if an existing service got
+// adopted.
+func ReconcileService(
+	log logr.Logger,
+	c IdentityClient,
+	spec keystonev1beta1.KeystoneServiceSpec,
+	status keystonev1beta1.KeystoneServiceStatus,
+	renameSuffix string,
+) (string, bool, error) {
+	s := Service{
+		Name:                  spec.ServiceName,
+		Type:                  spec.ServiceType,
+		Description:           spec.ServiceDescription,
+		Enabled:               spec.Enabled,
+		Tags:                  spec.Tags,
+		DomainID:              status.DomainID,
+		LocalizedDescriptions: spec.LocalizedDescriptions,
+		ID:                    spec.ServiceID,
+	}
+
+	// verify if there is already a service in keystone for the type and name
+	service, err := getService(
+		log,
+		c,
+		spec.NameLookup,
+		spec.ServiceType,
+		spec.ServiceName,
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	// the service got renamed, update the service from the status instead of
+	// registering a second one
+	if service == nil && status.ServiceID != "" {
+		renamed, err := c.GetServiceByID(log, status.ServiceID)
+		if err != nil {
+			return "", false, err
+		}
+		if renamed != nil && renamed.Type == spec.ServiceType {
+			err = c.UpdateService(log, s, renamed.ID)
+			if err != nil {
+				return "", false, err
+			}
+			log.Info(fmt.Sprintf("Service with ID %s renamed to %s", renamed.ID, spec.ServiceName))
+
+			return renamed.ID, false, nil
+		}
+
+		// the created service is not visible on the keystone replica yet,
+		// creating it again would register a duplicate
+		if renamed == nil && status.UnconfirmedServiceSince != nil &&
+			time.Since(status.UnconfirmedServiceSince.Time) < ServiceConfirmGracePeriod {
+			return status.ServiceID, false, fmt.Errorf("%w: %s service %s with ID %s",
+				ErrServiceNotConfirmed, spec.ServiceType, spec.ServiceName, status.ServiceID)
+		}
+	}
+
+	adopted := false
+	if service != nil && service.ID != status.ServiceID {
+		switch {
+		case spec.ConflictPolicy == keystonev1beta1.ConflictPolicyRename &&
+			spec.NameLookup == keystonev1beta1.NameLookupType:
+			// a second service of the type is what the lookup rules out
+			return "", false, fmt.Errorf("%w: %s service %s with ID %s, a service per type cannot be renamed",
+				ErrServiceConflict, spec.ServiceType, service.Extra["name"], service.ID)
+		case spec.ConflictPolicy == keystonev1beta1.ConflictPolicyRename:
+			// the tracked service, or the one to register, has the suffixed name
+			s.Name = fmt.Sprintf("%s-%s", spec.ServiceName, renameSuffix)
+			service, err = getService(log, c, spec.NameLookup, spec.ServiceType, s.Name)
+			if err != nil {
+				return "", false, err
+			}
+		case status.ServiceID != "":
+			// the service got registered again out-of-band, follow it
+		case spec.ConflictPolicy == keystonev1beta1.ConflictPolicyFail:
+			return "", false, fmt.Errorf("%w: %s service %s with ID %s", ErrServiceConflict, spec.ServiceType, spec.ServiceName, service.ID)
+		default:
+			log.Info(fmt.Sprintf("Service %s already registered with ID %s, adopting it", spec.ServiceName, service.ID))
+			adopted = true
+		}
+	}
+
+	if service == nil {
+		serviceID, err := createService(log, c, s)
+		return serviceID, false, err
+	}
+
+	if status.ServiceID != "" && status.ServiceID != service.ID {
+		log.Info(fmt.Sprintf("Service %s registered with ID %s instead of %s", spec.ServiceName, service.ID, status.ServiceID))
+	}
+
+	// remove the tags of the service if none are in the spec, unless the
+	// status records the operator did not set them, e.g. another tool tags the
+	// service
+	tags := GetServiceTags(service)
+	tagsChanged := !sets.NewString(tags...).Equal(sets.NewString(spec.Tags...))
+	if len(spec.Tags) == 0 && len(tags) > 0 {
+		if status.ManagedFields == nil || len(status.ManagedFields.Tags) > 0 {
+			s.Tags = []string{}
+		} else {
+			tagsChanged = false
+		}
+	}
+
+	// update the service ONLY if Enabled, Description, the localized
+	// descriptions, Tags or, for a service looked up by type, the name changed.
+	if service.Enabled != spec.Enabled ||
+		service.Extra["name"] != s.Name ||
+		service.Extra["description"] != spec.ServiceDescription ||
+		localizedDescriptionsChanged(service, spec.LocalizedDescriptions) ||
+		tagsChanged ||
+		(status.DomainID != "" && service.Extra["domain_id"] != status.DomainID) {
+		err := c.UpdateService(log, s, service.ID)
+		if _, ok := err.(gophercloud.ErrDefault400); ok && s.DomainID != "" {
+			return "", false, fmt.Errorf("%w: %s", ErrDomainScopedServiceUnsupported, err.Error())
+		}
+		if err != nil {
+			// the service got deleted out-of-band since it was listed, recreate it
+			if _, ok := err.(gophercloud.ErrDefault404); ok {
+				log.Info(fmt.Sprintf("Service %s with ID %s not found on update, recreating it", spec.ServiceName, service.ID))
+				serviceID, err := createService(log, c, s)
+				return serviceID, false, err
+			}
+			return "", false, err
+		}
+
+		if s.DomainID != "" {
+			err = confirmServiceDomain(log, c, service.ID, s.DomainID)
+			if err != nil {
+				return "", false, err
+			}
+		}
+	}
+
+	return service.ID, adopted, nil
+}
+
+// LookupService - returns the ID of the service of spec without creating or
+// updating it, for services another tool registers. The service with
+// serviceID is used if it still exists with the type, otherwise the service
+// gets looked up with the spec.NameLookup behavior. Returns
+// ErrServiceNotRegistered if there is none.
+func LookupService(
+	log logr.Logger,
+	c IdentityClient,
+	spec keystonev1beta1.KeystoneServiceSpec,
+	serviceID string,
+) (string, error) {
+	if serviceID != "" {
+		service, err := c.GetServiceByID(log, serviceID)
+		if err != nil {
+			return "", err
+		}
+		if service != nil && service.Type == spec.ServiceType {
+			return service.ID, nil
+		}
+	}
+
+	service, err := getService(log, c, spec.NameLookup, spec.ServiceType, spec.ServiceName)
+	if err != nil {
+		return "", err
+	}
+	if service == nil {
+		return "", fmt.Errorf("%w: %s service %s", ErrServiceNotRegistered, spec.ServiceType, spec.ServiceName)
+	}
+
+	return service.ID, nil
+}
+
+// createService - creates the service and reads it back. With a clustered
+// keystone a read after the create can miss the service due to replication
+// lag, the next reconcile would then register it again. If the service is
+// not visible yet, its ID is returned with ErrServiceNotConfirmed to track it
+// in the status and look it up again later.
+func createService(
+	log logr.Logger,
+	c IdentityClient,
+	s Service,
+) (string, error) {
+	serviceID, err := c.CreateService(log, s)
+	if _, ok := err.(gophercloud.ErrDefault400); ok && s.DomainID != "" {
+		return "", fmt.Errorf("%w: %s", ErrDomainScopedServiceUnsupported, err.Error())
+	}
+	if err != nil {
+		return "", err
+	}
+
+	service, err := c.GetServiceByID(log, serviceID)
+	if err != nil {
+		return serviceID, err
+	}
+	if service == nil {
+		return serviceID, fmt.Errorf("%w: %s service %s with ID %s", ErrServiceNotConfirmed, s.Type, s.Name, serviceID)
+	}
+	if s.DomainID != "" && service.Extra["domain_id"] != s.DomainID {
+		return serviceID, fmt.Errorf("%w: service %s has no domain_id", ErrDomainScopedServiceUnsupported, serviceID)
+	}
+
+	return serviceID, nil
+}
+
+// confirmServiceDomain - returns ErrDomainScopedServiceUnsupported if the
+// service with serviceID is not associated with domainID after an update
+func confirmServiceDomain(
+	log logr.Logger,
+	c IdentityClient,
+	serviceID string,
+	domainID string,
+) error {
+	service, err := c.GetServiceByID(log, serviceID)
+	if err != nil {
+		return err
+	}
+	if service != nil && service.Extra["domain_id"] != domainID {
+		return fmt.Errorf("%w: service %s has no domain_id", ErrDomainScopedServiceUnsupported, serviceID)
+	}
+
+	return nil
+}
+
+// DisableService - disables the service with serviceID, keeping its name and
+// description. It is ok if the service does not exist.
+func DisableService(
+	log logr.Logger,
+	c IdentityClient,
+	serviceID string,
+) error {
+	service, err := c.GetServiceByID(log, serviceID)
+	if err != nil {
+		return err
+	}
+	if service == nil || !service.Enabled {
+		return nil
+	}
+
+	name, _ := service.Extra["name"].(string)
+	description, _ := service.Extra["description"].(string)
+	domainID, _ := service.Extra["domain_id"].(string)
+	err = c.UpdateService(
+		log,
+		Service{
+			Name:        name,
+			Type:        service.Type,
+			Description: description,
+			Enabled:     false,
+			Tags:        GetServiceTags(service),
+			DomainID:    domainID,
+		},
+		serviceID)
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return nil
+		}
+		return err
+	}
+	log.Info(fmt.Sprintf("Service with ID %s disabled", serviceID))
+
+	return nil
+}
+
+// GetServiceTags - returns the tags attribute of the service
+func GetServiceTags(service *services.Service) []string {
+	values, _ := service.Extra["tags"].([]interface{})
+	tags := []string{}
+	for _, v := range values {
+		if tag, ok := v.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
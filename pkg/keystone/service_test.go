@@ -0,0 +1,579 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const serviceListOutput = `
+{
+    "links": {
+        "next": null,
+        "previous": null
+    },
+    "services": [%s]
+}
+`
+
+const placementService = `
+{
+    "id": "1234",
+    "type": "placement",
+    "enabled": %t,
+    "name": "placement",
+    "description": "Placement service"
+}
+`
+
+var placementSpec = keystonev1beta1.KeystoneServiceSpec{
+	ServiceType:        "placement",
+	ServiceName:        "placement",
+	ServiceDescription: "Placement service",
+	Enabled:            true,
+}
+
+// handleServices - serves the service list with the given services, other
+// requests to /services are passed to create
+func handleServices(t *testing.T, services string, create http.HandlerFunc) {
+	th.Mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			create(w, r)
+			return
+		}
+		th.TestHeader(t, r, "X-Auth-Token", fake.TokenID)
+		th.AssertEquals(t, "placement", r.URL.Query().Get("type"))
+		th.AssertEquals(t, "placement", r.URL.Query().Get("name"))
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, serviceListOutput, services)
+	})
+}
+
+// handleServiceGet - serves the placement service with serviceID
+func handleServiceGet(t *testing.T, serviceID string) {
+	th.Mux.HandleFunc("/services/"+serviceID, func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, strings.Replace(fmt.Sprintf(placementService, true), "1234", serviceID, 1))
+	})
+}
+
+func TestReconcileServiceCreate(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	created := false
+	handleServices(t, "", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		created = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+	handleServiceGet(t, "1234")
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, created)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceCreateConfirm(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	creates := 0
+	handleServices(t, "", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		creates++
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+
+	// the created service is not visible on the replica yet
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertEquals(t, true, errors.Is(err, ErrServiceNotConfirmed))
+	th.AssertEquals(t, "1234", serviceID)
+	th.AssertEquals(t, 1, creates)
+
+	// within the grace period the tracked service is not created again
+	since := metav1.Now()
+	status := keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234", UnconfirmedServiceSince: &since}
+	serviceID, _, err = ReconcileService(logr.Discard(), c, placementSpec, status, "openstack")
+	th.AssertEquals(t, true, errors.Is(err, ErrServiceNotConfirmed))
+	th.AssertEquals(t, "1234", serviceID)
+	th.AssertEquals(t, 1, creates)
+
+	// after the grace period the service is considered deleted
+	since = metav1.NewTime(time.Now().Add(-ServiceConfirmGracePeriod))
+	_, _, err = ReconcileService(logr.Discard(), c, placementSpec, status, "openstack")
+	th.AssertEquals(t, true, errors.Is(err, ErrServiceNotConfirmed))
+	th.AssertEquals(t, 2, creates)
+}
+
+func TestReconcileServiceCreateWithID(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// keystone ignores the requested ID and assigns its own
+	handleServices(t, "", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "id": "5678", "name": "placement", "description": "Placement service"}}`)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+	handleServiceGet(t, "1234")
+
+	spec := placementSpec
+	spec.ServiceID = "5678"
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceClientNameLookup(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// keystone ignoring the name filter returns all services of the type
+	th.Mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "placement", r.URL.Query().Get("type"))
+		th.AssertEquals(t, false, r.URL.Query().Has("name"))
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, serviceListOutput, strings.Join([]string{
+			strings.Replace(strings.Replace(fmt.Sprintf(placementService, true), "1234", "5678", 1),
+				`"name": "placement"`, `"name": "placement-legacy"`, 1),
+			fmt.Sprintf(placementService, true),
+		}, ","))
+	})
+
+	spec := placementSpec
+	spec.NameLookup = keystonev1beta1.NameLookupClient
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceTypeNameLookup(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// two services of the type, only the legacy one has endpoints in the region
+	th.Mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "placement", r.URL.Query().Get("type"))
+		th.AssertEquals(t, false, r.URL.Query().Has("name"))
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, serviceListOutput, strings.Join([]string{
+			strings.Replace(strings.Replace(fmt.Sprintf(placementService, true), "1234", "5678", 1),
+				`"name": "placement"`, `"name": "placement-legacy"`, 1),
+			strings.Replace(fmt.Sprintf(placementService, true), "1234", "9012", 1),
+		}, ","))
+	})
+	th.Mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.AssertEquals(t, "RegionOne", r.URL.Query().Get("region_id"))
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("service_id") == "5678" {
+			fmt.Fprintf(w, `{"endpoints": [%s]}`, strings.Replace(placementEndpoint, `"service_id": "1234"`, `"service_id": "5678"`, 1))
+			return
+		}
+		fmt.Fprintf(w, `{"endpoints": []}`)
+	})
+
+	// the legacy service gets adopted and renamed
+	updated := false
+	th.Mux.HandleFunc("/services/5678", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service"}}`)
+		updated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, strings.Replace(fmt.Sprintf(placementService, true), "1234", "5678", 1))
+	})
+
+	spec := placementSpec
+	spec.NameLookup = keystonev1beta1.NameLookupType
+	c := &Client{osclient: fake.ServiceClient(), regionID: "RegionOne"}
+	serviceID, adopted, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, adopted)
+	th.AssertEquals(t, true, updated)
+	th.AssertEquals(t, "5678", serviceID)
+
+	// a second service of the type cannot be registered by renaming
+	spec.ConflictPolicy = keystonev1beta1.ConflictPolicyRename
+	_, _, err = ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertEquals(t, true, errors.Is(err, ErrServiceConflict))
+}
+
+func TestReconcileServiceUpdate(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	handleServices(t, fmt.Sprintf(placementService, false), nil)
+
+	updated := false
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service"}}`)
+		updated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, updated)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceTags(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	handleServices(t, fmt.Sprintf(placementService, true), nil)
+
+	updated := false
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service", "tags": ["managed-by-keystone-operator"]}}`)
+		updated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+
+	spec := placementSpec
+	spec.Tags = []string{"managed-by-keystone-operator"}
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, updated)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceForeignTags(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// another tool tagged the service
+	handleServices(t, strings.Replace(fmt.Sprintf(placementService, true),
+		`"description": "Placement service"`,
+		`"description": "Placement service", "tags": ["billing"]`, 1), nil)
+
+	updates := 0
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service", "tags": []}}`)
+		updates++
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+
+	// the operator did not set tags, they are kept
+	c := &Client{osclient: fake.ServiceClient()}
+	status := keystonev1beta1.KeystoneServiceStatus{
+		ServiceID: "1234",
+		ManagedFields: &keystonev1beta1.ManagedServiceFields{
+			Type:    "placement",
+			Name:    "placement",
+			Enabled: true,
+		},
+	}
+	_, _, err := ReconcileService(logr.Discard(), c, placementSpec, status, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 0, updates)
+
+	// the operator set tags which got removed from the spec
+	status.ManagedFields.Tags = []string{"billing"}
+	_, _, err = ReconcileService(logr.Discard(), c, placementSpec, status, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 1, updates)
+}
+
+func TestReconcileServiceLocalizedDescriptions(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// the french description got set out-of-band
+	handleServices(t, strings.Replace(fmt.Sprintf(placementService, true),
+		`"description": "Placement service"`,
+		`"description": "Placement service", "description_de": "Platzierung", "description_fr": "Placement"`, 1), nil)
+
+	updated := false
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service", "description_de": "Platzierungsdienst"}}`)
+		updated = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+
+	spec := placementSpec
+	spec.LocalizedDescriptions = map[string]string{"de": "Platzierung"}
+	c := &Client{osclient: fake.ServiceClient()}
+	_, _, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, updated)
+
+	spec.LocalizedDescriptions = map[string]string{"de": "Platzierungsdienst"}
+	_, _, err = ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, updated)
+}
+
+func TestReconcileServiceDomainUnsupported(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	handleServices(t, "", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service", "domain_id": "d1"}}`)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+	// the service is read back without the domain
+	handleServiceGet(t, "1234")
+
+	c := &Client{osclient: fake.ServiceClient()}
+	_, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{DomainID: "d1"}, "openstack")
+	th.AssertEquals(t, true, errors.Is(err, ErrDomainScopedServiceUnsupported))
+}
+
+func TestReconcileServiceUnchanged(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// there is no handler for /services/1234, an update fails the test
+	handleServices(t, fmt.Sprintf(placementService, true), func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceRecreateOnUpdateNotFound(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	created := false
+	handleServices(t, fmt.Sprintf(placementService, false), func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		created = true
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"service": %s}`, strings.Replace(fmt.Sprintf(placementService, true), "1234", "4321", 1))
+	})
+
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PATCH")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handleServiceGet(t, "4321")
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, created)
+	th.AssertEquals(t, "4321", serviceID)
+}
+
+func TestReconcileServiceRename(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// no service registered with the new name
+	handleServices(t, "", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	updated := false
+	th.Mux.HandleFunc("/services/1234", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"service": %s}`, strings.Replace(fmt.Sprintf(placementService, true), `"name": "placement"`, `"name": "old"`, 1))
+			return
+		}
+		th.TestMethod(t, r, "PATCH")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement", "description": "Placement service"}}`)
+		updated = true
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service": %s}`, fmt.Sprintf(placementService, true))
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, _, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, updated)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceConflictAdopt(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	handleServices(t, fmt.Sprintf(placementService, true), func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, adopted, err := ReconcileService(logr.Discard(), c, placementSpec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, adopted)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceConflictFail(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	handleServices(t, fmt.Sprintf(placementService, true), func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	spec := placementSpec
+	spec.ConflictPolicy = keystonev1beta1.ConflictPolicyFail
+
+	c := &Client{osclient: fake.ServiceClient()}
+	_, _, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertEquals(t, true, errors.Is(err, ErrServiceConflict))
+
+	// the service registered by the KeystoneService is no conflict
+	serviceID, adopted, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{ServiceID: "1234"}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, adopted)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestReconcileServiceConflictRename(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	created := false
+	th.Mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusOK)
+			if r.URL.Query().Get("name") == "placement" {
+				fmt.Fprintf(w, serviceListOutput, fmt.Sprintf(placementService, true))
+				return
+			}
+			th.AssertEquals(t, "placement-openstack", r.URL.Query().Get("name"))
+			fmt.Fprintf(w, serviceListOutput, "")
+			return
+		}
+		th.TestMethod(t, r, "POST")
+		th.TestJSONRequest(t, r, `{"service": {"type": "placement", "enabled": true, "name": "placement-openstack", "description": "Placement service"}}`)
+		created = true
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"service": %s}`, strings.Replace(fmt.Sprintf(placementService, true), "1234", "4321", 1))
+	})
+
+	handleServiceGet(t, "4321")
+
+	spec := placementSpec
+	spec.ConflictPolicy = keystonev1beta1.ConflictPolicyRename
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, adopted, err := ReconcileService(logr.Discard(), c, spec, keystonev1beta1.KeystoneServiceStatus{}, "openstack")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, created)
+	th.AssertEquals(t, false, adopted)
+	th.AssertEquals(t, "4321", serviceID)
+}
+
+func TestLookupService(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// the service is disabled, it does not get updated
+	handleServices(t, fmt.Sprintf(placementService, false), func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	serviceID, err := LookupService(logr.Discard(), c, placementSpec, "")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "1234", serviceID)
+}
+
+func TestLookupServiceNotRegistered(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	handleServices(t, "", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	c := &Client{osclient: fake.ServiceClient()}
+	_, err := LookupService(logr.Discard(), c, placementSpec, "")
+	th.AssertEquals(t, true, errors.Is(err, ErrServiceNotRegistered))
+}
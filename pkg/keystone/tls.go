@@ -0,0 +1,165 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	keystonev1beta1 "github.com/openstack-k8s-operators/keystone-operator/api/v1beta1"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/helper"
+	"github.com/openstack-k8s-operators/lib-common/modules/common/secret"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ClientTLSCAKey - key of the CA bundle in a ClientTLSSecret
+const ClientTLSCAKey = "ca.crt"
+
+// ErrClientCertificate - the client certificate, its key or the CA bundle of
+// the ClientTLSSecret cannot be loaded
+var ErrClientCertificate = errors.New("client certificate cannot be loaded")
+
+// clientCertTransport - transport presenting a client certificate, with the
+// hash of the TLS material it got created from
+type clientCertTransport struct {
+	hash      [sha256.Size]byte
+	transport *http.Transport
+	rt        http.RoundTripper
+}
+
+// ClientCertTransport - returns the transport presenting the client
+// certificate certPEM with the key keyPEM, also trusting the CAs of caPEM if
+// set. It gets created like the Default transport, sharing its request limit,
+// and is kept by name, e.g. namespace/name of the Secret, so the clients
+// created on every reconcile reuse its connections. Changed TLS material
+// replaces the transport of name. Returns ErrClientCertificate if the
+// certificate, key or CAs cannot be loaded.
+func (t *Transports) ClientCertTransport(
+	name string,
+	certPEM []byte,
+	keyPEM []byte,
+	caPEM []byte,
+) (http.RoundTripper, error) {
+	// without Transports, e.g. in the subcommands, the transport is not kept
+	if t == nil {
+		tlsConfig, err := clientTLSConfig(certPEM, keyPEM, caPEM)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+
+		return transport, nil
+	}
+
+	h := sha256.New()
+	for _, pem := range [][]byte{certPEM, keyPEM, caPEM} {
+		h.Write(pem)
+		h.Write([]byte{0})
+	}
+	var hash [sha256.Size]byte
+	copy(hash[:], h.Sum(nil))
+
+	t.clientCertMu.Lock()
+	defer t.clientCertMu.Unlock()
+
+	current, ok := t.clientCert[name]
+	if ok && current.hash == hash {
+		return current.rt, nil
+	}
+
+	tlsConfig, err := clientTLSConfig(certPEM, keyPEM, caPEM)
+	if err != nil {
+		return nil, err
+	}
+	transport := NewTransport(t.opts)
+	transport.TLSClientConfig = tlsConfig
+	if ok {
+		current.transport.CloseIdleConnections()
+	}
+	t.clientCert[name] = clientCertTransport{hash: hash, transport: transport, rt: t.limitRequests(transport)}
+
+	return t.clientCert[name].rt, nil
+}
+
+// clientTLSConfig - returns the TLS config presenting the client certificate
+// certPEM with the key keyPEM, trusting the system CAs and the ones of caPEM
+func clientTLSConfig(certPEM []byte, keyPEM []byte, caPEM []byte) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrClientCertificate, err.Error())
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(caPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%w: no CA certificate in %s", ErrClientCertificate, ClientTLSCAKey)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// getClientCertTransport - returns the transport of transports presenting
+// the client certificate of the ClientTLSSecret of keystoneAPI, nil without
+// one. Waits for the Secret if it does not exist yet.
+func getClientCertTransport(
+	ctx context.Context,
+	h *helper.Helper,
+	keystoneAPI *keystonev1beta1.KeystoneAPI,
+	transports *Transports,
+) (http.RoundTripper, ctrl.Result, error) {
+	if keystoneAPI.Spec.ClientTLSSecret == "" {
+		return nil, ctrl.Result{}, nil
+	}
+
+	s, _, err := secret.GetSecret(ctx, h, keystoneAPI.Spec.ClientTLSSecret, keystoneAPI.Namespace)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			h.GetLogger().Info(fmt.Sprintf("Client TLS secret %s not found, retrying", keystoneAPI.Spec.ClientTLSSecret))
+			return nil, ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+		return nil, ctrl.Result{}, err
+	}
+
+	rt, err := transports.ClientCertTransport(
+		keystoneAPI.Namespace+"/"+keystoneAPI.Spec.ClientTLSSecret,
+		s.Data[corev1.TLSCertKey],
+		s.Data[corev1.TLSPrivateKeyKey],
+		s.Data[ClientTLSCAKey])
+	if err != nil {
+		return nil, ctrl.Result{}, fmt.Errorf("secret %s: %w", keystoneAPI.Spec.ClientTLSSecret, err)
+	}
+
+	return rt, ctrl.Result{}, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+// newClientCert - returns a self-signed client certificate and its key, PEM
+// encoded
+func newClientCert(t *testing.T, cn string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	th.AssertNoErr(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	th.AssertNoErr(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	th.AssertNoErr(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestClientCertTransport(t *testing.T) {
+	certPEM, keyPEM := newClientCert(t, "keystone-operator")
+	transports := NewTransports(TransportOptions{}, 0)
+
+	rt, err := transports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, certPEM)
+	th.AssertNoErr(t, err)
+	transport := rt.(*http.Transport)
+	th.AssertEquals(t, 1, len(transport.TLSClientConfig.Certificates))
+	th.AssertEquals(t, true, transport.TLSClientConfig.RootCAs != nil)
+
+	// the transport is reused while the certificate is unchanged
+	again, err := transports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, certPEM)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, rt, again)
+
+	// a rotated certificate replaces it
+	certPEM, keyPEM = newClientCert(t, "keystone-operator")
+	rotated, err := transports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, nil)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, rt == rotated)
+	th.AssertEquals(t, true, rotated.(*http.Transport).TLSClientConfig.RootCAs == nil)
+
+	// a key not matching the certificate cannot be loaded
+	_, otherKeyPEM := newClientCert(t, "other")
+	_, err = transports.ClientCertTransport("openstack/keystone-client", certPEM, otherKeyPEM, nil)
+	th.AssertEquals(t, true, errors.Is(err, ErrClientCertificate))
+
+	_, err = transports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, []byte("not a CA"))
+	th.AssertEquals(t, true, errors.Is(err, ErrClientCertificate))
+
+	// without Transports the transport is not kept
+	var noTransports *Transports
+	rt, err = noTransports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, nil)
+	th.AssertNoErr(t, err)
+	again, err = noTransports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, nil)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, rt == again)
+}
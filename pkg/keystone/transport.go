@@ -0,0 +1,157 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportOptions - connection pool settings of the HTTP transports the
+// clients send their keystone requests with
+type TransportOptions struct {
+	// MaxIdleConns - idle connections kept open across all hosts, 0 means no
+	// limit
+	MaxIdleConns int
+	// MaxIdleConnsPerHost - idle connections kept open per host, to reuse
+	// them for the next burst of reconciles
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost - connections per host, further requests wait for a
+	// free one, 0 means no limit
+	MaxConnsPerHost int
+	// IdleConnTimeout - time after which an idle connection gets closed
+	IdleConnTimeout time.Duration
+}
+
+// Transports - the HTTP transports shared by all clients. A client is
+// created for every reconcile, sharing the transports lets them reuse the
+// connections to keystone. Created by main and set in the ClientOptions of
+// the reconcilers. A nil Transports uses http.DefaultTransport.
+type Transports struct {
+	// opts - the options the transports get created with
+	opts TransportOptions
+	// slots - the requests in flight of all transports, nil without limit
+	slots chan struct{}
+	// rt - the transport of the clients without client certificate
+	rt http.RoundTripper
+
+	// clientCert - the transports presenting a client certificate, by the
+	// name they got requested with
+	clientCertMu sync.Mutex
+	clientCert   map[string]clientCertTransport
+}
+
+// NewTransports - returns Transports created by NewTransport with opts,
+// limited to maxRequests requests in flight like LimitTransport. The
+// transports presenting a client certificate get the same settings and share
+// the limit.
+func NewTransports(opts TransportOptions, maxRequests int) *Transports {
+	t := &Transports{
+		opts:       opts,
+		clientCert: map[string]clientCertTransport{},
+	}
+	if maxRequests > 0 {
+		t.slots = make(chan struct{}, maxRequests)
+	}
+	t.rt = t.limitRequests(NewTransport(opts))
+
+	return t
+}
+
+// Default - returns the transport of the clients without client certificate
+func (t *Transports) Default() http.RoundTripper {
+	if t == nil {
+		return http.DefaultTransport
+	}
+
+	return t.rt
+}
+
+// limitRequests - returns rt limited by the slots shared by all transports
+func (t *Transports) limitRequests(rt http.RoundTripper) http.RoundTripper {
+	if t.slots == nil {
+		return rt
+	}
+
+	return &limitTransport{rt: rt, slots: t.slots}
+}
+
+// NewTransport - returns a copy of the default HTTP transport, with its proxy,
+// dial and TLS settings, using the connection pool settings of opts
+func NewTransport(opts TransportOptions) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = opts.MaxIdleConns
+	t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = opts.MaxConnsPerHost
+	t.IdleConnTimeout = opts.IdleConnTimeout
+
+	return t
+}
+
+// LimitTransport - returns rt limited to maxRequests requests in flight at a
+// time, across all clients sharing it. Further requests wait for a free slot
+// or until their context is done. A request holds its slot until its
+// response body is closed. rt is returned as is if maxRequests is 0.
+func LimitTransport(rt http.RoundTripper, maxRequests int) http.RoundTripper {
+	if maxRequests <= 0 {
+		return rt
+	}
+
+	return &limitTransport{rt: rt, slots: make(chan struct{}, maxRequests)}
+}
+
+// limitTransport - http.RoundTripper passing at most cap(slots) requests at a
+// time to rt
+type limitTransport struct {
+	rt    http.RoundTripper
+	slots chan struct{}
+}
+
+// RoundTrip - waits for a free slot and sends the request with rt
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := func() { <-t.slots }
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// releaseBody - response body freeing the slot of its request once closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close - closes the body and frees the slot
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+
+	return err
+}
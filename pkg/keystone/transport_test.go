@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport := NewTransport(TransportOptions{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 20,
+		MaxConnsPerHost:     30,
+		IdleConnTimeout:     time.Minute,
+	})
+	th.AssertEquals(t, 50, transport.MaxIdleConns)
+	th.AssertEquals(t, 20, transport.MaxIdleConnsPerHost)
+	th.AssertEquals(t, 30, transport.MaxConnsPerHost)
+	th.AssertEquals(t, time.Minute, transport.IdleConnTimeout)
+
+	// the settings of the default transport are kept
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	th.AssertEquals(t, defaultTransport.TLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	th.AssertEquals(t, true, transport.Proxy != nil)
+	th.AssertEquals(t, false, transport == defaultTransport)
+}
+
+func TestNewTransports(t *testing.T) {
+	var noTransports *Transports
+	th.AssertEquals(t, http.DefaultTransport, noTransports.Default())
+
+	transports := NewTransports(TransportOptions{MaxIdleConnsPerHost: 20}, 0)
+	th.AssertEquals(t, 20, transports.Default().(*http.Transport).MaxIdleConnsPerHost)
+
+	// the client certificate transports share the request limit
+	certPEM, keyPEM := newClientCert(t, "keystone-operator")
+	transports = NewTransports(TransportOptions{MaxIdleConnsPerHost: 20}, 2)
+	rt, err := transports.ClientCertTransport("openstack/keystone-client", certPEM, keyPEM, nil)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, transports.Default().(*limitTransport).slots, rt.(*limitTransport).slots)
+	th.AssertEquals(t, 20, rt.(*limitTransport).rt.(*http.Transport).MaxIdleConnsPerHost)
+}
+
+// blockingTransport - signals started requests and answers them once
+// unblocked
+type blockingTransport struct {
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (t *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.started <- struct{}{}
+	<-t.unblock
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestLimitTransport(t *testing.T) {
+	th.AssertEquals(t, http.DefaultTransport, LimitTransport(http.DefaultTransport, 0))
+
+	rt := &blockingTransport{started: make(chan struct{}, 2), unblock: make(chan struct{})}
+	transport := LimitTransport(rt, 1)
+
+	req, err := http.NewRequest(http.MethodGet, "http://keystone:5000/v3", nil)
+	th.AssertNoErr(t, err)
+	responses := make(chan *http.Response)
+	go func() {
+		resp, err := transport.RoundTrip(req)
+		th.AssertNoErr(t, err)
+		responses <- resp
+	}()
+
+	// the slot is taken by the blocked request
+	<-rt.started
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = transport.RoundTrip(req.WithContext(ctx))
+	th.AssertEquals(t, context.DeadlineExceeded, err)
+
+	// closing the body of the response frees the slot
+	close(rt.unblock)
+	resp := <-responses
+	th.AssertNoErr(t, resp.Body.Close())
+	resp, err = transport.RoundTrip(req)
+	th.AssertNoErr(t, err)
+	th.AssertNoErr(t, resp.Body.Close())
+}
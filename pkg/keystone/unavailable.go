@@ -0,0 +1,56 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// ErrAuthURLsUnavailable - keystone was unavailable at all AuthURLs
+var ErrAuthURLsUnavailable = errors.New("keystone unavailable at all AuthURLs")
+
+// IsUnavailable - returns true if err tells keystone is unavailable, i.e. it
+// could not be connected, the connection got closed or timed out, or keystone
+// answered with a 5xx. Rejected credentials or an invalid configuration are
+// not, they fail the same at every identity endpoint.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrAuthURLsUnavailable) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var codeErr gophercloud.StatusCodeError
+	return errors.As(err, &codeErr) && codeErr.GetStatusCode() >= http.StatusInternalServerError
+}
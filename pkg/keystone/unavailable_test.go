@@ -0,0 +1,50 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "http://keystone:5000/v3/auth/tokens", Err: &net.OpError{Op: "dial", Net: "tcp"}}
+	timeout := &url.Error{Op: "Post", URL: "http://keystone:5000/v3/auth/tokens", Err: context.DeadlineExceeded}
+	closed := &url.Error{Op: "Post", URL: "http://keystone:5000/v3/auth/tokens", Err: io.EOF}
+	err503 := gophercloud.ErrDefault503{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusServiceUnavailable}}
+	err502 := gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusBadGateway}
+	err401 := gophercloud.ErrDefault401{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusUnauthorized}}
+	err404 := gophercloud.ErrDefault404{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusNotFound}}
+
+	th.AssertEquals(t, false, IsUnavailable(nil))
+	th.AssertEquals(t, true, IsUnavailable(refused))
+	th.AssertEquals(t, true, IsUnavailable(timeout))
+	th.AssertEquals(t, true, IsUnavailable(closed))
+	th.AssertEquals(t, true, IsUnavailable(err503))
+	th.AssertEquals(t, true, IsUnavailable(err502))
+	th.AssertEquals(t, true, IsUnavailable(fmt.Errorf("%w: boom", ErrAuthURLsUnavailable)))
+	th.AssertEquals(t, false, IsUnavailable(err401))
+	th.AssertEquals(t, false, IsUnavailable(err404))
+	th.AssertEquals(t, false, IsUnavailable(fmt.Errorf("%w: regionOne, regionTwo", ErrAmbiguousRegion)))
+}
@@ -0,0 +1,194 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/roles"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/users"
+)
+
+// Project - keystone project
+type Project struct {
+	Name        string
+	Description string
+}
+
+// User - keystone user
+type User struct {
+	Name      string
+	Password  string
+	ProjectID string
+}
+
+// CreateProject - creates the project if it does not exist and returns its ID
+func (c *Client) CreateProject(
+	log logr.Logger,
+	p Project,
+) (string, error) {
+	allPages, err := projects.List(c.osclient, projects.ListOpts{Name: p.Name}).AllPages()
+	if err != nil {
+		return "", err
+	}
+	allProjects, err := projects.ExtractProjects(allPages)
+	if err != nil {
+		return "", err
+	}
+	if len(allProjects) > 0 {
+		return allProjects[0].ID, nil
+	}
+
+	project, err := projects.Create(c.osclient, projects.CreateOpts{
+		Name:        p.Name,
+		Description: p.Description,
+	}).Extract()
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("Project %s created with ID %s", p.Name, project.ID))
+
+	return project.ID, nil
+}
+
+// getRoleID - returns the ID of the role with roleName, empty if it does not exist
+func (c *Client) getRoleID(
+	roleName string,
+) (string, error) {
+	allPages, err := roles.List(c.osclient, roles.ListOpts{Name: roleName}).AllPages()
+	if err != nil {
+		return "", err
+	}
+	allRoles, err := roles.ExtractRoles(allPages)
+	if err != nil {
+		return "", err
+	}
+	if len(allRoles) == 0 {
+		return "", nil
+	}
+
+	return allRoles[0].ID, nil
+}
+
+// CreateRole - creates the role if it does not exist and returns its ID
+func (c *Client) CreateRole(
+	log logr.Logger,
+	roleName string,
+) (string, error) {
+	roleID, err := c.getRoleID(roleName)
+	if err != nil || roleID != "" {
+		return roleID, err
+	}
+
+	role, err := roles.Create(c.osclient, roles.CreateOpts{Name: roleName}).Extract()
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("Role %s created with ID %s", roleName, role.ID))
+
+	return role.ID, nil
+}
+
+// getUsers - returns the users with userName
+func (c *Client) getUsers(
+	userName string,
+) ([]users.User, error) {
+	allPages, err := users.List(c.osclient, users.ListOpts{Name: userName}).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return users.ExtractUsers(allPages)
+}
+
+// CreateUser - creates the user if it does not exist and returns its ID
+func (c *Client) CreateUser(
+	log logr.Logger,
+	u User,
+) (string, error) {
+	allUsers, err := c.getUsers(u.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(allUsers) > 0 {
+		return allUsers[0].ID, nil
+	}
+
+	user, err := users.Create(c.osclient, users.CreateOpts{
+		Name:             u.Name,
+		Password:         u.Password,
+		DefaultProjectID: u.ProjectID,
+	}).Extract()
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("User %s created with ID %s", u.Name, user.ID))
+
+	return user.ID, nil
+}
+
+// AssignUserRole - assigns the role to the user in the project
+func (c *Client) AssignUserRole(
+	log logr.Logger,
+	roleName string,
+	userID string,
+	projectID string,
+) error {
+	roleID, err := c.getRoleID(roleName)
+	if err != nil {
+		return err
+	}
+	if roleID == "" {
+		return fmt.Errorf("role %s not found", roleName)
+	}
+
+	err = roles.Assign(c.osclient, roleID, roles.AssignOpts{
+		UserID:    userID,
+		ProjectID: projectID,
+	}).ExtractErr()
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Role %s assigned to user %s in project %s", roleName, userID, projectID))
+
+	return nil
+}
+
+// DeleteUser - deletes the user with userName, it is ok if it does not exist
+func (c *Client) DeleteUser(
+	log logr.Logger,
+	userName string,
+) error {
+	allUsers, err := c.getUsers(userName)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range allUsers {
+		err = users.Delete(c.osclient, user.ID).ExtractErr()
+		if err != nil {
+			if _, ok := err.(gophercloud.ErrDefault404); !ok {
+				return err
+			}
+		}
+		log.Info(fmt.Sprintf("User %s with ID %s deleted", userName, user.ID))
+	}
+
+	return nil
+}